@@ -0,0 +1,285 @@
+// Package analytics summarizes the engagement of Tumblr posts: notes by post type, by tag, by hour of day and
+// day of week, and over time, along with the top posts.
+//
+// A Report is built from any tumblr.PostIterator, such as a blog's PostPager, and exported as JSON or CSV:
+//
+//	report, err := analytics.Aggregate(tumblr.NewPostPager(client, "staff", nil), analytics.Options{Period: analytics.Quarter})
+//	report.WriteCSV(w, analytics.ByTag)
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tumblr/tumblr.go"
+)
+
+// Default number of top posts of a Report
+const DefaultTopPosts = 10
+
+// Period is the span of time grouped together in a Report's OverTime.
+type Period string
+
+const (
+	Day     Period = "day"
+	Week    Period = "week"
+	Month   Period = "month"
+	Quarter Period = "quarter"
+	Year    Period = "year"
+)
+
+// Options configure Aggregate.
+type Options struct {
+	// Time zone of the hours, days of week and periods; defaults to UTC
+	Location *time.Location
+	// Span of the OverTime groups; defaults to Month
+	Period Period
+	// Number of top posts kept; defaults to DefaultTopPosts
+	TopPosts int
+}
+
+// Group is the engagement of the posts sharing a type, tag, time of day or period.
+type Group struct {
+	Key       string  `json:"key"`
+	Posts     int     `json:"posts"`
+	Notes     uint64  `json:"notes"`
+	MeanNotes float64 `json:"mean_notes"`
+	MaxNotes  uint64  `json:"max_notes"`
+}
+
+// Adds a post's notes to a group
+func (g *Group) add(notes uint64) {
+	g.Posts++
+	g.Notes += notes
+	if notes > g.MaxNotes {
+		g.MaxNotes = notes
+	}
+}
+
+// TopPost is one of the posts with the most notes.
+type TopPost struct {
+	Id        uint64    `json:"id"`
+	Type      string    `json:"type"`
+	Url       string    `json:"url"`
+	Summary   string    `json:"summary"`
+	Tags      []string  `json:"tags"`
+	Notes     uint64    `json:"notes"`
+	Published time.Time `json:"published"`
+}
+
+// Report is the engagement of a set of posts. Groups by type and tag are ordered by notes, most first; the
+// others are in time order, OverTime including the periods without posts. Posts without a timestamp are only
+// counted by type and tag.
+type Report struct {
+	Posts int    `json:"posts"`
+	Notes uint64 `json:"notes"`
+	// Posts which failed to decode; they are still counted, with whichever common fields decoded
+	DecodeErrors int `json:"decode_errors"`
+	// Publication times of the oldest and newest posts
+	First     time.Time `json:"first"`
+	Last      time.Time `json:"last"`
+	Period    Period    `json:"period"`
+	ByType    []Group   `json:"by_type"`
+	ByTag     []Group   `json:"by_tag"`
+	ByHour    []Group   `json:"by_hour"`
+	ByWeekday []Group   `json:"by_weekday"`
+	OverTime  []Group   `json:"over_time"`
+	Top       []TopPost `json:"top"`
+}
+
+// Returns the start of the period holding t
+func (p Period) start(t time.Time) time.Time {
+	year, month, day := t.Date()
+	switch p {
+	case Day:
+		return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+	case Week:
+		// weeks start on Monday, as ISO weeks do
+		return time.Date(year, month, day-(int(t.Weekday())+6)%7, 0, 0, 0, 0, t.Location())
+	case Quarter:
+		return time.Date(year, month-(month-1)%3, 1, 0, 0, 0, 0, t.Location())
+	case Year:
+		return time.Date(year, 1, 1, 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+}
+
+// Returns the start of the period after the one starting at start
+func (p Period) next(start time.Time) time.Time {
+	switch p {
+	case Day:
+		return start.AddDate(0, 0, 1)
+	case Week:
+		return start.AddDate(0, 0, 7)
+	case Quarter:
+		return start.AddDate(0, 3, 0)
+	case Year:
+		return start.AddDate(1, 0, 0)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// Returns the key of the period starting at start, e.g. 2024-W05 or 2024-Q1
+func (p Period) key(start time.Time) string {
+	switch p {
+	case Day:
+		return start.Format("2006-01-02")
+	case Week:
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case Quarter:
+		return fmt.Sprintf("%d-Q%d", start.Year(), (int(start.Month())-1)/3+1)
+	case Year:
+		return start.Format("2006")
+	}
+	return start.Format("2006-01")
+}
+
+// Returns groups sorted by notes, most first, then by key
+func sortedGroups(groups map[string]*Group) []Group {
+	sorted := make([]Group, 0, len(groups))
+	for _, group := range groups {
+		sorted = append(sorted, *group)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Notes != sorted[j].Notes {
+			return sorted[i].Notes > sorted[j].Notes
+		}
+		return sorted[i].Key < sorted[j].Key
+	})
+	return sorted
+}
+
+// Sets the mean notes of groups
+func setMeans(groups []Group) {
+	for i := range groups {
+		if groups[i].Posts > 0 {
+			groups[i].MeanNotes = float64(groups[i].Notes) / float64(groups[i].Posts)
+		}
+	}
+}
+
+// Aggregate reads every post of posts and reports their engagement. Tags are grouped case-insensitively,
+// under their lower case form.
+func Aggregate(posts tumblr.PostIterator, options Options) (*Report, error) {
+	location := options.Location
+	if location == nil {
+		location = time.UTC
+	}
+	period := options.Period
+	if period == "" {
+		period = Month
+	}
+	topPosts := options.TopPosts
+	if topPosts < 1 {
+		topPosts = DefaultTopPosts
+	}
+
+	report := &Report{Period: period, ByHour: make([]Group, 24), ByWeekday: make([]Group, 7), Top: []TopPost{}}
+	for hour := range report.ByHour {
+		report.ByHour[hour].Key = fmt.Sprintf("%02d", hour)
+	}
+	for day := range report.ByWeekday {
+		report.ByWeekday[day].Key = time.Weekday(day).String()
+	}
+	byType := map[string]*Group{}
+	byTag := map[string]*Group{}
+	byPeriod := map[string]*Group{}
+	for {
+		post, err := posts.Next()
+		if err == io.EOF {
+			break
+		}
+		// posts failing to decode still carry their id and type
+		if errors.As(err, new(*tumblr.PostDecodeError)) && post != nil {
+			report.DecodeErrors++
+			err = nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		self := post.GetSelf()
+		notes := self.NoteCount
+		report.Posts++
+		report.Notes += notes
+
+		if byType[self.Type] == nil {
+			byType[self.Type] = &Group{Key: self.Type}
+		}
+		byType[self.Type].add(notes)
+		tagged := map[string]bool{}
+		for _, tag := range self.Tags {
+			tag = strings.ToLower(tag)
+			if tagged[tag] {
+				continue
+			}
+			tagged[tag] = true
+			if byTag[tag] == nil {
+				byTag[tag] = &Group{Key: tag}
+			}
+			byTag[tag].add(notes)
+		}
+
+		published := time.Time{}
+		if self.Timestamp > 0 {
+			published = time.Unix(int64(self.Timestamp), 0).In(location)
+			if report.First.IsZero() || published.Before(report.First) {
+				report.First = published
+			}
+			if published.After(report.Last) {
+				report.Last = published
+			}
+			report.ByHour[published.Hour()].add(notes)
+			report.ByWeekday[published.Weekday()].add(notes)
+			key := period.key(period.start(published))
+			if byPeriod[key] == nil {
+				byPeriod[key] = &Group{Key: key}
+			}
+			byPeriod[key].add(notes)
+		}
+
+		// keep the top posts sorted by notes, most first, the post read first winning ties
+		rank := sort.Search(len(report.Top), func(i int) bool {
+			return report.Top[i].Notes < notes
+		})
+		if rank < topPosts {
+			report.Top = append(report.Top, TopPost{})
+			copy(report.Top[rank+1:], report.Top[rank:])
+			report.Top[rank] = TopPost{
+				Id:        self.Id,
+				Type:      self.Type,
+				Url:       self.PostUrl,
+				Summary:   self.Summary,
+				Tags:      self.Tags,
+				Notes:     notes,
+				Published: published,
+			}
+			if len(report.Top) > topPosts {
+				report.Top = report.Top[:topPosts]
+			}
+		}
+	}
+
+	report.ByType = sortedGroups(byType)
+	report.ByTag = sortedGroups(byTag)
+	report.OverTime = []Group{}
+	if !report.First.IsZero() {
+		end := period.start(report.Last)
+		for start := period.start(report.First); !start.After(end); start = period.next(start) {
+			key := period.key(start)
+			if group, ok := byPeriod[key]; ok {
+				report.OverTime = append(report.OverTime, *group)
+			} else {
+				report.OverTime = append(report.OverTime, Group{Key: key})
+			}
+		}
+	}
+	for _, groups := range [][]Group{report.ByType, report.ByTag, report.ByHour, report.ByWeekday, report.OverTime} {
+		setMeans(groups)
+	}
+	return report, nil
+}
@@ -0,0 +1,199 @@
+package analytics_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tumblr/tumblr.go"
+	"github.com/tumblr/tumblr.go/analytics"
+)
+
+// Posts of January and March 2024, and one without a timestamp
+const testPosts = `[
+	{"id": 1, "type": "text", "note_count": 10, "tags": ["Cats", "cats", "dogs"], "timestamp": 1704099600},
+	{"id": 2, "type": "photo", "note_count": 30, "tags": ["cats"], "timestamp": 1710538200, "post_url": "https://david.tumblr.com/post/2"},
+	{"id": 3, "type": "text", "note_count": 5, "timestamp": 1710580200},
+	{"id": 4, "type": "quote", "note_count": 30}
+]`
+
+func aggregate(t *testing.T, options analytics.Options) *analytics.Report {
+	posts, err := tumblr.ReadPosts(strings.NewReader(testPosts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := analytics.Aggregate(tumblr.IteratePosts(posts), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+// Returns the keys of groups
+func keys(groups []analytics.Group) []string {
+	keys := []string{}
+	for _, group := range groups {
+		keys = append(keys, group.Key)
+	}
+	return keys
+}
+
+func TestAggregate(t *testing.T) {
+	report := aggregate(t, analytics.Options{TopPosts: 2})
+	if report.Posts != 4 || report.Notes != 75 {
+		t.Errorf("Expected 4 posts with 75 notes, got %d with %d", report.Posts, report.Notes)
+	}
+	if !report.First.Equal(time.Unix(1704099600, 0)) || !report.Last.Equal(time.Unix(1710580200, 0)) {
+		t.Errorf("Unexpected range %s to %s", report.First, report.Last)
+	}
+	if types := keys(report.ByType); !reflect.DeepEqual(types, []string{"photo", "quote", "text"}) {
+		t.Errorf("Expected types by notes, got %v", types)
+	}
+	if text := report.ByType[2]; text.Posts != 2 || text.Notes != 15 || text.MeanNotes != 7.5 || text.MaxNotes != 10 {
+		t.Errorf("Unexpected text group %+v", text)
+	}
+	// tags are counted once per post, whatever their case
+	expectedTags := []analytics.Group{
+		{Key: "cats", Posts: 2, Notes: 40, MeanNotes: 20, MaxNotes: 30},
+		{Key: "dogs", Posts: 1, Notes: 10, MeanNotes: 10, MaxNotes: 10},
+	}
+	if !reflect.DeepEqual(report.ByTag, expectedTags) {
+		t.Errorf("Expected tags %+v, got %+v", expectedTags, report.ByTag)
+	}
+	if len(report.ByHour) != 24 || report.ByHour[9].Posts != 2 || report.ByHour[9].Notes != 15 || report.ByHour[21].Notes != 30 {
+		t.Errorf("Unexpected hours %+v", report.ByHour)
+	}
+	if days := report.ByWeekday; days[time.Monday].Notes != 10 || days[time.Friday].Notes != 30 || days[time.Saturday].Key != "Saturday" {
+		t.Errorf("Unexpected days of week %+v", days)
+	}
+	// February had no posts
+	if periods := keys(report.OverTime); !reflect.DeepEqual(periods, []string{"2024-01", "2024-02", "2024-03"}) {
+		t.Errorf("Expected every month, got %v", periods)
+	}
+	if report.OverTime[1].Posts != 0 || report.OverTime[2].Notes != 35 {
+		t.Errorf("Unexpected months %+v", report.OverTime)
+	}
+	// the post read first wins the tie
+	if len(report.Top) != 2 || report.Top[0].Id != 2 || report.Top[1].Id != 4 || report.Top[0].Url != "https://david.tumblr.com/post/2" {
+		t.Errorf("Unexpected top posts %+v", report.Top)
+	}
+}
+
+func TestAggregatePeriods(t *testing.T) {
+	location := time.FixedZone("UTC+5", 5*60*60)
+	cases := []struct {
+		period analytics.Period
+		first  string
+		count  int
+	}{
+		{analytics.Day, "2024-01-01", 76},
+		{analytics.Week, "2024-W01", 11},
+		{analytics.Quarter, "2024-Q1", 1},
+		{analytics.Year, "2024", 1},
+	}
+	for _, c := range cases {
+		periods := aggregate(t, analytics.Options{Period: c.period, Location: location}).OverTime
+		if len(periods) != c.count || periods[0].Key != c.first {
+			t.Errorf("Expected %d %s periods from %s, got %v", c.count, c.period, c.first, keys(periods))
+		}
+	}
+	// 21:30 UTC on Friday is 02:30 on Saturday five hours east
+	report := aggregate(t, analytics.Options{Location: location})
+	if report.ByHour[2].Notes != 30 || report.ByWeekday[time.Saturday].Notes != 35 {
+		t.Errorf("Expected times in the location, got %+v", report.ByWeekday)
+	}
+}
+
+func TestAggregateKeepsPostsFailingToDecode(t *testing.T) {
+	posts, _ := tumblr.ReadPosts(strings.NewReader(`[
+		{"id": 1, "type": "photo", "note_count": 7, "tags": ["cats"], "photos": 5},
+		{"id": 2, "type": "text", "note_count": 3}
+	]`))
+	report, err := analytics.Aggregate(tumblr.IteratePosts(posts), analytics.Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if report.Posts != 2 || report.Notes != 3 || report.DecodeErrors != 0 || len(report.ByType) != 2 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+
+	// a PostIterator reporting the failure, as PostPager does
+	report, err = analytics.Aggregate(&failingIterator{posts: posts}, analytics.Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if report.Posts != 2 || report.Notes != 3 || report.DecodeErrors != 1 || len(report.ByType) != 2 {
+		t.Errorf("Expected the raw post to be counted, got %+v", report)
+	}
+}
+
+// Iterates over posts, reporting the first one as failing to decode
+type failingIterator struct {
+	posts []tumblr.PostInterface
+	index int
+}
+
+func (f *failingIterator) Next() (tumblr.PostInterface, error) {
+	if f.index >= len(f.posts) {
+		return nil, io.EOF
+	}
+	post := f.posts[f.index]
+	f.index++
+	if f.index == 1 {
+		return post, &tumblr.PostDecodeError{Index: 0, Id: post.GetSelf().Id, Type: "photo", Err: errors.New("Invalid photos")}
+	}
+	return post, nil
+}
+
+func TestWriteCSV(t *testing.T) {
+	report := aggregate(t, analytics.Options{TopPosts: 1})
+	buffer := &bytes.Buffer{}
+	if err := report.WriteCSV(buffer, analytics.ByTag); err != nil {
+		t.Fatal(err)
+	}
+	expected := "tag,posts,notes,mean_notes,max_notes\ncats,2,40,20.00,30\ndogs,1,10,10.00,10\n"
+	if buffer.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buffer.String())
+	}
+	buffer.Reset()
+	if err := report.WriteCSV(buffer, analytics.Top); err != nil {
+		t.Fatal(err)
+	}
+	expected = "rank,id,type,notes,published,url,tags\n1,2,photo,30,2024-03-15T21:30:00Z,https://david.tumblr.com/post/2,cats\n"
+	if buffer.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buffer.String())
+	}
+	// tags may hold spaces, so they are separated by commas and quoted
+	buffer.Reset()
+	report = &analytics.Report{Top: []analytics.TopPost{{Id: 5, Type: "text", Tags: []string{"big cats", "dogs"}}}}
+	if err := report.WriteCSV(buffer, analytics.Top); err != nil {
+		t.Fatal(err)
+	}
+	expected = "rank,id,type,notes,published,url,tags\n1,5,text,0,,,\"big cats,dogs\"\n"
+	if buffer.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buffer.String())
+	}
+	if err := report.WriteCSV(buffer, "unknown"); err == nil {
+		t.Error("Expected an unknown dimension to fail")
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	report := aggregate(t, analytics.Options{})
+	buffer := &bytes.Buffer{}
+	if err := report.WriteJSON(buffer); err != nil {
+		t.Fatal(err)
+	}
+	decoded := &analytics.Report{}
+	if err := json.Unmarshal(buffer.Bytes(), decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Posts != 4 || !reflect.DeepEqual(decoded.ByTag, report.ByTag) || len(decoded.Top) != 4 || decoded.Period != analytics.Month {
+		t.Errorf("Expected the report to round trip, got %+v", decoded)
+	}
+}
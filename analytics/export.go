@@ -0,0 +1,89 @@
+package analytics
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dimension is a breakdown of a Report written by WriteCSV.
+type Dimension string
+
+const (
+	ByType    Dimension = "type"
+	ByTag     Dimension = "tag"
+	ByHour    Dimension = "hour"
+	ByWeekday Dimension = "weekday"
+	OverTime  Dimension = "period"
+	Top       Dimension = "top"
+)
+
+// WriteJSON writes the whole report as an indented JSON document.
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// Returns the groups of a dimension
+func (r *Report) groups(dimension Dimension) ([]Group, error) {
+	switch dimension {
+	case ByType:
+		return r.ByType, nil
+	case ByTag:
+		return r.ByTag, nil
+	case ByHour:
+		return r.ByHour, nil
+	case ByWeekday:
+		return r.ByWeekday, nil
+	case OverTime:
+		return r.OverTime, nil
+	}
+	return nil, fmt.Errorf("Unknown dimension %s", dimension)
+}
+
+// WriteCSV writes one breakdown of the report as CSV with a header row. Groups are written as the dimension
+// followed by posts, notes, mean_notes and max_notes; top posts as rank, id, type, notes, published, url and
+// comma separated tags.
+func (r *Report) WriteCSV(w io.Writer, dimension Dimension) error {
+	writer := csv.NewWriter(w)
+	if dimension == Top {
+		writer.Write([]string{"rank", "id", "type", "notes", "published", "url", "tags"})
+		for i, post := range r.Top {
+			published := ""
+			if !post.Published.IsZero() {
+				published = post.Published.Format(time.RFC3339)
+			}
+			writer.Write([]string{
+				strconv.Itoa(i + 1),
+				strconv.FormatUint(post.Id, 10),
+				post.Type,
+				strconv.FormatUint(post.Notes, 10),
+				published,
+				post.Url,
+				strings.Join(post.Tags, ","),
+			})
+		}
+	} else {
+		groups, err := r.groups(dimension)
+		if err != nil {
+			return err
+		}
+		writer.Write([]string{string(dimension), "posts", "notes", "mean_notes", "max_notes"})
+		for _, group := range groups {
+			writer.Write([]string{
+				group.Key,
+				strconv.Itoa(group.Posts),
+				strconv.FormatUint(group.Notes, 10),
+				strconv.FormatFloat(group.MeanNotes, 'f', 2, 64),
+				strconv.FormatUint(group.MaxNotes, 10),
+			})
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,491 @@
+// Package archive backs up Tumblr blogs to disk and restores them.
+//
+// An archive is a directory holding one JSON Lines file per exported section (posts.jsonl, drafts.jsonl,
+// queue.jsonl and submissions.jsonl), a media directory with the photos, videos and audio the posts reference,
+// and a progress.json file recording how far the export got so it can resume after an interruption.
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/tumblr/tumblr.go"
+)
+
+// Section is a set of a blog's posts.
+type Section string
+
+const (
+	Posts       Section = "posts"
+	Drafts      Section = "drafts"
+	Queue       Section = "queue"
+	Submissions Section = "submissions"
+)
+
+// AllSections lists every section, in export order. Only the posts section is public; the others need the
+// client to be authorized as a member of the blog.
+var AllSections = []Section{Posts, Drafts, Queue, Submissions}
+
+// Returns the function listing the posts of a section
+func (s Section) fetcher() (func(tumblr.ClientInterface, string, url.Values) (*tumblr.Posts, error), error) {
+	switch s {
+	case Posts:
+		return tumblr.GetPosts, nil
+	case Drafts:
+		return tumblr.GetDrafts, nil
+	case Queue:
+		return tumblr.GetQueue, nil
+	case Submissions:
+		return tumblr.GetSubmissions, nil
+	}
+	return nil, fmt.Errorf("Unknown section %q", s)
+}
+
+// Returns the param the section is paged with, so that posts published or deleted while exporting do not
+// shift the pages: before_id for drafts and before, a timestamp, for published posts. The queue and
+// submissions can only be paged with offset.
+func (s Section) pageParam() string {
+	switch s {
+	case Drafts:
+		return "before_id"
+	case Posts:
+		return "before"
+	}
+	return "offset"
+}
+
+// File name of the section's posts within an archive
+func (s Section) fileName() string {
+	return string(s) + ".jsonl"
+}
+
+// Name of the progress file within an archive
+const progressFile = "progress.json"
+
+// Name of the media directory within an archive
+const mediaDir = "media"
+
+// Media is a file referenced by a post.
+type Media struct {
+	Url string `json:"url"`
+	// Location of the download, relative to the archive directory; empty if it failed
+	Path  string `json:"path,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Record is a line of an archive's JSON Lines files.
+type Record struct {
+	Section Section `json:"section"`
+	// The decoded post encoded again; posts of unknown types are kept as the API returned them
+	Post  json.RawMessage `json:"post"`
+	Media []Media         `json:"media,omitempty"`
+}
+
+// How far the export of a section got
+type sectionProgress struct {
+	Offset int `json:"offset"`
+	// Id and timestamp of the last post of the last exported page, from which sections paged by id or
+	// timestamp resume
+	LastId        uint64 `json:"last_id"`
+	LastTimestamp uint64 `json:"last_timestamp,omitempty"`
+	Done          bool   `json:"done"`
+}
+
+// ExportStats counts what an export wrote.
+type ExportStats struct {
+	Posts        map[Section]int
+	Media        int
+	MediaFailed  int
+	DecodeErrors int
+}
+
+// Exporter writes a blog's posts to an archive directory.
+type Exporter struct {
+	Client tumblr.ClientInterface
+	Blog   string
+	Dir    string
+	// Sections to export; defaults to Posts only
+	Sections []Section
+	// Number of posts requested per page; defaults to 20
+	PageSize int
+	// Skip downloading media
+	SkipMedia bool
+	// Client used for media downloads; defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// NewExporter creates an Exporter writing the public posts of blog to dir.
+func NewExporter(client tumblr.ClientInterface, blog, dir string) *Exporter {
+	return &Exporter{Client: client, Blog: blog, Dir: dir}
+}
+
+// Export walks every page of each section, appending the posts not yet in the archive and downloading their
+// media. Progress is saved after every page: if an export is interrupted, running it again against the same
+// directory resumes after the last exported post. Sections that were completed are not exported again, so a
+// fresh backup needs a fresh directory.
+//
+// Posts which fail to decode are still archived as the API returned them; only media extraction is skipped.
+func (e *Exporter) Export(ctx context.Context) (*ExportStats, error) {
+	if e.Blog == "" {
+		return nil, errors.New("No blog name provided")
+	}
+	if err := os.MkdirAll(e.Dir, 0755); err != nil {
+		return nil, err
+	}
+	progress, err := e.loadProgress()
+	if err != nil {
+		return nil, err
+	}
+	sections := e.Sections
+	if len(sections) < 1 {
+		sections = []Section{Posts}
+	}
+	stats := &ExportStats{Posts: map[Section]int{}}
+	for _, section := range sections {
+		if progress[section] == nil {
+			progress[section] = &sectionProgress{}
+		}
+		if progress[section].Done {
+			continue
+		}
+		if err = e.exportSection(ctx, section, progress, stats); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// Exports the posts of one section
+func (e *Exporter) exportSection(ctx context.Context, section Section, progress map[Section]*sectionProgress, stats *ExportStats) error {
+	fetch, err := section.fetcher()
+	if err != nil {
+		return err
+	}
+	pageSize := e.PageSize
+	if pageSize < 1 {
+		pageSize = 20
+	}
+	fileName := filepath.Join(e.Dir, section.fileName())
+	exported, err := repairRecords(fileName)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	state := progress[section]
+	// sections paged by offset shift when posts are published or deleted since the interruption; going back
+	// a page and skipping exported posts covers up to a page of deletions. Other sections carry on from the
+	// last exported post.
+	offset := state.Offset - pageSize
+	if offset < 0 {
+		offset = 0
+	}
+	for {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		params := url.Values{}
+		switch section.pageParam() {
+		case "before_id":
+			if state.LastId > 0 {
+				params.Set("before_id", strconv.FormatUint(state.LastId, 10))
+			}
+		case "before":
+			// posts published in the same second as the last one may straddle the page boundary, so the
+			// page starts with that second, and posts exported already are skipped. More than a page of posts
+			// published in a single second cannot be paged past.
+			if state.LastTimestamp > 0 {
+				params.Set("before", strconv.FormatUint(state.LastTimestamp+1, 10))
+			}
+		default:
+			params.Set("offset", strconv.Itoa(offset))
+		}
+		params.Set("limit", strconv.Itoa(pageSize))
+		page, err := fetch(e.Client, e.Blog, params)
+		if err != nil {
+			return err
+		}
+		posts, err := page.All()
+		if err != nil {
+			return err
+		}
+		stats.DecodeErrors += len(page.DecodeErrors())
+		if len(posts) < 1 {
+			break
+		}
+		buffer := &bytes.Buffer{}
+		fresh := 0
+		for _, post := range posts {
+			self := post.GetSelf()
+			if exported[self.Id] {
+				continue
+			}
+			record, err := e.record(ctx, section, post)
+			if err != nil {
+				return err
+			}
+			for _, media := range record.Media {
+				if media.Error != "" {
+					stats.MediaFailed++
+				} else {
+					stats.Media++
+				}
+			}
+			line, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+			buffer.Write(line)
+			buffer.WriteByte('\n')
+			exported[self.Id] = true
+			stats.Posts[section]++
+			fresh++
+		}
+		if _, err = file.Write(buffer.Bytes()); err != nil {
+			return err
+		}
+		if err = file.Sync(); err != nil {
+			return err
+		}
+		offset += len(posts)
+		last := posts[len(posts)-1].GetSelf()
+		state.Offset = offset
+		state.LastId = last.Id
+		state.LastTimestamp = last.Timestamp
+		if err = e.saveProgress(progress); err != nil {
+			return err
+		}
+		// a page overlapping the previous one by a second only holds exported posts once the section is done
+		if section.pageParam() == "before" && fresh < 1 {
+			break
+		}
+	}
+	state.Done = true
+	return e.saveProgress(progress)
+}
+
+// Builds the record of a post, downloading its media
+func (e *Exporter) record(ctx context.Context, section Section, post tumblr.PostInterface) (*Record, error) {
+	record := &Record{Section: section}
+	var err error
+	if raw, ok := post.(*tumblr.RawPost); ok {
+		record.Post = raw.Raw
+	} else if record.Post, err = json.Marshal(post); err != nil {
+		return nil, err
+	}
+	if e.SkipMedia {
+		return record, nil
+	}
+	id := post.GetSelf().Id
+	for i, mediaUrl := range MediaUrls(post) {
+		media := Media{Url: mediaUrl}
+		media.Path, err = e.download(ctx, mediaUrl, id, i)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			media.Error = err.Error()
+		}
+		record.Media = append(record.Media, media)
+	}
+	return record, nil
+}
+
+// MediaUrls returns the URLs of the media a post carries: the original size of each photo of a PhotoPost,
+// the video of a VideoPost and the audio of an AudioPost, then the largest rendition of each image, video and
+// audio block of its NPF content.
+func MediaUrls(post tumblr.PostInterface) []string {
+	urls := []string{}
+	switch p := post.(type) {
+	case *tumblr.PhotoPost:
+		for _, photo := range p.Photos {
+			if photo.OriginalSize.Url != "" {
+				urls = append(urls, photo.OriginalSize.Url)
+			}
+		}
+	case *tumblr.VideoPost:
+		if p.VideoUrl != "" {
+			urls = append(urls, p.VideoUrl)
+		}
+	case *tumblr.AudioPost:
+		if p.AudioUrl != "" {
+			urls = append(urls, p.AudioUrl)
+		}
+	}
+	for _, block := range post.GetSelf().Content {
+		switch block.Type {
+		case "image", "video", "audio":
+			if mediaUrl := largestMedia(block.Media); mediaUrl != "" && !slices.Contains(urls, mediaUrl) {
+				urls = append(urls, mediaUrl)
+			}
+		}
+	}
+	return urls
+}
+
+// Returns the URL of the widest rendition of a media file, or the first one when none has a width
+func largestMedia(media tumblr.MediaList) string {
+	largest := ""
+	width := uint32(0)
+	for _, m := range media {
+		if m.Url != "" && (largest == "" || m.Width > width) {
+			largest, width = m.Url, m.Width
+		}
+	}
+	return largest
+}
+
+// Returns the path, relative to the archive, of the index-th media file of a post
+func mediaPath(mediaUrl string, postId uint64, index int) string {
+	ext := ""
+	if parsed, err := url.Parse(mediaUrl); err == nil {
+		ext = path.Ext(parsed.Path)
+	}
+	return filepath.Join(mediaDir, strconv.FormatUint(postId, 10), strconv.Itoa(index)+ext)
+}
+
+// Downloads a media file into the archive, unless a previous run already did
+func (e *Exporter) download(ctx context.Context, mediaUrl string, postId uint64, index int) (string, error) {
+	relative := mediaPath(mediaUrl, postId, index)
+	target := filepath.Join(e.Dir, relative)
+	if _, err := os.Stat(target); err == nil {
+		return relative, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, mediaUrl, nil)
+	if err != nil {
+		return "", err
+	}
+	client := e.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Unexpected status %d downloading %s", res.StatusCode, mediaUrl)
+	}
+	// written aside and renamed, so an interrupted download is not mistaken for a complete one
+	temp, err := os.CreateTemp(filepath.Dir(target), ".download-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err = io.Copy(temp, res.Body); err == nil {
+		err = temp.Close()
+	} else {
+		temp.Close()
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), target)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return "", err
+	}
+	return relative, nil
+}
+
+// Reads the ids of the posts already in a section file. A line cut short by an interruption is truncated away.
+func repairRecords(fileName string) (map[uint64]bool, error) {
+	exported := map[uint64]bool{}
+	file, err := os.Open(fileName)
+	if os.IsNotExist(err) {
+		return exported, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	valid := int64(0)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		record := struct {
+			Post struct {
+				Id uint64 `json:"id"`
+			} `json:"post"`
+		}{}
+		if json.Unmarshal(line, &record) != nil {
+			break
+		}
+		exported[record.Post.Id] = true
+		valid += int64(len(line))
+	}
+	if info, err := file.Stat(); err == nil && info.Size() > valid {
+		return exported, os.Truncate(fileName, valid)
+	}
+	return exported, nil
+}
+
+// Loads the progress of a previous export, if any
+func (e *Exporter) loadProgress() (map[Section]*sectionProgress, error) {
+	progress := map[Section]*sectionProgress{}
+	b, err := os.ReadFile(filepath.Join(e.Dir, progressFile))
+	if os.IsNotExist(err) {
+		return progress, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return progress, json.Unmarshal(b, &progress)
+}
+
+// Saves the progress of the export, atomically
+func (e *Exporter) saveProgress(progress map[Section]*sectionProgress) error {
+	b, err := json.MarshalIndent(progress, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(e.Dir, progressFile), b)
+}
+
+// Writes a file aside and renames it into place, so that neither a crash nor another run writing the same
+// file leaves it partly written
+func writeFileAtomic(target string, b []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+"-*")
+	if err != nil {
+		return err
+	}
+	if _, err = temp.Write(b); err == nil {
+		err = temp.Close()
+	} else {
+		temp.Close()
+	}
+	if err == nil {
+		err = os.Chmod(temp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), target)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+	}
+	return err
+}
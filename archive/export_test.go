@@ -0,0 +1,268 @@
+package archive_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tumblr/tumblr.go"
+	"github.com/tumblr/tumblr.go/archive"
+	"github.com/tumblr/tumblr.go/tumblrtest"
+	"github.com/tumblr/tumblr.go/tumblrtest/fakeserver"
+)
+
+// Adds n text posts in the given state to the user's blog
+func addPosts(t *testing.T, server *fakeserver.Server, n int, state string) []uint64 {
+	ids := []uint64{}
+	for i := 0; i < n; i++ {
+		id, err := server.AddPost("david", url.Values{"type": {"text"}, "body": {"post"}, "state": {state}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Reads the records of an archive's section file
+func readRecords(t *testing.T, fileName string) []archive.Record {
+	file, err := os.Open(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records := []archive.Record{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		record := archive.Record{}
+		if err = json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("Invalid record %s: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// Returns the id of an archived post
+func recordId(t *testing.T, record archive.Record) uint64 {
+	post := tumblr.MiniPost{}
+	if err := json.Unmarshal(record.Post, &post); err != nil {
+		t.Fatal(err)
+	}
+	return post.Id
+}
+
+func TestExportSections(t *testing.T) {
+	server := fakeserver.New("david")
+	defer server.Close()
+	published := addPosts(t, server, 25, "published")
+	drafts := addPosts(t, server, 12, "draft")
+
+	exporter := archive.NewExporter(server.Client(), "david", t.TempDir())
+	exporter.Sections = archive.AllSections
+	exporter.PageSize = 10
+	stats, err := exporter.Export(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if stats.Posts[archive.Posts] != 25 || stats.Posts[archive.Drafts] != 12 || stats.Posts[archive.Queue] != 0 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	records := readRecords(t, filepath.Join(exporter.Dir, "posts.jsonl"))
+	if len(records) != 25 {
+		t.Fatalf("Expected 25 archived posts, got %d", len(records))
+	}
+	for i, record := range records {
+		// newest first
+		if id := recordId(t, record); id != published[24-i] || record.Section != archive.Posts {
+			t.Errorf("Unexpected record %d: %d in %s", i, id, record.Section)
+		}
+	}
+	// drafts span two pages, read with before_id
+	records = readRecords(t, filepath.Join(exporter.Dir, "drafts.jsonl"))
+	if len(records) != 12 {
+		t.Fatalf("Expected 12 archived drafts, got %d", len(records))
+	}
+	for i, record := range records {
+		if id := recordId(t, record); id != drafts[11-i] {
+			t.Errorf("Unexpected draft %d: %d", i, id)
+		}
+	}
+}
+
+// Fails every request after the first few
+type interruptedClient struct {
+	tumblr.ClientInterface
+	remaining int
+}
+
+func (c *interruptedClient) GetWithParams(endpoint string, params url.Values) (tumblr.Response, error) {
+	if c.remaining < 1 {
+		return tumblr.Response{}, errors.New("Connection reset")
+	}
+	c.remaining--
+	return c.ClientInterface.GetWithParams(endpoint, params)
+}
+
+func TestExportResumes(t *testing.T) {
+	server := fakeserver.New("david")
+	defer server.Close()
+	addPosts(t, server, 25, "published")
+	dir := t.TempDir()
+
+	exporter := archive.NewExporter(&interruptedClient{ClientInterface: server.Client(), remaining: 2}, "david", dir)
+	exporter.PageSize = 10
+	if _, err := exporter.Export(context.Background()); err == nil {
+		t.Fatal("Expected the interrupted export to fail")
+	}
+	fileName := filepath.Join(dir, "posts.jsonl")
+	// pages overlap by the second of their last post
+	if records := readRecords(t, fileName); len(records) != 19 {
+		t.Fatalf("Expected two pages to be archived, got %d posts", len(records))
+	}
+	// a line cut short by a crash
+	file, _ := os.OpenFile(fileName, os.O_WRONLY|os.O_APPEND, 0644)
+	file.WriteString(`{"section":"posts","po`)
+	file.Close()
+	// posts published in the meantime are newer than the saved position, and do not shift it
+	addPosts(t, server, 3, "published")
+
+	exporter.Client = server.Client()
+	stats, err := exporter.Export(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error resuming %v", err)
+	}
+	if stats.Posts[archive.Posts] != 6 {
+		t.Errorf("Expected the 6 remaining posts to be exported, got %d", stats.Posts[archive.Posts])
+	}
+	seen := map[uint64]bool{}
+	for _, record := range readRecords(t, fileName) {
+		id := recordId(t, record)
+		if seen[id] {
+			t.Errorf("Post %d was archived twice", id)
+		}
+		seen[id] = true
+	}
+	if len(seen) != 25 {
+		t.Errorf("Expected 25 archived posts, got %d", len(seen))
+	}
+
+	// a completed export has nothing left to do
+	if stats, err = exporter.Export(context.Background()); err != nil || stats.Posts[archive.Posts] != 0 {
+		t.Errorf("Unexpected re-export %+v, %v", stats, err)
+	}
+}
+
+func TestExportDraftsResume(t *testing.T) {
+	server := fakeserver.New("david")
+	defer server.Close()
+	addPosts(t, server, 25, "draft")
+	dir := t.TempDir()
+
+	exporter := archive.NewExporter(&interruptedClient{ClientInterface: server.Client(), remaining: 2}, "david", dir)
+	exporter.Sections = []archive.Section{archive.Drafts}
+	exporter.PageSize = 10
+	if _, err := exporter.Export(context.Background()); err == nil {
+		t.Fatal("Expected the interrupted export to fail")
+	}
+	// drafts written in the meantime are newer than the saved position, and do not shift it
+	addPosts(t, server, 3, "draft")
+
+	exporter.Client = server.Client()
+	stats, err := exporter.Export(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error resuming %v", err)
+	}
+	if stats.Posts[archive.Drafts] != 5 {
+		t.Errorf("Expected the 5 remaining drafts to be exported, got %d", stats.Posts[archive.Drafts])
+	}
+	seen := map[uint64]bool{}
+	for _, record := range readRecords(t, filepath.Join(dir, "drafts.jsonl")) {
+		id := recordId(t, record)
+		if seen[id] {
+			t.Errorf("Draft %d was archived twice", id)
+		}
+		seen[id] = true
+	}
+	if len(seen) != 25 {
+		t.Errorf("Expected 25 archived drafts, got %d", len(seen))
+	}
+}
+
+func TestExportMedia(t *testing.T) {
+	media := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.mp3" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("media " + r.URL.Path))
+	}))
+	defer media.Close()
+
+	client := tumblrtest.NewClient()
+	client.On(http.MethodGet, "/blog/david.tumblr.com/posts").WithParam("before", "101").RespondResult(map[string]interface{}{"posts": []interface{}{}})
+	client.On(http.MethodGet, "/blog/david.tumblr.com/posts").RespondResult(map[string]interface{}{
+		"total_posts": 4,
+		"posts": []interface{}{
+			map[string]interface{}{"id": 4, "type": "blocks", "timestamp": 400, "content": []interface{}{
+				map[string]interface{}{"type": "text", "text": "Look"},
+				map[string]interface{}{"type": "image", "media": []interface{}{
+					map[string]interface{}{"url": media.URL + "/d_500.jpg", "width": 500},
+					map[string]interface{}{"url": media.URL + "/d_1280.jpg", "width": 1280},
+				}},
+				map[string]interface{}{"type": "video", "media": map[string]interface{}{"url": media.URL + "/e.mp4"}},
+			}},
+			map[string]interface{}{"id": 3, "type": "photo", "timestamp": 300, "photos": []interface{}{
+				map[string]interface{}{"original_size": map[string]interface{}{"url": media.URL + "/a.jpg"}},
+				map[string]interface{}{"original_size": map[string]interface{}{"url": media.URL + "/b.png"}},
+			}},
+			map[string]interface{}{"id": 2, "type": "video", "timestamp": 200, "video_url": media.URL + "/c.mp4"},
+			map[string]interface{}{"id": 1, "type": "audio", "timestamp": 100, "audio_url": media.URL + "/missing.mp3"},
+		},
+	})
+
+	dir := t.TempDir()
+	stats, err := archive.NewExporter(client, "david", dir).Export(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if stats.Media != 5 || stats.MediaFailed != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	records := readRecords(t, filepath.Join(dir, "posts.jsonl"))
+	if len(records) != 4 {
+		t.Fatalf("Expected 4 records, got %d", len(records))
+	}
+	// the widest rendition of NPF images is downloaded
+	if npf := records[0].Media; len(npf) != 2 || npf[0].Url != media.URL+"/d_1280.jpg" || npf[1].Url != media.URL+"/e.mp4" {
+		t.Errorf("Unexpected media of the NPF post %v", npf)
+	}
+	expected := [][]string{
+		{filepath.Join("media", "4", "0.jpg"), filepath.Join("media", "4", "1.mp4")},
+		{filepath.Join("media", "3", "0.jpg"), filepath.Join("media", "3", "1.png")},
+		{filepath.Join("media", "2", "0.mp4")},
+	}
+	for i, paths := range expected {
+		if len(records[i].Media) != len(paths) {
+			t.Fatalf("Unexpected media of record %d: %v", i, records[i].Media)
+		}
+		for j, path := range paths {
+			if records[i].Media[j].Path != path {
+				t.Errorf("Expected media at %s, got %v", path, records[i].Media[j])
+			}
+			if b, err := os.ReadFile(filepath.Join(dir, path)); err != nil || len(b) == 0 {
+				t.Errorf("Expected media file %s, got %v", path, err)
+			}
+		}
+	}
+	if failed := records[3].Media; len(failed) != 1 || failed[0].Path != "" || failed[0].Error == "" {
+		t.Errorf("Expected the missing audio to be reported, got %v", failed)
+	}
+}
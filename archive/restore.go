@@ -0,0 +1,331 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tumblr/tumblr.go"
+)
+
+// Default name of the id mapping file within an archive
+const idMapFile = "id_map.json"
+
+// RestoreStats counts what a restore did.
+type RestoreStats struct {
+	Created int
+	// Posts skipped because a previous run already created them
+	Skipped int
+	// Media files left pointing at their original URL instead of being uploaded: media which was not
+	// downloaded, and any media of NPF posts, as NPF uploads need multipart requests ClientInterface cannot make
+	LinkedMedia int
+}
+
+// Restorer re-creates the posts of an archive on a blog. Restoring into a fakeserver.Server, or any other fake
+// client, is a dry run showing what would be created.
+type Restorer struct {
+	Client tumblr.ClientInterface
+	// Blog the posts are created on
+	Blog string
+	// Archive directory, as written by an Exporter
+	Dir string
+	// Sections to restore; defaults to every section found in the archive
+	Sections []Section
+	// File mapping archived post ids to created ones; defaults to id_map.json in the archive directory
+	MappingFile string
+}
+
+// NewRestorer creates a Restorer re-creating the posts archived in dir on blog.
+func NewRestorer(client tumblr.ClientInterface, blog, dir string) *Restorer {
+	return &Restorer{Client: client, Blog: blog, Dir: dir}
+}
+
+// An archived post to restore
+type restoreItem struct {
+	record Record
+	post   tumblr.PostInterface
+}
+
+// Restore creates the archived posts in chronological order, keeping their tags, dates and states, and
+// uploading their downloaded media. The id of each created post is recorded in the mapping file as soon as it
+// is created; running Restore again after a failure skips the posts already created. Posts are restored at
+// least once rather than exactly once: a post created just before a crash, or whose creation succeeded but
+// whose response was lost, is not in the mapping yet and is created again by the next run.
+//
+// Submissions are restored as drafts, and answers, which cannot be created through the API, as text posts.
+// NPF posts are created with their content as archived, so their media keeps its original URLs; see
+// RestoreStats.LinkedMedia.
+func (r *Restorer) Restore() (*RestoreStats, error) {
+	if r.Blog == "" {
+		return nil, errors.New("No blog name provided")
+	}
+	mappingFile := r.MappingFile
+	if mappingFile == "" {
+		mappingFile = filepath.Join(r.Dir, idMapFile)
+	}
+	mapping, err := LoadIdMap(mappingFile)
+	if err != nil {
+		return nil, err
+	}
+	items, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	stats := &RestoreStats{}
+	for _, item := range items {
+		self := item.post.GetSelf()
+		if _, ok := mapping[self.Id]; ok {
+			stats.Skipped++
+			continue
+		}
+		ref, err := r.create(item, stats)
+		if err != nil {
+			return stats, fmt.Errorf("Unable to restore post %d: %w", self.Id, err)
+		}
+		mapping[self.Id] = ref.Id
+		if err = saveIdMap(mappingFile, mapping); err != nil {
+			return stats, err
+		}
+		stats.Created++
+	}
+	return stats, nil
+}
+
+// Reads the archived posts, oldest first
+func (r *Restorer) load() ([]restoreItem, error) {
+	sections := r.Sections
+	if len(sections) < 1 {
+		sections = AllSections
+	}
+	items := []restoreItem{}
+	for _, section := range sections {
+		file, err := os.Open(filepath.Join(r.Dir, section.fileName()))
+		if os.IsNotExist(err) && len(r.Sections) < 1 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(nil, 64*1024*1024)
+		for scanner.Scan() {
+			item := restoreItem{}
+			if err = json.Unmarshal(scanner.Bytes(), &item.record); err != nil {
+				break
+			}
+			// decoded as a list of one, through the same path as API responses
+			posts, decodeErr := tumblr.ReadPosts(bytes.NewReader(append(append([]byte{'['}, item.record.Post...), ']')))
+			if len(posts) != 1 {
+				err = fmt.Errorf("Invalid post in %s: %v", section.fileName(), decodeErr)
+				break
+			}
+			item.post = posts[0]
+			items = append(items, item)
+		}
+		if err == nil {
+			err = scanner.Err()
+		}
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i].post.GetSelf(), items[j].post.GetSelf()
+		if a.Timestamp != b.Timestamp {
+			return a.Timestamp < b.Timestamp
+		}
+		return a.Id < b.Id
+	})
+	return items, nil
+}
+
+// Returns the state a post is restored in
+func restoredState(section Section, state string) string {
+	switch {
+	case section == Submissions || state == "submission":
+		return "draft"
+	case state == "queued":
+		return "queue"
+	case state != "":
+		return state
+	case section == Drafts:
+		return "draft"
+	case section == Queue:
+		return "queue"
+	}
+	return "published"
+}
+
+// Counts the media files of NPF content, each block holding the renditions of one file
+func countNPFMedia(content []tumblr.ContentBlock) int {
+	n := 0
+	for _, block := range content {
+		if len(block.Media) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// Creates the post of an item
+func (r *Restorer) create(item restoreItem, stats *RestoreStats) (*tumblr.PostRef, error) {
+	self := item.post.GetSelf()
+	params := url.Values{}
+	params.Set("state", restoredState(item.record.Section, self.State))
+	if len(self.Tags) > 0 {
+		params.Set("tags", strings.Join(self.Tags, ","))
+	}
+	if self.Date != "" {
+		params.Set("date", self.Date)
+	}
+	if self.Slug != "" {
+		params.Set("slug", self.Slug)
+	}
+	if len(self.Content) > 0 {
+		ref, err := tumblr.CreateNPFPost(r.Client, r.Blog, self.Content, self.Layout, params)
+		if err == nil {
+			stats.LinkedMedia += countNPFMedia(self.Content)
+		}
+		return ref, err
+	}
+	if self.Format != "" {
+		params.Set("format", self.Format)
+	}
+	linked, err := r.legacyParams(item, params)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := tumblr.CreatePost(r.Client, r.Blog, params)
+	if err == nil {
+		stats.LinkedMedia += linked
+	}
+	return ref, err
+}
+
+// Sets the type specific params of a legacy post, returning how many media files are sourced from their URL
+func (r *Restorer) legacyParams(item restoreItem, params url.Values) (int, error) {
+	self := item.post.GetSelf()
+	switch p := item.post.(type) {
+	case *tumblr.TextPost:
+		params.Set("type", "text")
+		params.Set("title", p.Title)
+		params.Set("body", p.Body)
+	case *tumblr.AnswerPost:
+		params.Set("type", "text")
+		params.Set("title", p.Question)
+		params.Set("body", p.Answer)
+	case *tumblr.QuotePost:
+		params.Set("type", "quote")
+		params.Set("quote", p.Text)
+		params.Set("source", p.Source)
+	case *tumblr.LinkPost:
+		params.Set("type", "link")
+		params.Set("url", p.Url)
+		params.Set("title", p.Title)
+		params.Set("description", p.Description)
+	case *tumblr.ChatPost:
+		params.Set("type", "chat")
+		lines := make([]string, len(p.Dialog))
+		for i, line := range p.Dialog {
+			lines[i] = line.Label + " " + line.Phrase
+		}
+		params.Set("conversation", strings.Join(lines, "\n"))
+	case *tumblr.PhotoPost:
+		params.Set("type", "photo")
+		params.Set("caption", p.Caption)
+		// the API takes either uploads or sources for a post's photos, so unless every photo was downloaded
+		// they are all sourced from their URLs
+		uploads := []string{}
+		for _, photo := range p.Photos {
+			data, ok, err := r.media(item.record, photo.OriginalSize.Url)
+			if err != nil {
+				return 0, err
+			}
+			if !ok {
+				break
+			}
+			uploads = append(uploads, data)
+		}
+		if len(uploads) == len(p.Photos) {
+			params["data64"] = uploads
+			return 0, nil
+		}
+		for _, photo := range p.Photos {
+			params.Add("source", photo.OriginalSize.Url)
+		}
+		return len(p.Photos), nil
+	case *tumblr.VideoPost:
+		params.Set("type", "video")
+		params.Set("caption", p.Caption)
+		if data, ok, err := r.media(item.record, p.VideoUrl); err != nil {
+			return 0, err
+		} else if ok {
+			params.Set("data64", data)
+			return 0, nil
+		}
+		params.Set("embed", p.VideoUrl)
+		return 1, nil
+	case *tumblr.AudioPost:
+		params.Set("type", "audio")
+		params.Set("caption", p.Caption)
+		if data, ok, err := r.media(item.record, p.AudioUrl); err != nil {
+			return 0, err
+		} else if ok {
+			params.Set("data64", data)
+			return 0, nil
+		}
+		params.Set("external_url", p.AudioUrl)
+		return 1, nil
+	default:
+		return 0, fmt.Errorf("Unable to restore %s posts", self.Type)
+	}
+	return 0, nil
+}
+
+// Returns the base64 encoded contents of the media file downloaded from mediaUrl, if it was.
+// Media is sent base64 encoded since ClientInterface only carries form values.
+func (r *Restorer) media(record Record, mediaUrl string) (string, bool, error) {
+	for _, media := range record.Media {
+		if media.Url != mediaUrl || media.Path == "" {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(r.Dir, media.Path))
+		if err != nil {
+			return "", false, err
+		}
+		return base64.StdEncoding.EncodeToString(b), true, nil
+	}
+	return "", false, nil
+}
+
+// LoadIdMap reads a mapping file written by a Restorer, from archived post ids to the ids of the posts created
+// from them. A missing file is an empty mapping.
+func LoadIdMap(fileName string) (map[uint64]uint64, error) {
+	mapping := map[uint64]uint64{}
+	b, err := os.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return mapping, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mapping, json.Unmarshal(b, &mapping)
+}
+
+// Saves the id mapping, atomically
+func saveIdMap(fileName string, mapping map[uint64]uint64) error {
+	b, err := json.MarshalIndent(mapping, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(fileName, b)
+}
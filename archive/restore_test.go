@@ -0,0 +1,227 @@
+package archive_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tumblr/tumblr.go"
+	"github.com/tumblr/tumblr.go/archive"
+	"github.com/tumblr/tumblr.go/tumblrtest"
+	"github.com/tumblr/tumblr.go/tumblrtest/fakeserver"
+)
+
+// Exports a blog with posts in every state, returning the archive directory and archived ids, oldest first
+func exportFixture(t *testing.T) (string, []uint64) {
+	server := fakeserver.New("david")
+	defer server.Close()
+	ids := []uint64{}
+	for _, params := range []url.Values{
+		{"type": {"text"}, "title": {"First"}, "body": {"hello"}, "tags": {"a,b"}, "date": {"2015-03-01 12:00:00 GMT"}},
+		{"type": {"quote"}, "quote": {"To be"}, "source": {"Hamlet"}, "state": {"draft"}},
+		{"type": {"link"}, "url": {"https://example.com"}, "title": {"Example"}, "state": {"queue"}},
+		{"type": {"text"}, "title": {"Last"}, "body": {"bye"}, "state": {"private"}},
+	} {
+		id, err := server.AddPost("david", params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	exporter := archive.NewExporter(server.Client(), "david", t.TempDir())
+	exporter.Sections = archive.AllSections
+	if _, err := exporter.Export(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return exporter.Dir, ids
+}
+
+func TestRestore(t *testing.T) {
+	dir, ids := exportFixture(t)
+	target := fakeserver.New("copy")
+	defer target.Close()
+
+	stats, err := archive.NewRestorer(target.Client(), "copy", dir).Restore()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	// private posts are listed to their owner, so they are archived too
+	if stats.Created != 4 {
+		t.Errorf("Expected 4 posts to be created, got %+v", stats)
+	}
+	mapping, err := archive.LoadIdMap(filepath.Join(dir, "id_map.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// created oldest first
+	previous := uint64(0)
+	for _, id := range ids {
+		created, ok := mapping[id]
+		if !ok || created <= previous {
+			t.Fatalf("Unexpected mapping %v for archived posts %v", mapping, ids)
+		}
+		previous = created
+	}
+
+	client := target.Client()
+	posts, err := tumblr.GetPosts(client, "copy", url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, _ := posts.All()
+	if len(all) != 2 {
+		t.Fatalf("Expected a published and a private post, got %d", len(all))
+	}
+	// newest first
+	if private, ok := all[0].(*tumblr.TextPost); !ok || private.Title != "Last" || private.State != "private" {
+		t.Errorf("Unexpected restored private post %v", all[0])
+	}
+	text, ok := all[1].(*tumblr.TextPost)
+	if !ok || text.Title != "First" || text.Body != "hello" || len(text.Tags) != 2 || text.Tags[1] != "b" {
+		t.Errorf("Unexpected restored post %v", all[1])
+	}
+	if text.Date != "2015-03-01 12:00:00 GMT" || text.Timestamp != 1425211200 {
+		t.Errorf("Restored post should keep its original date, got %s (%d)", text.Date, text.Timestamp)
+	}
+	drafts, _ := tumblr.GetDrafts(client, "copy", url.Values{})
+	if quote, ok := drafts.Get(0).(*tumblr.QuotePost); !ok || quote.Source != "Hamlet" {
+		t.Errorf("Unexpected restored draft %v", drafts.Get(0))
+	}
+	queue, _ := tumblr.GetQueue(client, "copy", url.Values{})
+	if link, ok := queue.Get(0).(*tumblr.LinkPost); !ok || link.Url != "https://example.com" {
+		t.Errorf("Unexpected restored queued post %v", queue.Get(0))
+	}
+}
+
+// Fails every post creation after the first few
+type failingCreateClient struct {
+	tumblr.ClientInterface
+	remaining int
+}
+
+func (c *failingCreateClient) PostWithParams(endpoint string, params url.Values) (tumblr.Response, error) {
+	if c.remaining < 1 {
+		return tumblr.Response{}, errors.New("Connection reset")
+	}
+	c.remaining--
+	return c.ClientInterface.PostWithParams(endpoint, params)
+}
+
+func TestRestoreResumes(t *testing.T) {
+	dir, _ := exportFixture(t)
+	target := fakeserver.New("copy")
+	defer target.Close()
+
+	restorer := archive.NewRestorer(&failingCreateClient{ClientInterface: target.Client(), remaining: 1}, "copy", dir)
+	if stats, err := restorer.Restore(); err == nil || stats.Created != 1 {
+		t.Fatalf("Expected the restore to fail after one post, got %+v, %v", stats, err)
+	}
+	restorer.Client = target.Client()
+	stats, err := restorer.Restore()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if stats.Created != 3 || stats.Skipped != 1 {
+		t.Errorf("Expected the remaining posts to be created, got %+v", stats)
+	}
+}
+
+// Writes a posts.jsonl file holding a single record
+func writeRecord(t *testing.T, dir string, record archive.Record) {
+	line, err := json.Marshal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, "posts.jsonl"), append(line, '\n'), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Restores an archive holding a single post through a fake client, returning the creation params and stats
+func restoreOne(t *testing.T, dir, endpoint string) (url.Values, *archive.RestoreStats) {
+	client := tumblrtest.NewClient()
+	client.On(http.MethodPost, endpoint).RespondResult(map[string]interface{}{"id": 70})
+	stats, err := archive.NewRestorer(client, "copy", dir).Restore()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	return client.Calls()[0].Params, stats
+}
+
+func TestRestoreMedia(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "media", "7"), 0755)
+	os.WriteFile(filepath.Join(dir, "media", "7", "0.jpg"), []byte("jpeg"), 0644)
+	os.WriteFile(filepath.Join(dir, "media", "7", "1.jpg"), []byte("jpeg 2"), 0644)
+	post := json.RawMessage(`{"id": 7, "type": "photo", "caption": "Sunset", "date": "2020-01-02 03:04:05 GMT",
+		"photos": [{"original_size": {"url": "https://example.com/a.jpg"}}, {"original_size": {"url": "https://example.com/b.jpg"}}]}`)
+	writeRecord(t, dir, archive.Record{
+		Section: archive.Posts,
+		Post:    post,
+		Media: []archive.Media{
+			{Url: "https://example.com/a.jpg", Path: filepath.Join("media", "7", "0.jpg")},
+			{Url: "https://example.com/b.jpg", Path: filepath.Join("media", "7", "1.jpg")},
+		},
+	})
+	params, stats := restoreOne(t, dir, "/blog/copy.tumblr.com/post")
+	if params.Get("type") != "photo" || params.Get("caption") != "Sunset" || params.Get("date") != "2020-01-02 03:04:05 GMT" ||
+		params.Get("state") != "published" {
+		t.Errorf("Unexpected params %v", params)
+	}
+	uploads := params["data64"]
+	if len(uploads) != 2 || uploads[0] != base64.StdEncoding.EncodeToString([]byte("jpeg")) ||
+		uploads[1] != base64.StdEncoding.EncodeToString([]byte("jpeg 2")) || params.Get("source") != "" {
+		t.Errorf("Expected both downloaded photos to be uploaded, got %v", params)
+	}
+	if stats.LinkedMedia != 0 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	if mapping, _ := archive.LoadIdMap(filepath.Join(dir, "id_map.json")); mapping[7] != 70 {
+		t.Errorf("Unexpected mapping %v", mapping)
+	}
+
+	// a photo which was not downloaded has every photo sourced from its URL
+	dir = t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "media", "7"), 0755)
+	os.WriteFile(filepath.Join(dir, "media", "7", "0.jpg"), []byte("jpeg"), 0644)
+	writeRecord(t, dir, archive.Record{
+		Section: archive.Posts,
+		Post:    post,
+		Media: []archive.Media{
+			{Url: "https://example.com/a.jpg", Path: filepath.Join("media", "7", "0.jpg")},
+			{Url: "https://example.com/b.jpg", Error: "Unexpected status 404"},
+		},
+	})
+	params, stats = restoreOne(t, dir, "/blog/copy.tumblr.com/post")
+	if sources := params["source"]; len(sources) != 2 || sources[0] != "https://example.com/a.jpg" ||
+		sources[1] != "https://example.com/b.jpg" || len(params["data64"]) != 0 {
+		t.Errorf("Expected every photo to be sourced from its URL, got %v", params)
+	}
+	if stats.LinkedMedia != 2 {
+		t.Errorf("Expected both photos to be counted as linked, got %+v", stats)
+	}
+}
+
+func TestRestoreNPFMedia(t *testing.T) {
+	dir := t.TempDir()
+	writeRecord(t, dir, archive.Record{
+		Section: archive.Posts,
+		Post: json.RawMessage(`{"id": 8, "type": "blocks", "content": [{"type": "text", "text": "Look"},
+			{"type": "image", "media": [{"url": "https://example.com/a_1280.jpg"}, {"url": "https://example.com/a_500.jpg"}]},
+			{"type": "video", "media": {"url": "https://example.com/v.mp4"}}]}`),
+	})
+	params, stats := restoreOne(t, dir, "/blog/copy.tumblr.com/posts")
+	if params.Get("content") == "" || stats.Created != 1 {
+		t.Fatalf("Expected an NPF post to be created, got %v, %+v", params, stats)
+	}
+	// NPF media is not uploaded, only counted
+	if stats.LinkedMedia != 2 {
+		t.Errorf("Expected two linked media files, got %+v", stats)
+	}
+}
@@ -0,0 +1,167 @@
+package tumblr
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// Operation is a single unit of work run by RunBatch.
+type Operation struct {
+	// Identifies the operation in results and errors, e.g. "like 1234"
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// LikeOperation likes a post, see LikePost.
+func LikeOperation(client ClientInterface, postId uint64, reblogKey string) Operation {
+	return Operation{
+		Name: "like " + strconv.FormatUint(postId, 10),
+		Run: func(context.Context) error {
+			return LikePost(client, postId, reblogKey)
+		},
+	}
+}
+
+// UnlikeOperation unlikes a post, see UnlikePost.
+func UnlikeOperation(client ClientInterface, postId uint64, reblogKey string) Operation {
+	return Operation{
+		Name: "unlike " + strconv.FormatUint(postId, 10),
+		Run: func(context.Context) error {
+			return UnlikePost(client, postId, reblogKey)
+		},
+	}
+}
+
+// FollowOperation follows a blog, see Follow.
+func FollowOperation(client ClientInterface, blogName string) Operation {
+	return Operation{
+		Name: "follow " + blogName,
+		Run: func(context.Context) error {
+			return Follow(client, blogName)
+		},
+	}
+}
+
+// UnfollowOperation unfollows a blog, see Unfollow.
+func UnfollowOperation(client ClientInterface, blogName string) Operation {
+	return Operation{
+		Name: "unfollow " + blogName,
+		Run: func(context.Context) error {
+			return Unfollow(client, blogName)
+		},
+	}
+}
+
+// DeletePostOperation deletes a post, see DeletePost.
+func DeletePostOperation(client ClientInterface, blogName string, postId uint64) Operation {
+	return Operation{
+		Name: "delete " + strconv.FormatUint(postId, 10),
+		Run: func(context.Context) error {
+			return DeletePost(client, blogName, postId)
+		},
+	}
+}
+
+// BatchOptions configure RunBatch.
+type BatchOptions struct {
+	// Number of operations running at once; defaults to 1
+	Concurrency int
+	// Maximum number of operations started per second; zero means no limit
+	PerSecond float64
+	// Paces the operations instead of PerSecond, sharing its rate with whatever else it paces
+	Pacer *Pacer
+	// Stop starting operations after the first failure
+	StopOnError bool
+}
+
+// BatchResult is the outcome of one operation of a batch.
+type BatchResult struct {
+	// Position of the operation in the batch
+	Index int
+	Name  string
+	Err   error
+	// The operation was never run, because the batch was cancelled or stopped
+	Skipped bool
+}
+
+// BatchError reports the operations of a batch which failed or were skipped.
+type BatchError struct {
+	Total  int
+	Failed []BatchResult
+}
+
+// Error implements the error interface.
+func (e *BatchError) Error() string {
+	skipped := 0
+	for _, result := range e.Failed {
+		if result.Skipped {
+			skipped++
+		}
+	}
+	return fmt.Sprintf("%d of %d operations failed and %d were skipped; first failure: %s: %v",
+		len(e.Failed)-skipped, e.Total, skipped, e.Failed[0].Name, e.Failed[0].Err)
+}
+
+// Unwrap returns the errors of the failed operations.
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, len(e.Failed))
+	for i, result := range e.Failed {
+		errs[i] = result.Err
+	}
+	return errs
+}
+
+// RunBatch runs the operations with bounded concurrency and rate, returning one result per operation in the
+// order given. Once ctx is cancelled (or, with StopOnError, an operation fails) no further operations are
+// started; those are reported as skipped. If any operation failed or was skipped, a *BatchError is returned
+// along with the results.
+func RunBatch(ctx context.Context, operations []Operation, options BatchOptions) ([]BatchResult, error) {
+	concurrency := options.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	pacer := options.Pacer
+	if pacer == nil {
+		pacer = NewPacer(options.PerSecond)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]BatchResult, len(operations))
+	indices := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				result := BatchResult{Index: i, Name: operations[i].Name}
+				if err := pacer.Wait(ctx); err != nil {
+					result.Err = err
+					result.Skipped = true
+				} else if result.Err = operations[i].Run(ctx); result.Err != nil && options.StopOnError {
+					cancel()
+				}
+				results[i] = result
+			}
+		}()
+	}
+	for i := range operations {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	failed := []BatchResult{}
+	for _, result := range results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	if len(failed) > 0 {
+		return results, &BatchError{Total: len(operations), Failed: failed}
+	}
+	return results, nil
+}
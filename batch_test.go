@@ -0,0 +1,190 @@
+package tumblr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunBatchSuccess(t *testing.T) {
+	client := newTestClient("{}", nil)
+	seen := map[string]bool{}
+	mutex := sync.Mutex{}
+	client.confirmExpectedSet = func(method, path string, params url.Values) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		seen[method+" "+path+" "+params.Encode()] = true
+	}
+	ops := []Operation{
+		LikeOperation(client, 1, "a"),
+		FollowOperation(client, "staff"),
+		DeletePostOperation(client, "david", 2),
+	}
+	results, err := RunBatch(context.Background(), ops, BatchOptions{Concurrency: 3})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+	for i, result := range results {
+		if result.Index != i || result.Name != ops[i].Name || result.Err != nil || result.Skipped {
+			t.Errorf("Unexpected result %d: %+v", i, result)
+		}
+	}
+	for _, call := range []string{
+		"POST /user/like id=1&reblog_key=a",
+		"POST /user/follow url=staff.tumblr.com",
+		"POST /blog/david.tumblr.com/post/delete id=2",
+	} {
+		if !seen[call] {
+			t.Errorf("Expected call %s, got %v", call, seen)
+		}
+	}
+}
+
+func TestRunBatchBoundsConcurrency(t *testing.T) {
+	running, peak := int32(0), int32(0)
+	ops := make([]Operation, 20)
+	for i := range ops {
+		ops[i] = Operation{Name: fmt.Sprint(i), Run: func(context.Context) error {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(2 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return nil
+		}}
+	}
+	if _, err := RunBatch(context.Background(), ops, BatchOptions{Concurrency: 4}); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if peak > 4 {
+		t.Errorf("Expected at most 4 concurrent operations, saw %d", peak)
+	}
+}
+
+func TestRunBatchRateBudget(t *testing.T) {
+	ops := make([]Operation, 5)
+	for i := range ops {
+		ops[i] = Operation{Run: func(context.Context) error { return nil }}
+	}
+	start := time.Now()
+	if _, err := RunBatch(context.Background(), ops, BatchOptions{Concurrency: 5, PerSecond: 100}); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	// five starts at 10ms intervals take at least 40ms
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Expected the rate budget to pace operations, took %s", elapsed)
+	}
+}
+
+func TestRunBatchSharedPacer(t *testing.T) {
+	ops := make([]Operation, 3)
+	for i := range ops {
+		ops[i] = Operation{Run: func(context.Context) error { return nil }}
+	}
+	pacer := NewPacer(100)
+	start := time.Now()
+	done := make(chan bool)
+	go func() {
+		RunBatch(context.Background(), ops, BatchOptions{Concurrency: 3, Pacer: pacer})
+		done <- true
+	}()
+	RunBatch(context.Background(), ops, BatchOptions{Concurrency: 3, Pacer: pacer})
+	<-done
+	// six starts between both batches at 10ms intervals take at least 50ms
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected batches sharing a pacer to share its rate, took %s", elapsed)
+	}
+}
+
+func TestPacerCancellation(t *testing.T) {
+	pacer := NewPacer(0.1)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := pacer.Wait(ctx); err != nil {
+		t.Fatalf("The first start should not wait, got %v", err)
+	}
+	cancel()
+	if err := pacer.Wait(ctx); err != context.Canceled {
+		t.Fatalf("Expected the wait to end with the context, got %v", err)
+	}
+}
+
+func TestRunBatchPartialFailure(t *testing.T) {
+	failure := errors.New("Post not found")
+	ops := []Operation{
+		{Name: "ok", Run: func(context.Context) error { return nil }},
+		{Name: "bad", Run: func(context.Context) error { return failure }},
+		{Name: "ok too", Run: func(context.Context) error { return nil }},
+	}
+	results, err := RunBatch(context.Background(), ops, BatchOptions{Concurrency: 2})
+	batchErr, ok := err.(*BatchError)
+	if !ok {
+		t.Fatalf("Expected a *BatchError, got %v", err)
+	}
+	if batchErr.Total != 3 || len(batchErr.Failed) != 1 || batchErr.Failed[0].Name != "bad" {
+		t.Errorf("Unexpected batch error %+v", batchErr)
+	}
+	if !errors.Is(err, failure) {
+		t.Error("Expected the batch error to wrap the operation's error")
+	}
+	if results[0].Err != nil || results[1].Err != failure || results[2].Err != nil {
+		t.Errorf("Unexpected results %+v", results)
+	}
+}
+
+func TestRunBatchStopOnError(t *testing.T) {
+	ran := int32(0)
+	ops := make([]Operation, 5)
+	for i := range ops {
+		ops[i] = Operation{Name: fmt.Sprint(i), Run: func(context.Context) error {
+			atomic.AddInt32(&ran, 1)
+			return errors.New("Failed")
+		}}
+	}
+	results, err := RunBatch(context.Background(), ops, BatchOptions{StopOnError: true})
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if ran != 1 {
+		t.Errorf("Expected a single operation to run, ran %d", ran)
+	}
+	for _, result := range results[1:] {
+		if !result.Skipped || result.Err != context.Canceled {
+			t.Errorf("Expected operation %d to be skipped, got %+v", result.Index, result)
+		}
+	}
+}
+
+func TestRunBatchCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ops := make([]Operation, 3)
+	for i := range ops {
+		ops[i] = Operation{Run: func(context.Context) error {
+			cancel()
+			return nil
+		}}
+	}
+	results, err := RunBatch(ctx, ops, BatchOptions{})
+	batchErr, ok := err.(*BatchError)
+	if !ok {
+		t.Fatalf("Expected a *BatchError, got %v", err)
+	}
+	if results[0].Err != nil || results[0].Skipped {
+		t.Errorf("Expected the first operation to complete, got %+v", results[0])
+	}
+	if len(batchErr.Failed) != 2 || !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the remaining operations to be skipped, got %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+package tumblr
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheEntry is a cached GET response.
+type CacheEntry struct {
+	Body    []byte      `json:"body"`
+	Headers http.Header `json:"headers,omitempty"`
+	// After this time the entry must be revalidated (or refetched) before use
+	Expires time.Time `json:"expires"`
+	// Validators for conditional requests, taken from the ETag and Last-Modified headers
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// Cache stores responses for a CacheClient; implementations must be safe for concurrent use.
+type Cache interface {
+	// Get the entry stored under key
+	Get(key string) (*CacheEntry, bool)
+	// Store an entry under key, replacing any existing one
+	Set(key string, entry *CacheEntry)
+	// Remove every entry whose key starts with prefix
+	DeletePrefix(prefix string)
+}
+
+// ConditionalClient is implemented by clients able to issue conditional GET requests.
+// A CacheClient wrapping such a client revalidates expired entries using their ETag or Last-Modified value
+// instead of refetching them. notModified must be true if the API answered 304 Not Modified.
+type ConditionalClient interface {
+	ConditionalGet(endpoint string, params url.Values, headers http.Header) (response Response, notModified bool, err error)
+}
+
+// CacheClient wraps a ClientInterface, caching the responses to GET requests.
+// Any other request touching a blog (following, posting, editing, deleting...) drops the cached responses
+// for that blog as well as those of the current user.
+type CacheClient struct {
+	client ClientInterface
+	cache  Cache
+	// How long responses stay fresh, for endpoints without an entry in TTLs; zero disables caching unless the
+	// response can be revalidated
+	DefaultTTL time.Duration
+	// Per-endpoint TTLs, keyed by path with the blog identifier replaced by {blog}, e.g. "/blog/{blog}/info"
+	TTLs  map[string]time.Duration
+	mutex sync.RWMutex
+	now   func() time.Time
+}
+
+// NewCacheClient wraps the provided client, storing responses in cache.
+func NewCacheClient(client ClientInterface, cache Cache, defaultTTL time.Duration) *CacheClient {
+	return &CacheClient{
+		client:     client,
+		cache:      cache,
+		DefaultTTL: defaultTTL,
+		TTLs:       map[string]time.Duration{},
+		now:        time.Now,
+	}
+}
+
+// SetTTL sets how long responses of an endpoint stay fresh; see TTLs.
+func (c *CacheClient) SetTTL(endpoint string, ttl time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.TTLs[endpoint] = ttl
+}
+
+// Returns the blog identifier of a /blog/ path along with the path's template, or "" if there is none
+func splitBlogPath(endpoint string) (blog, template string) {
+	if !strings.HasPrefix(endpoint, "/blog/") {
+		return "", endpoint
+	}
+	rest := strings.TrimPrefix(endpoint, "/blog/")
+	if i := strings.Index(rest, "/"); i >= 0 {
+		return rest[:i], "/blog/{blog}" + rest[i:]
+	}
+	return rest, "/blog/{blog}"
+}
+
+// Key under which the response to a GET request is cached
+func cacheKey(endpoint string, params url.Values) string {
+	if len(params) < 1 {
+		return endpoint
+	}
+	return endpoint + "?" + params.Encode()
+}
+
+// Returns how long the response to endpoint stays fresh
+func (c *CacheClient) ttl(endpoint string) time.Duration {
+	_, template := splitBlogPath(endpoint)
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if ttl, ok := c.TTLs[template]; ok {
+		return ttl
+	}
+	return c.DefaultTTL
+}
+
+// Drops the cached responses a write request may have made stale
+func (c *CacheClient) invalidate(endpoint string, params url.Values) {
+	c.cache.DeletePrefix("/user/")
+	if blog, _ := splitBlogPath(endpoint); blog != "" {
+		c.cache.DeletePrefix("/blog/" + blog + "/")
+	}
+	// follows and unfollows name their blog in the params
+	if blog := params.Get("url"); blog != "" {
+		c.cache.DeletePrefix("/blog/" + normalizeBlogName(blog) + "/")
+	}
+}
+
+// Serves a GET request from the cache when possible
+func (c *CacheClient) get(endpoint string, params url.Values) (Response, error) {
+	key := cacheKey(endpoint, params)
+	now := c.now()
+	entry, found := c.cache.Get(key)
+	if found && now.Before(entry.Expires) {
+		return *NewResponse(entry.Body, entry.Headers), nil
+	}
+	var response Response
+	var err error
+	conditional, canRevalidate := c.client.(ConditionalClient)
+	if found && canRevalidate && (entry.ETag != "" || entry.LastModified != "") {
+		headers := http.Header{}
+		if entry.ETag != "" {
+			headers.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			headers.Set("If-Modified-Since", entry.LastModified)
+		}
+		var notModified bool
+		if response, notModified, err = conditional.ConditionalGet(endpoint, params, headers); err == nil && notModified {
+			refreshed := *entry
+			refreshed.Expires = now.Add(c.ttl(endpoint))
+			c.cache.Set(key, &refreshed)
+			return *NewResponse(entry.Body, entry.Headers), nil
+		}
+	} else {
+		response, err = callClient(c.client, http.MethodGet, endpoint, params)
+	}
+	if err != nil {
+		return response, err
+	}
+	c.store(key, endpoint, response, now, canRevalidate)
+	return response, nil
+}
+
+// Caches a successful response if it may be reused
+func (c *CacheClient) store(key, endpoint string, response Response, now time.Time, canRevalidate bool) {
+	if status := response.StatusCode(); status != 0 && status != http.StatusOK {
+		return
+	}
+	entry := &CacheEntry{
+		Body:         response.GetBody(),
+		Headers:      response.Headers,
+		Expires:      now.Add(c.ttl(endpoint)),
+		ETag:         response.Headers.Get("ETag"),
+		LastModified: response.Headers.Get("Last-Modified"),
+	}
+	if !entry.Expires.After(now) && !(canRevalidate && (entry.ETag != "" || entry.LastModified != "")) {
+		return
+	}
+	c.cache.Set(key, entry)
+}
+
+// Issues a write request, then drops any cached response it affects
+func (c *CacheClient) write(method, endpoint string, params url.Values) (Response, error) {
+	response, err := callClient(c.client, method, endpoint, params)
+	c.invalidate(endpoint, params)
+	return response, err
+}
+
+// Get implements ClientInterface.
+func (c *CacheClient) Get(endpoint string) (Response, error) {
+	return c.get(endpoint, nil)
+}
+
+// GetWithParams implements ClientInterface.
+func (c *CacheClient) GetWithParams(endpoint string, params url.Values) (Response, error) {
+	return c.get(endpoint, params)
+}
+
+// Post implements ClientInterface.
+func (c *CacheClient) Post(endpoint string) (Response, error) {
+	return c.write(http.MethodPost, endpoint, nil)
+}
+
+// PostWithParams implements ClientInterface.
+func (c *CacheClient) PostWithParams(endpoint string, params url.Values) (Response, error) {
+	return c.write(http.MethodPost, endpoint, params)
+}
+
+// Put implements ClientInterface.
+func (c *CacheClient) Put(endpoint string) (Response, error) {
+	return c.write(http.MethodPut, endpoint, nil)
+}
+
+// PutWithParams implements ClientInterface.
+func (c *CacheClient) PutWithParams(endpoint string, params url.Values) (Response, error) {
+	return c.write(http.MethodPut, endpoint, params)
+}
+
+// Delete implements ClientInterface.
+func (c *CacheClient) Delete(endpoint string) (Response, error) {
+	return c.write(http.MethodDelete, endpoint, nil)
+}
+
+// DeleteWithParams implements ClientInterface.
+func (c *CacheClient) DeleteWithParams(endpoint string, params url.Values) (Response, error) {
+	return c.write(http.MethodDelete, endpoint, params)
+}
@@ -0,0 +1,214 @@
+package tumblr
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// MemoryCache is an in-memory Cache holding a bounded number of entries, evicting the least recently used.
+type MemoryCache struct {
+	capacity int
+	mutex    sync.Mutex
+	order    *list.List
+	items    map[string]*list.Element
+}
+
+// An element of MemoryCache's recency list
+type memoryCacheItem struct {
+	key   string
+	entry *CacheEntry
+}
+
+// NewMemoryCache creates a MemoryCache holding at most capacity entries; a capacity below 1 means no limit.
+func NewMemoryCache(capacity int) *MemoryCache {
+	return &MemoryCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    map[string]*list.Element{},
+	}
+}
+
+// Get implements Cache.
+func (m *MemoryCache) Get(key string) (*CacheEntry, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if elem, ok := m.items[key]; ok {
+		m.order.MoveToFront(elem)
+		return elem.Value.(*memoryCacheItem).entry, true
+	}
+	return nil, false
+}
+
+// Set implements Cache.
+func (m *MemoryCache) Set(key string, entry *CacheEntry) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if elem, ok := m.items[key]; ok {
+		elem.Value.(*memoryCacheItem).entry = entry
+		m.order.MoveToFront(elem)
+		return
+	}
+	m.items[key] = m.order.PushFront(&memoryCacheItem{key: key, entry: entry})
+	for m.capacity > 0 && m.order.Len() > m.capacity {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.items, oldest.Value.(*memoryCacheItem).key)
+	}
+}
+
+// DeletePrefix implements Cache.
+func (m *MemoryCache) DeletePrefix(prefix string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for key, elem := range m.items {
+		if strings.HasPrefix(key, prefix) {
+			m.order.Remove(elem)
+			delete(m.items, key)
+		}
+	}
+}
+
+// Len returns the number of cached entries.
+func (m *MemoryCache) Len() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.order.Len()
+}
+
+// FileCache is a Cache storing each entry as a JSON file in a directory, so it survives restarts
+// and can be shared between processes. Entries are grouped in a subdirectory per blog (and one for the
+// user's endpoints), so invalidating a blog removes a single directory.
+type FileCache struct {
+	dir   string
+	mutex sync.Mutex
+}
+
+// The contents of a FileCache file; the key is kept to support DeletePrefix
+type fileCacheItem struct {
+	Key   string      `json:"key"`
+	Entry *CacheEntry `json:"entry"`
+}
+
+// NewFileCache creates a FileCache in dir, creating the directory if needed.
+func NewFileCache(dir string) (*FileCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileCache{dir: dir}, nil
+}
+
+// Returns the prefix shared by the keys stored alongside key: "/blog/{blog}/" for a blog's endpoints,
+// "/user/" for the user's, and "" for any other key
+func cacheGroup(key string) string {
+	path := key
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	if blog, _ := splitBlogPath(path); blog != "" {
+		if group := "/blog/" + blog + "/"; strings.HasPrefix(path, group) {
+			return group
+		}
+		return ""
+	}
+	if strings.HasPrefix(path, "/user/") {
+		return "/user/"
+	}
+	return ""
+}
+
+// Returns the hex encoded SHA-256 of s
+func hashName(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// Directory holding the entries of a group
+func (f *FileCache) groupDir(group string) string {
+	return filepath.Join(f.dir, hashName(group))
+}
+
+// Path of the file holding the entry for key
+func (f *FileCache) path(key string) string {
+	return filepath.Join(f.groupDir(cacheGroup(key)), hashName(key)+".json")
+}
+
+// Reads a cache file, returning nil if it cannot be read
+func readFileCacheItem(path string) *fileCacheItem {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	item := &fileCacheItem{}
+	if err = json.Unmarshal(data, item); err != nil || item.Entry == nil {
+		return nil
+	}
+	return item
+}
+
+// Get implements Cache.
+func (f *FileCache) Get(key string) (*CacheEntry, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	// hash collisions are not worth handling, but a mismatched key is still not a hit
+	if item := readFileCacheItem(f.path(key)); item != nil && item.Key == key {
+		return item.Entry, true
+	}
+	return nil, false
+}
+
+// Set implements Cache. Entries which cannot be written are silently dropped.
+func (f *FileCache) Set(key string, entry *CacheEntry) {
+	data, err := json.Marshal(fileCacheItem{Key: key, Entry: entry})
+	if err != nil {
+		return
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	path := f.path(key)
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+	// written aside and renamed so readers never see a partial entry; the temporary name is unique, as other
+	// processes may be writing the same entry
+	temp, err := os.CreateTemp(filepath.Dir(path), ".set-*")
+	if err != nil {
+		return
+	}
+	if _, err = temp.Write(data); err == nil {
+		err = temp.Close()
+	} else {
+		temp.Close()
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+	}
+}
+
+// DeletePrefix implements Cache. Prefixes naming a whole group, such as "/blog/{blog}/" or "/user/", remove
+// its directory; any other prefix reads every entry to find the matching ones.
+func (f *FileCache) DeletePrefix(prefix string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if group := cacheGroup(prefix); group != "" && group == prefix {
+		os.RemoveAll(f.groupDir(group))
+		return
+	}
+	paths, err := filepath.Glob(filepath.Join(f.dir, "*", "*.json"))
+	if err != nil {
+		return
+	}
+	for _, path := range paths {
+		if item := readFileCacheItem(path); item != nil && strings.HasPrefix(item.Key, prefix) {
+			os.Remove(path)
+		}
+	}
+}
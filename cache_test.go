@@ -0,0 +1,220 @@
+package tumblr
+
+import (
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Client counting requests, optionally able to answer conditional requests
+type countingClient struct {
+	testClient
+	gets        int
+	conditional int
+	lastHeaders http.Header
+	notModified bool
+}
+
+func newCountingClient(body string, headers http.Header) *countingClient {
+	c := &countingClient{}
+	c.response = Response{body: []byte(body), Headers: headers}
+	return c
+}
+
+func (c *countingClient) Get(endpoint string) (Response, error) {
+	c.gets++
+	return c.response, c.err
+}
+
+func (c *countingClient) GetWithParams(endpoint string, params url.Values) (Response, error) {
+	c.gets++
+	return c.response, c.err
+}
+
+type conditionalCountingClient struct {
+	*countingClient
+}
+
+func (c conditionalCountingClient) ConditionalGet(endpoint string, params url.Values, headers http.Header) (Response, bool, error) {
+	c.conditional++
+	c.lastHeaders = headers
+	if c.notModified {
+		return Response{}, true, nil
+	}
+	return c.response, false, nil
+}
+
+func newTestCacheClient(client ClientInterface, cache Cache, ttl time.Duration, now *time.Time) *CacheClient {
+	c := NewCacheClient(client, cache, ttl)
+	c.now = func() time.Time { return *now }
+	return c
+}
+
+func TestCacheClientServesFreshEntries(t *testing.T) {
+	now := time.Unix(1000, 0)
+	inner := newCountingClient(`{"meta": {"status": 200}, "response": {"blog": {"name": "b"}}}`, http.Header{})
+	client := newTestCacheClient(inner, NewMemoryCache(10), time.Minute, &now)
+	for i := 0; i < 3; i++ {
+		blog, err := GetBlogInfo(client, "b")
+		if err != nil || blog.Name != "b" {
+			t.Fatalf("Unexpected result %v, %v", blog, err)
+		}
+	}
+	if inner.gets != 1 {
+		t.Fatalf("Expected a single request, got %d", inner.gets)
+	}
+	now = now.Add(2 * time.Minute)
+	GetBlogInfo(client, "b")
+	if inner.gets != 2 {
+		t.Fatal("Expired entries should be refetched")
+	}
+}
+
+func TestCacheClientPerEndpointTTL(t *testing.T) {
+	now := time.Unix(1000, 0)
+	inner := newCountingClient(`{"meta": {"status": 200}, "response": {}}`, http.Header{})
+	client := newTestCacheClient(inner, NewMemoryCache(10), 0, &now)
+	client.SetTTL("/blog/{blog}/followers", time.Hour)
+	client.GetWithParams("/blog/b.tumblr.com/followers", url.Values{"offset": []string{"20"}})
+	client.GetWithParams("/blog/b.tumblr.com/followers", url.Values{"offset": []string{"20"}})
+	client.Get("/user/info")
+	client.Get("/user/info")
+	if inner.gets != 3 {
+		t.Fatalf("Only the followers endpoint should be cached, saw %d requests", inner.gets)
+	}
+}
+
+func TestCacheClientDoesNotCacheErrors(t *testing.T) {
+	now := time.Unix(1000, 0)
+	inner := newCountingClient(`{"meta": {"status": 404}}`, http.Header{})
+	client := newTestCacheClient(inner, NewMemoryCache(10), time.Hour, &now)
+	client.Get("/blog/b.tumblr.com/info")
+	client.Get("/blog/b.tumblr.com/info")
+	if inner.gets != 2 {
+		t.Fatal("Error responses should not be cached")
+	}
+}
+
+func TestCacheClientRevalidates(t *testing.T) {
+	now := time.Unix(1000, 0)
+	headers := http.Header{}
+	headers.Set("ETag", `"abc"`)
+	inner := conditionalCountingClient{newCountingClient(`{"meta": {"status": 200}, "response": {}}`, headers)}
+	client := newTestCacheClient(inner, NewMemoryCache(10), 0, &now)
+	client.Get("/blog/b.tumblr.com/info")
+	inner.notModified = true
+	response, err := client.Get("/blog/b.tumblr.com/info")
+	if err != nil || string(response.GetBody()) != `{"meta": {"status": 200}, "response": {}}` {
+		t.Fatal("A not modified response should be served from the cache")
+	}
+	if inner.gets != 1 || inner.conditional != 1 || inner.lastHeaders.Get("If-None-Match") != `"abc"` {
+		t.Fatalf("Expected a conditional request, saw %d gets and %d conditional", inner.gets, inner.conditional)
+	}
+}
+
+func TestCacheClientInvalidatesOnWrite(t *testing.T) {
+	now := time.Unix(1000, 0)
+	inner := newCountingClient(`{"meta": {"status": 200}, "response": {}}`, http.Header{})
+	cache := NewMemoryCache(10)
+	client := newTestCacheClient(inner, cache, time.Hour, &now)
+	client.Get("/blog/a.tumblr.com/info")
+	client.Get("/blog/b.tumblr.com/info")
+	client.Get("/user/following")
+	if cache.Len() != 3 {
+		t.Fatalf("Expected 3 entries, got %d", cache.Len())
+	}
+	DeletePost(client, "a", 1)
+	if _, ok := cache.Get("/blog/a.tumblr.com/info"); ok {
+		t.Fatal("Deleting a post should invalidate the blog")
+	}
+	if _, ok := cache.Get("/user/following"); ok {
+		t.Fatal("Writes should invalidate user endpoints")
+	}
+	Follow(client, "b")
+	if cache.Len() != 0 {
+		t.Fatal("Following should invalidate the followed blog")
+	}
+}
+
+func TestMemoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewMemoryCache(2)
+	cache.Set("a", &CacheEntry{})
+	cache.Set("b", &CacheEntry{})
+	cache.Get("a")
+	cache.Set("c", &CacheEntry{})
+	if _, ok := cache.Get("b"); ok {
+		t.Fatal("Least recently used entry should be evicted")
+	}
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatal("Recently used entry should be kept")
+	}
+}
+
+func TestFileCache(t *testing.T) {
+	cache, err := NewFileCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires := time.Unix(2000, 0).UTC()
+	cache.Set("/blog/a.tumblr.com/info", &CacheEntry{Body: []byte("a"), Expires: expires, ETag: "x"})
+	cache.Set("/blog/b.tumblr.com/info", &CacheEntry{Body: []byte("b")})
+	entry, ok := cache.Get("/blog/a.tumblr.com/info")
+	if !ok || string(entry.Body) != "a" || !entry.Expires.Equal(expires) || entry.ETag != "x" {
+		t.Fatalf("Unexpected entry %+v", entry)
+	}
+	cache.DeletePrefix("/blog/a.tumblr.com/")
+	if _, ok = cache.Get("/blog/a.tumblr.com/info"); ok {
+		t.Fatal("Entry should have been deleted")
+	}
+	if _, ok = cache.Get("/blog/b.tumblr.com/info"); !ok {
+		t.Fatal("Other entries should be kept")
+	}
+	cache.Set("/user/info", &CacheEntry{Body: []byte("u")})
+	cache.Set("/blog/b.tumblr.com/posts?limit=1", &CacheEntry{Body: []byte("p")})
+	cache.DeletePrefix("/user/")
+	if _, ok = cache.Get("/user/info"); ok {
+		t.Fatal("User entry should have been deleted")
+	}
+	// prefixes narrower than a blog are matched entry by entry
+	cache.DeletePrefix("/blog/b.tumblr.com/posts")
+	if _, ok = cache.Get("/blog/b.tumblr.com/posts?limit=1"); ok {
+		t.Fatal("Posts entry should have been deleted")
+	}
+	if _, ok = cache.Get("/blog/b.tumblr.com/info"); !ok {
+		t.Fatal("Info entry should be kept")
+	}
+}
+
+func TestFileCacheConcurrentWriters(t *testing.T) {
+	dir := t.TempDir()
+	// separate caches over one directory behave like separate processes
+	caches := []*FileCache{}
+	for i := 0; i < 4; i++ {
+		cache, err := NewFileCache(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		caches = append(caches, cache)
+	}
+	wg := sync.WaitGroup{}
+	for _, cache := range caches {
+		wg.Add(1)
+		go func(cache *FileCache) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				cache.Set("/blog/a.tumblr.com/info", &CacheEntry{Body: []byte("a")})
+			}
+		}(cache)
+	}
+	wg.Wait()
+	if entry, ok := caches[0].Get("/blog/a.tumblr.com/info"); !ok || string(entry.Body) != "a" {
+		t.Fatalf("Unexpected entry %+v", entry)
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(dir, "*", ".set-*"))
+	if len(leftovers) > 0 {
+		t.Fatalf("Temporary files left behind: %v", leftovers)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"strconv"
 )
@@ -62,3 +63,31 @@ var NoNextPageError error = errors.New("No next page.")
 
 // Error returned for a collection's Prev() invocation if no previous page is possible
 var NoPrevPageError error = errors.New("No prev page.")
+
+// Issues a request through the matching ClientInterface method; nil params select the param-less variant.
+// Client decorators funnel all eight methods through this.
+func callClient(client ClientInterface, method, endpoint string, params url.Values) (Response, error) {
+	switch method {
+	case http.MethodGet:
+		if params == nil {
+			return client.Get(endpoint)
+		}
+		return client.GetWithParams(endpoint, params)
+	case http.MethodPost:
+		if params == nil {
+			return client.Post(endpoint)
+		}
+		return client.PostWithParams(endpoint, params)
+	case http.MethodPut:
+		if params == nil {
+			return client.Put(endpoint)
+		}
+		return client.PutWithParams(endpoint, params)
+	case http.MethodDelete:
+		if params == nil {
+			return client.Delete(endpoint)
+		}
+		return client.DeleteWithParams(endpoint, params)
+	}
+	return Response{}, fmt.Errorf("Unsupported request method %s", method)
+}
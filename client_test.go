@@ -140,3 +140,16 @@ func (c *testClient) DeleteWithParams(endpoint string, params url.Values) (Respo
 	c.checkCallParams(http.MethodDelete, endpoint, params)
 	return c.response, c.err
 }
+
+func TestCallClient(t *testing.T) {
+	client := newTestClient("{}", nil)
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete} {
+		client.confirmExpectedSet = expectClientCallParams(t, "callClient", method, "/path", url.Values{"a": []string{"b"}})
+		if _, err := callClient(client, method, "/path", url.Values{"a": []string{"b"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := callClient(client, http.MethodPatch, "/path", nil); err == nil {
+		t.Fatal("Unsupported methods should error")
+	}
+}
@@ -12,10 +12,12 @@ type Dashboard struct {
 	params   url.Values
 	bySince  bool
 	byOffset bool
+	errs     PostDecodeErrors
 	Posts    []PostInterface `json:"posts"`
 }
 
 // Retreive a User's dashboard
+// Posts which fail to decode are kept as RawPosts and reported by DecodeErrors.
 func GetDashboard(client ClientInterface, params url.Values) (*Dashboard, error) {
 	if params.Get("offset") != "" && params.Get("since_id") != "" {
 		return nil, errors.New("Cannot specify both offset and since_id")
@@ -27,28 +29,25 @@ func GetDashboard(client ClientInterface, params url.Values) (*Dashboard, error)
 	}
 	result := struct {
 		Response struct {
-			Posts []MiniPost `json:"posts"`
+			Posts decodedPostList `json:"posts"`
 		} `json:"response"`
 	}{}
 	if err = json.Unmarshal(response.body, &result); err != nil {
 		return nil, err
 	}
-	minis := result.Response.Posts
-	full := struct {
-		Response Dashboard `json:"response"`
-	}{
-		Response: Dashboard{
-			client:   client,
-			params:   params,
-			byOffset: params.Get("offset") != "",
-			bySince:  params.Get("since_id") != "",
-		},
+	dashboard := &Dashboard{
+		client:   client,
+		params:   params,
+		byOffset: params.Get("offset") != "",
+		bySince:  params.Get("since_id") != "",
 	}
-	full.Response.Posts = makePostsFromMinis(minis, client)
-	if err = json.Unmarshal(response.body, &full); err != nil {
-		return nil, err
-	}
-	return &full.Response, nil
+	dashboard.Posts, dashboard.errs = result.Response.Posts.result(client)
+	return dashboard, nil
+}
+
+// DecodeErrors returns the posts of the dashboard which failed to decode, and are kept in Posts as RawPosts.
+func (d *Dashboard) DecodeErrors() PostDecodeErrors {
+	return d.errs
 }
 
 // Error generated when a Dashboard result set it attempting to change pagination methods
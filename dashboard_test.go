@@ -47,6 +47,17 @@ func TestDashboardErrorsOnBadJSON(t *testing.T) {
 	}
 }
 
+func TestDashboardKeepsPostsFailingToDecode(t *testing.T) {
+	client := newTestClient(`{"response": {"posts": [{"id": 1, "type": "quote", "text": "q"}, {"id": 2, "type": "quote", "text": 5}]}}`, nil)
+	dashboard, err := GetDashboard(client, url.Values{})
+	if err != nil || len(dashboard.Posts) != 2 {
+		t.Fatal("A post failing to decode should not fail the page", err)
+	}
+	if errs := dashboard.DecodeErrors(); len(errs) != 1 || errs[0].Id != 2 {
+		t.Fatal("The post failing to decode should be reported", errs)
+	}
+}
+
 func TestPaginateDashboardByOffset(t *testing.T) {
 	p3 := Post{PostRef: PostRef{MiniPost: MiniPost{Id: 12345}}}
 	c := newTestClient(getDashString(Post{}, Post{}, p3), nil)
@@ -0,0 +1,286 @@
+// Package feed publishes Tumblr blog posts as RSS 2.0 and Atom 1.0 documents.
+//
+// A Feed is built from a blog's metadata and its posts, then written in either format:
+//
+//	f := feed.New(blog)
+//	if err := f.AddAll(tumblr.NewPostPager(client, blog.Name, nil), 50); err != nil { ... }
+//	f.WriteRSS(w)
+package feed
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/tumblr/tumblr.go"
+)
+
+// Longest title derived from post text, in runes
+const maxTitleLength = 80
+
+// Prefix of the tag URIs identifying blogs and posts which have no URL
+const tagPrefix = "tag:tumblr.com,2007:"
+
+// Enclosure is a media file attached to an item.
+type Enclosure struct {
+	Url  string
+	Type string
+	// Size in bytes, zero when unknown
+	Length int64
+}
+
+// Item is an entry of a feed, derived from a post.
+type Item struct {
+	Id    string
+	Title string
+	Link  string
+	// HTML
+	Description string
+	Published   time.Time
+	Tags        []string
+	Enclosures  []Enclosure
+}
+
+// Feed holds a channel's metadata and its items, newest first.
+type Feed struct {
+	// Identifies the feed in Atom; defaults to Link, or a tag URI of the Author's blog
+	Id          string
+	Title       string
+	Link        string
+	Description string
+	Author      string
+	// Defaults to the newest item's publication
+	Updated time.Time
+	Items   []Item
+}
+
+// New creates an empty Feed with the metadata of a blog.
+func New(blog *tumblr.Blog) *Feed {
+	f := &Feed{
+		Title:       blog.Title,
+		Link:        blog.Url,
+		Description: blog.Description,
+		Author:      blog.Name,
+	}
+	if f.Title == "" {
+		f.Title = blog.Name
+	}
+	if blog.Updated > 0 {
+		f.Updated = time.Unix(blog.Updated, 0).UTC()
+	}
+	return f
+}
+
+// FromPosts creates a Feed with the metadata of a blog and a page of its posts.
+func FromPosts(blog *tumblr.Blog, posts *tumblr.Posts) (*Feed, error) {
+	f := New(blog)
+	all, err := posts.All()
+	if err != nil {
+		return nil, err
+	}
+	for _, post := range all {
+		f.Add(post)
+	}
+	return f, nil
+}
+
+// Add appends an item for post.
+func (f *Feed) Add(post tumblr.PostInterface) {
+	f.Items = append(f.Items, NewItem(post))
+}
+
+// AddAll appends an item for each post of the iterator, up to limit items if limit is positive. Posts which
+// fail to decode are added with their common fields.
+func (f *Feed) AddAll(posts tumblr.PostIterator, limit int) error {
+	for added := 0; limit < 1 || added < limit; {
+		post, err := posts.Next()
+		if err == io.EOF {
+			return nil
+		}
+		// posts failing to decode still carry their common fields
+		if errors.As(err, new(*tumblr.PostDecodeError)) && post != nil {
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+		if post == nil {
+			continue
+		}
+		f.Add(post)
+		added++
+	}
+	return nil
+}
+
+// Returns the Atom id of the feed, empty when there is nothing to derive it from
+func (f *Feed) id() string {
+	if f.Id != "" {
+		return f.Id
+	}
+	if f.Link != "" {
+		return f.Link
+	}
+	if f.Author != "" {
+		return tagPrefix + f.Author
+	}
+	return ""
+}
+
+// Returns when the feed was last updated
+func (f *Feed) updated() time.Time {
+	updated := f.Updated
+	for _, item := range f.Items {
+		if item.Published.After(updated) {
+			updated = item.Published
+		}
+	}
+	return updated
+}
+
+// Returns a title from the start of the text of some HTML
+func excerpt(text string) string {
+	text = strings.Join(strings.Fields(html.UnescapeString(stripTags(text))), " ")
+	if runes := []rune(text); len(runes) > maxTitleLength {
+		return strings.TrimSpace(string(runes[:maxTitleLength-1])) + "…"
+	}
+	return text
+}
+
+// Removes the markup of some HTML, leaving its text
+func stripTags(s string) string {
+	b := &strings.Builder{}
+	inTag := false
+	for _, r := range s {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>' && inTag:
+			inTag = false
+			b.WriteRune(' ')
+		case !inTag:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Guesses the MIME type of a media file from its URL, falling back to a generic type of the kind of media
+func mediaType(mediaUrl, fallback string) string {
+	if parsed, err := url.Parse(mediaUrl); err == nil {
+		if t := mime.TypeByExtension(path.Ext(parsed.Path)); t != "" {
+			return t
+		}
+	}
+	return fallback
+}
+
+// Joins the non-empty parts of a description
+func joinParts(parts ...string) string {
+	kept := []string{}
+	for _, part := range parts {
+		if part != "" {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// NewItem maps a post to a feed item. Each post type provides its title, description and enclosures:
+// quotes are quoted with their source, links point to their URL, photos are embedded and enclosed, audio and
+// video are enclosed, and answers show the question. Posts with NPF content are rendered with RenderNPFHTML.
+func NewItem(post tumblr.PostInterface) Item {
+	self := post.GetSelf()
+	item := Item{
+		Id:    self.PostUrl,
+		Title: self.Summary,
+		Link:  self.PostUrl,
+		Tags:  self.Tags,
+	}
+	if item.Id == "" {
+		item.Id = fmt.Sprintf("%s%s/post/%d", tagPrefix, self.BlogName, self.Id)
+	}
+	if self.Timestamp > 0 {
+		item.Published = time.Unix(int64(self.Timestamp), 0).UTC()
+	}
+	switch p := post.(type) {
+	case *tumblr.TextPost:
+		if p.Title != "" {
+			item.Title = p.Title
+		}
+		item.Description = p.Body
+	case *tumblr.QuotePost:
+		item.Description = joinParts("<blockquote>"+p.Text+"</blockquote>", p.Source)
+		if item.Title == "" {
+			item.Title = excerpt(p.Text)
+		}
+	case *tumblr.LinkPost:
+		linkTitle := p.Title
+		if linkTitle == "" {
+			linkTitle = p.Url
+		}
+		item.Title = linkTitle
+		item.Description = joinParts(
+			fmt.Sprintf(`<p><a href="%s">%s</a></p>`, html.EscapeString(p.Url), html.EscapeString(linkTitle)),
+			p.Excerpt, p.Description)
+	case *tumblr.AnswerPost:
+		item.Title = excerpt(p.Question)
+		item.Description = joinParts(
+			fmt.Sprintf("<p><strong>%s</strong> asked: %s</p>", html.EscapeString(p.AskingName), p.Question),
+			p.Answer)
+	case *tumblr.ChatPost:
+		lines := []string{}
+		for _, line := range p.Dialog {
+			lines = append(lines, fmt.Sprintf("<p><strong>%s</strong> %s</p>", html.EscapeString(line.Label), html.EscapeString(line.Phrase)))
+		}
+		item.Description = joinParts(lines...)
+	case *tumblr.PhotoPost:
+		images := []string{}
+		for _, photo := range p.Photos {
+			if photo.OriginalSize.Url == "" {
+				continue
+			}
+			images = append(images, fmt.Sprintf(`<img src="%s" alt="%s">`,
+				html.EscapeString(photo.OriginalSize.Url), html.EscapeString(excerpt(photo.Caption))))
+			item.Enclosures = append(item.Enclosures, Enclosure{
+				Url:  photo.OriginalSize.Url,
+				Type: mediaType(photo.OriginalSize.Url, "image/jpeg"),
+			})
+		}
+		item.Description = joinParts(append(images, p.Caption)...)
+	case *tumblr.AudioPost:
+		if item.Title == "" && p.TrackName != "" {
+			item.Title = p.TrackName
+			if p.Artist != "" {
+				item.Title = p.Artist + " – " + p.TrackName
+			}
+		}
+		item.Description = joinParts(p.Player, p.Caption)
+		if p.AudioUrl != "" {
+			item.Enclosures = []Enclosure{{Url: p.AudioUrl, Type: mediaType(p.AudioUrl, "audio/mpeg")}}
+		}
+	case *tumblr.VideoPost:
+		item.Description = p.Caption
+		if p.VideoUrl != "" {
+			item.Enclosures = []Enclosure{{Url: p.VideoUrl, Type: mediaType(p.VideoUrl, "video/mp4")}}
+		}
+	default:
+		item.Description = joinParts(self.Body, self.Caption)
+	}
+	if len(self.Content) > 0 && item.Description == "" {
+		item.Description = tumblr.RenderNPFHTML(self.Content, self.Layout)
+	}
+	if item.Title == "" {
+		item.Title = excerpt(item.Description)
+	}
+	if item.Title == "" {
+		item.Title = fmt.Sprintf("%s post", self.Type)
+	}
+	return item
+}
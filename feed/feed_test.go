@@ -0,0 +1,249 @@
+package feed_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tumblr/tumblr.go"
+	"github.com/tumblr/tumblr.go/feed"
+	"github.com/tumblr/tumblr.go/tumblrtest"
+)
+
+const postsJSON = `[
+	{"id": 6, "type": "text", "blog_name": "david", "post_url": "https://david.tumblr.com/post/6", "timestamp": 1600000600,
+		"title": "Hello", "body": "<p>First <b>post</b></p>", "tags": ["intro", "hello"]},
+	{"id": 5, "type": "quote", "post_url": "https://david.tumblr.com/post/5", "timestamp": 1600000500,
+		"text": "To be, or not to be", "source": "Hamlet"},
+	{"id": 4, "type": "link", "post_url": "https://david.tumblr.com/post/4", "timestamp": 1600000400,
+		"url": "https://example.com/?a=1&b=2", "description": "<p>A site</p>"},
+	{"id": 3, "type": "photo", "post_url": "https://david.tumblr.com/post/3", "timestamp": 1600000300, "caption": "<p>Sunset</p>",
+		"photos": [{"original_size": {"url": "https://media.tumblr.com/a.png"}}, {"original_size": {"url": "https://media.tumblr.com/b.jpg"}}]},
+	{"id": 2, "type": "audio", "post_url": "https://david.tumblr.com/post/2", "timestamp": 1600000200,
+		"audio_url": "https://media.tumblr.com/song.mp3", "track_name": "Song", "artist": "Band"},
+	{"id": 1, "type": "video", "post_url": "https://david.tumblr.com/post/1", "timestamp": 1600000100,
+		"video_url": "https://media.tumblr.com/clip", "caption": "<p>Clip</p>"},
+	{"id": 0, "type": "blocks", "post_url": "https://david.tumblr.com/post/0", "timestamp": 1600000000,
+		"content": [{"type": "text", "text": "Blocks all the way down"}]}
+]`
+
+func testFeed(t *testing.T) *feed.Feed {
+	posts, err := tumblr.ReadPosts(strings.NewReader(postsJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blog := &tumblr.Blog{BlogRef: tumblr.BlogRef{Name: "david"}, Title: "David's Log", Url: "https://david.tumblr.com/",
+		Description: "Things & stuff"}
+	f := feed.New(blog)
+	if err = f.AddAll(tumblr.IteratePosts(posts), 0); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestNewItem(t *testing.T) {
+	items := testFeed(t).Items
+	expected := []struct {
+		title       string
+		description string
+		enclosures  []string
+	}{
+		{"Hello", "<p>First <b>post</b></p>", nil},
+		{"To be, or not to be", "<blockquote>To be, or not to be</blockquote>\nHamlet", nil},
+		{"https://example.com/?a=1&b=2", `<p><a href="https://example.com/?a=1&amp;b=2">https://example.com/?a=1&amp;b=2</a></p>` + "\n<p>A site</p>", nil},
+		{"Sunset", "", []string{"https://media.tumblr.com/a.png image/png", "https://media.tumblr.com/b.jpg image/jpeg"}},
+		{"Band – Song", "", []string{"https://media.tumblr.com/song.mp3 audio/mpeg"}},
+		{"Clip", "<p>Clip</p>", []string{"https://media.tumblr.com/clip video/mp4"}},
+		{"Blocks all the way down", "<p>Blocks all the way down</p>", nil},
+	}
+	if len(items) != len(expected) {
+		t.Fatalf("Expected %d items, got %d", len(expected), len(items))
+	}
+	for i, e := range expected {
+		item := items[i]
+		if item.Title != e.title {
+			t.Errorf("Item %d: expected title %q, got %q", i, e.title, item.Title)
+		}
+		if e.description != "" && item.Description != e.description {
+			t.Errorf("Item %d: expected description %q, got %q", i, e.description, item.Description)
+		}
+		if len(item.Enclosures) != len(e.enclosures) {
+			t.Errorf("Item %d: expected enclosures %v, got %v", i, e.enclosures, item.Enclosures)
+			continue
+		}
+		for j, enclosure := range item.Enclosures {
+			if got := enclosure.Url + " " + enclosure.Type; got != e.enclosures[j] {
+				t.Errorf("Item %d: expected enclosure %s, got %s", i, e.enclosures[j], got)
+			}
+		}
+	}
+	if photo := items[3].Description; !strings.Contains(photo, `<img src="https://media.tumblr.com/a.png"`) ||
+		!strings.HasSuffix(photo, "<p>Sunset</p>") {
+		t.Errorf("Expected the photos to be embedded above the caption, got %q", photo)
+	}
+	if items[0].Id != "https://david.tumblr.com/post/6" || !items[0].Published.Equal(time.Unix(1600000600, 0)) {
+		t.Errorf("Unexpected item %+v", items[0])
+	}
+}
+
+type rss struct {
+	Version string `xml:"version,attr"`
+	Channel struct {
+		Title         string `xml:"title"`
+		Description   string `xml:"description"`
+		LastBuildDate string `xml:"lastBuildDate"`
+		Items         []struct {
+			Title      string   `xml:"title"`
+			Guid       string   `xml:"guid"`
+			PubDate    string   `xml:"pubDate"`
+			Categories []string `xml:"category"`
+			Enclosures []struct {
+				Url  string `xml:"url,attr"`
+				Type string `xml:"type,attr"`
+			} `xml:"enclosure"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+func TestWriteRSS(t *testing.T) {
+	b := &bytes.Buffer{}
+	if err := testFeed(t).WriteRSS(b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(b.String(), xml.Header) {
+		t.Error("Expected an XML declaration")
+	}
+	doc := rss{}
+	if err := xml.Unmarshal(b.Bytes(), &doc); err != nil {
+		t.Fatalf("Invalid RSS %v:\n%s", err, b)
+	}
+	if doc.Version != "2.0" || doc.Channel.Title != "David's Log" || doc.Channel.Description != "Things & stuff" {
+		t.Errorf("Unexpected channel %+v", doc.Channel)
+	}
+	if doc.Channel.LastBuildDate != time.Unix(1600000600, 0).UTC().Format(time.RFC1123Z) {
+		t.Errorf("Expected the newest post to date the channel, got %s", doc.Channel.LastBuildDate)
+	}
+	items := doc.Channel.Items
+	if len(items) != 7 || items[0].Guid != "https://david.tumblr.com/post/6" || len(items[0].Categories) != 2 {
+		t.Fatalf("Unexpected items %+v", items)
+	}
+	// a single enclosure per RSS item
+	if len(items[3].Enclosures) != 1 || items[3].Enclosures[0].Url != "https://media.tumblr.com/a.png" {
+		t.Errorf("Unexpected photo enclosures %+v", items[3].Enclosures)
+	}
+}
+
+type atom struct {
+	XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+	Id      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Author  string   `xml:"author>name"`
+	Entries []struct {
+		Title     string `xml:"title"`
+		Published string `xml:"published"`
+		Links     []struct {
+			Rel  string `xml:"rel,attr"`
+			Href string `xml:"href,attr"`
+		} `xml:"link"`
+		Categories []struct {
+			Term string `xml:"term,attr"`
+		} `xml:"category"`
+		Content struct {
+			Type  string `xml:"type,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"content"`
+	} `xml:"entry"`
+}
+
+func TestWriteAtom(t *testing.T) {
+	b := &bytes.Buffer{}
+	if err := testFeed(t).WriteAtom(b); err != nil {
+		t.Fatal(err)
+	}
+	doc := atom{}
+	if err := xml.Unmarshal(b.Bytes(), &doc); err != nil {
+		t.Fatalf("Invalid Atom %v:\n%s", err, b)
+	}
+	if doc.Id != "https://david.tumblr.com/" || doc.Title != "David's Log" || doc.Author != "david" || len(doc.Entries) != 7 {
+		t.Fatalf("Unexpected feed %+v", doc)
+	}
+	first := doc.Entries[0]
+	if first.Published != "2020-09-13T12:36:40Z" || first.Content.Type != "html" || first.Content.Value != "<p>First <b>post</b></p>" {
+		t.Errorf("Unexpected entry %+v", first)
+	}
+	if len(first.Categories) != 2 || first.Categories[0].Term != "intro" {
+		t.Errorf("Unexpected categories %+v", first.Categories)
+	}
+	enclosures := 0
+	for _, link := range doc.Entries[3].Links {
+		if link.Rel == "enclosure" {
+			enclosures++
+		}
+	}
+	if enclosures != 2 {
+		t.Errorf("Expected both photos to be enclosed, got %d", enclosures)
+	}
+}
+
+func TestFromPosts(t *testing.T) {
+	client := tumblrtest.NewClient()
+	client.On(http.MethodGet, "/blog/david.tumblr.com/posts").
+		Respond(`{"meta": {"status": 200}, "response": {"posts": ` + postsJSON + `, "total_posts": 7}}`)
+	posts, err := tumblr.GetPosts(client, "david", url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := feed.FromPosts(&tumblr.Blog{BlogRef: tumblr.BlogRef{Name: "david"}}, posts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Title != "david" || len(f.Items) != 7 {
+		t.Errorf("Unexpected feed %+v", f)
+	}
+}
+
+func TestAddAllLimit(t *testing.T) {
+	posts, _ := tumblr.ReadPosts(strings.NewReader(postsJSON))
+	f := &feed.Feed{}
+	if err := f.AddAll(tumblr.IteratePosts(posts), 3); err != nil || len(f.Items) != 3 {
+		t.Errorf("Expected 3 items, got %d, %v", len(f.Items), err)
+	}
+}
+
+func TestWriteAtomId(t *testing.T) {
+	f := &feed.Feed{Title: "Untitled"}
+	if err := f.WriteAtom(&bytes.Buffer{}); err == nil {
+		t.Fatal("A feed without an id should not be written as Atom")
+	}
+	f.Author = "david"
+	b := &bytes.Buffer{}
+	if err := f.WriteAtom(b); err != nil {
+		t.Fatal(err)
+	}
+	doc := atom{}
+	if err := xml.Unmarshal(b.Bytes(), &doc); err != nil || doc.Id != "tag:tumblr.com,2007:david" {
+		t.Errorf("Expected an id derived from the author, got %q, %v", doc.Id, err)
+	}
+}
+
+func TestAddAllDecodeErrors(t *testing.T) {
+	posts, err := tumblr.ReadPosts(strings.NewReader(`[
+		{"id": 3, "type": "text", "blog_name": "david", "title": "Broken", "body": 7},
+		{"id": 2, "type": "text", "blog_name": "david", "title": "Two"},
+		{"id": 1, "type": "text", "blog_name": "david", "title": "One"}
+	]`))
+	if err == nil {
+		t.Fatal("Expected a decode error")
+	}
+	f := &feed.Feed{}
+	if err = f.AddAll(tumblr.IteratePosts(posts), 2); err != nil || len(f.Items) != 2 {
+		t.Fatalf("Expected 2 items, got %d, %v", len(f.Items), err)
+	}
+	if f.Items[0].Id != "tag:tumblr.com,2007:david/post/3" || f.Items[1].Title != "Two" {
+		t.Errorf("Expected the broken post to be added with its common fields, got %+v", f.Items)
+	}
+}
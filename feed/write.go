@@ -0,0 +1,180 @@
+package feed
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"time"
+)
+
+// RSS 2.0 document
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link,omitempty"`
+	Description string        `xml:"description"`
+	Guid        rssGuid       `xml:"guid"`
+	PubDate     string        `xml:"pubDate,omitempty"`
+	Categories  []string      `xml:"category"`
+	Enclosure   *rssEnclosure `xml:"enclosure"`
+}
+
+type rssGuid struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	Url    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length int64  `xml:"length,attr"`
+}
+
+// Atom 1.0 document
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Id       string      `xml:"id"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Author   *atomAuthor `xml:"author"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel    string `xml:"rel,attr,omitempty"`
+	Href   string `xml:"href,attr"`
+	Type   string `xml:"type,attr,omitempty"`
+	Length int64  `xml:"length,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	Id         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published,omitempty"`
+	Links      []atomLink     `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+// Writes a document with an XML declaration
+func writeXML(w io.Writer, document interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// Formats a time for RSS, or nothing for the zero time
+func rssDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC1123Z)
+}
+
+// WriteRSS writes the feed as an RSS 2.0 document. RSS allows a single enclosure per item, so only the first
+// enclosure of each item is included.
+func (f *Feed) WriteRSS(w io.Writer) error {
+	channel := rssChannel{
+		Title:         f.Title,
+		Link:          f.Link,
+		Description:   f.Description,
+		LastBuildDate: rssDate(f.updated()),
+	}
+	for _, item := range f.Items {
+		entry := rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			Description: item.Description,
+			Guid:        rssGuid{IsPermaLink: item.Id == item.Link, Value: item.Id},
+			PubDate:     rssDate(item.Published),
+			Categories:  item.Tags,
+		}
+		if len(item.Enclosures) > 0 {
+			enclosure := item.Enclosures[0]
+			entry.Enclosure = &rssEnclosure{Url: enclosure.Url, Type: enclosure.Type, Length: enclosure.Length}
+		}
+		channel.Items = append(channel.Items, entry)
+	}
+	return writeXML(w, rssDocument{Version: "2.0", Channel: channel})
+}
+
+// WriteAtom writes the feed as an Atom 1.0 document. Atom requires an id, so the feed needs an Id, a Link or
+// an Author.
+func (f *Feed) WriteAtom(w io.Writer) error {
+	id := f.id()
+	if id == "" {
+		return errors.New("No id, link or author to identify the feed by")
+	}
+	updated := f.updated()
+	document := atomFeed{
+		Title:    f.Title,
+		Subtitle: f.Description,
+		Id:       id,
+		Updated:  updated.Format(time.RFC3339),
+	}
+	if f.Link != "" {
+		document.Links = []atomLink{{Rel: "alternate", Href: f.Link, Type: "text/html"}}
+	}
+	if f.Author != "" {
+		document.Author = &atomAuthor{Name: f.Author}
+	}
+	for _, item := range f.Items {
+		entry := atomEntry{
+			Title:   item.Title,
+			Id:      item.Id,
+			Updated: updated.Format(time.RFC3339),
+			Content: atomContent{Type: "html", Value: item.Description},
+		}
+		if !item.Published.IsZero() {
+			entry.Updated = item.Published.Format(time.RFC3339)
+			entry.Published = entry.Updated
+		}
+		if item.Link != "" {
+			entry.Links = append(entry.Links, atomLink{Rel: "alternate", Href: item.Link, Type: "text/html"})
+		}
+		for _, enclosure := range item.Enclosures {
+			entry.Links = append(entry.Links, atomLink{Rel: "enclosure", Href: enclosure.Url, Type: enclosure.Type, Length: enclosure.Length})
+		}
+		for _, tag := range item.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		document.Entries = append(document.Entries, entry)
+	}
+	return writeXML(w, document)
+}
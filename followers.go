@@ -2,6 +2,7 @@ package tumblr
 
 import (
 	"encoding/json"
+	"errors"
 	"net/url"
 )
 
@@ -9,6 +10,8 @@ type FollowingList struct {
 	client ClientInterface
 	Total  uint32 `json:"total_blogs"`
 	Blogs  []Blog `json:"blogs"`
+	// Blog whose followed blogs are listed, empty for the user's
+	name   string
 	offset uint
 	limit  uint
 }
@@ -33,9 +36,26 @@ type Follower struct {
 
 // Retrieves the list of blogs this user follows
 func GetFollowing(client ClientInterface, offset, limit uint) (*FollowingList, error) {
+	return getFollowing(client, "", offset, limit)
+}
+
+// Retrieves the list of blogs a blog follows, if the blog shares it
+func GetBlogFollowing(client ClientInterface, name string, offset, limit uint) (*FollowingList, error) {
+	if name == "" {
+		return nil, errors.New("No blog name provided")
+	}
+	return getFollowing(client, name, offset, limit)
+}
+
+// Retrieves the blogs followed by a blog, or by the user if name is empty
+func getFollowing(client ClientInterface, name string, offset, limit uint) (*FollowingList, error) {
 	params := setParamsUint(uint64(limit), url.Values{}, "limit")
 	params = setParamsUint(uint64(offset), params, "offset")
-	result, err := client.GetWithParams("/user/following", params)
+	path := "/user/following"
+	if name != "" {
+		path = blogPath("/blog/%s/following", name)
+	}
+	result, err := client.GetWithParams(path, params)
 	if err != nil {
 		return nil, err
 	}
@@ -44,6 +64,7 @@ func GetFollowing(client ClientInterface, offset, limit uint) (*FollowingList, e
 	}{
 		Response: FollowingList{
 			client: client,
+			name:   name,
 			limit:  limit,
 			offset: offset,
 		},
@@ -64,7 +85,7 @@ func (f *FollowingList) Next() (*FollowingList, error) {
 	if offset >= uint(f.Total) {
 		return nil, NoNextPageError
 	}
-	return GetFollowing(f.client, offset, limit)
+	return getFollowing(f.client, f.name, offset, limit)
 }
 
 // Retrieves the previous page of followers
@@ -80,7 +101,7 @@ func (f *FollowingList) Prev() (*FollowingList, error) {
 	if limit >= f.offset {
 		newOffset = 0
 	}
-	return GetFollowing(f.client, newOffset, limit)
+	return getFollowing(f.client, f.name, newOffset, limit)
 }
 
 // Retrieve User's followers
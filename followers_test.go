@@ -82,6 +82,28 @@ func TestFollowingNext(t *testing.T) {
 	}
 }
 
+func TestGetBlogFollowing(t *testing.T) {
+	if _, err := GetBlogFollowing(newTestClient("{}", nil), "", 0, 0); err == nil {
+		t.Fatal("Missing blog name should generate an error")
+	}
+	response := getFollowerString(5, Blog{}, Blog{}, Blog{})
+	client := newTestClient(response, nil)
+	expectedParams := url.Values{}
+	expectedParams.Set("offset", "0")
+	expectedParams.Set("limit", "3")
+	client.confirmExpectedSet = expectClientCallParams(t, "GetBlogFollowing", http.MethodGet, "/blog/david.tumblr.com/following", expectedParams)
+	result, err := GetBlogFollowing(client, "david", 0, 3)
+	if err != nil {
+		t.Fatal("Failed to get blog following", err)
+	}
+	// pages stay on the blog's endpoint
+	expectedParams.Set("offset", "3")
+	client.confirmExpectedSet = expectClientCallParams(t, "BlogFollowing.Next", http.MethodGet, "/blog/david.tumblr.com/following", expectedParams)
+	if _, err = result.Next(); err != nil {
+		t.Fatal("Failed to get next page", err)
+	}
+}
+
 func TestFollowingPrevWithoutLimit(t *testing.T) {
 	client := newTestClient("{}", nil)
 	result, _ := GetFollowing(client, 4, 0)
@@ -0,0 +1,3 @@
+module github.com/tumblr/tumblr.go
+
+go 1.21
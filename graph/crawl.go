@@ -0,0 +1,193 @@
+package graph
+
+import (
+	"context"
+	"errors"
+
+	"github.com/tumblr/tumblr.go"
+)
+
+// Default number of followers or followed blogs requested per page, the most the API returns
+const defaultPageSize = 20
+
+// Crawler builds a Graph by listing the followers and followed blogs of seed blogs, then of the blogs found,
+// breadth first, up to a depth and within a request budget.
+//
+// Blogs only share their followers with their owners, and their followed blogs if they choose to; listings
+// which fail are recorded in the Node's Err and the crawl goes on. An exhausted rate limit stops the crawl.
+type Crawler struct {
+	Client tumblr.ClientInterface
+	// Hops from the seeds whose blogs are listed; blogs found at Depth are added without being listed.
+	// Defaults to 1, listing only the seeds.
+	Depth int
+	// Most requests made by a crawl; zero is unlimited
+	MaxRequests int
+	// Most requests started per second; zero is unlimited
+	PerSecond float64
+	// Paces the requests instead of PerSecond, sharing its rate with whatever else it paces
+	Pacer *tumblr.Pacer
+	// Most pages read per listing, so that popular blogs do not take the whole budget; zero reads every page
+	MaxPages int
+	// Followers or followed blogs requested per page; defaults to 20
+	PageSize uint
+}
+
+// State of a single crawl, so that a Crawler can run several at once
+type crawl struct {
+	*Crawler
+	// Requests made so far
+	requests int
+	pacer    *tumblr.Pacer
+}
+
+// NewCrawler creates a Crawler listing blogs through client.
+func NewCrawler(client tumblr.ClientInterface) *Crawler {
+	return &Crawler{Client: client, Depth: 1}
+}
+
+// Error ending a crawl once the request budget is spent
+var errBudgetSpent = errors.New("Request budget spent")
+
+// Crawl builds the graph around seeds. When the request budget runs out the graph built so far is returned
+// with Truncated set; when ctx is done or the rate limit is exhausted it is returned along with the error.
+func (c *Crawler) Crawl(ctx context.Context, seeds ...string) (*Graph, error) {
+	if len(seeds) < 1 {
+		return nil, errors.New("No seed blogs provided")
+	}
+	depth := c.Depth
+	if depth < 1 {
+		depth = 1
+	}
+	state := &crawl{Crawler: c, pacer: c.Pacer}
+	if state.pacer == nil {
+		state.pacer = tumblr.NewPacer(c.PerSecond)
+	}
+	g := New()
+	queue := []*Node{}
+	for _, seed := range seeds {
+		if g.Node(seed) == nil {
+			queue = append(queue, g.AddNode(seed))
+		}
+	}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		if node.Depth >= depth {
+			continue
+		}
+		found, err := state.list(ctx, g, node)
+		if err == errBudgetSpent {
+			g.Truncated = true
+			return g, nil
+		}
+		if err != nil {
+			return g, err
+		}
+		queue = append(queue, found...)
+	}
+	return g, nil
+}
+
+// Counts a request against the budget, then waits for its turn
+func (c *crawl) spend(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if c.MaxRequests > 0 && c.requests >= c.MaxRequests {
+		return errBudgetSpent
+	}
+	c.requests++
+	return c.pacer.Wait(ctx)
+}
+
+// Reports whether an error ends the crawl rather than a blog's listing
+func fatal(err error) bool {
+	var limit *tumblr.RateLimitError
+	return err == errBudgetSpent || err == context.Canceled || err == context.DeadlineExceeded || errors.As(err, &limit)
+}
+
+// Adds the edge between a node and another blog, returning the blog's node if it is new to the graph
+func discover(g *Graph, node *Node, other string, follows bool) *Node {
+	found := g.Node(other)
+	if found == nil {
+		found = g.AddNode(other)
+		found.Depth = node.Depth + 1
+	} else {
+		found = nil
+	}
+	if follows {
+		g.AddEdge(node.Name, other)
+	} else {
+		g.AddEdge(other, node.Name)
+	}
+	return found
+}
+
+// Lists the followed blogs and followers of a node into the graph, returning the blogs new to it
+func (c *crawl) list(ctx context.Context, g *Graph, node *Node) ([]*Node, error) {
+	limit := c.PageSize
+	if limit < 1 {
+		limit = defaultPageSize
+	}
+	found := []*Node{}
+	err := c.spend(ctx)
+	if err == nil {
+		var following *tumblr.FollowingList
+		following, err = tumblr.GetBlogFollowing(c.Client, node.Name, 0, limit)
+		listed := 0
+		for page := 1; err == nil; page++ {
+			node.FollowingTotal = following.Total
+			for _, blog := range following.Blogs {
+				if next := discover(g, node, blog.Name, true); next != nil {
+					found = append(found, next)
+				}
+			}
+			listed += len(following.Blogs)
+			if len(following.Blogs) < 1 || listed >= int(following.Total) || (c.MaxPages > 0 && page >= c.MaxPages) {
+				break
+			}
+			if err = c.spend(ctx); err == nil {
+				following, err = following.Next()
+			}
+		}
+	}
+	if err == tumblr.NoNextPageError {
+		err = nil
+	}
+	if fatal(err) {
+		return found, err
+	}
+	node.Err = err
+
+	if err = c.spend(ctx); err == nil {
+		var followers *tumblr.FollowerList
+		followers, err = tumblr.GetFollowers(c.Client, node.Name, 0, limit)
+		listed := 0
+		for page := 1; err == nil; page++ {
+			node.FollowerTotal = followers.Total
+			for _, follower := range followers.Followers {
+				if next := discover(g, node, follower.Name, false); next != nil {
+					found = append(found, next)
+				}
+			}
+			listed += len(followers.Followers)
+			if len(followers.Followers) < 1 || listed >= int(followers.Total) || (c.MaxPages > 0 && page >= c.MaxPages) {
+				break
+			}
+			if err = c.spend(ctx); err == nil {
+				followers, err = followers.Next()
+			}
+		}
+	}
+	if err == tumblr.NoNextPageError {
+		err = nil
+	}
+	if fatal(err) {
+		return found, err
+	}
+	if node.Err == nil {
+		node.Err = err
+	}
+	node.Crawled = node.Err == nil
+	return found, nil
+}
@@ -0,0 +1,144 @@
+package graph_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tumblr/tumblr.go"
+	"github.com/tumblr/tumblr.go/graph"
+	"github.com/tumblr/tumblr.go/tumblrtest"
+	"github.com/tumblr/tumblr.go/tumblrtest/fakeserver"
+)
+
+// Starts a server where david follows b and is followed by a, a and b follow each other, c follows a and d
+// follows c
+func newTestServer(t *testing.T) *fakeserver.Server {
+	server := fakeserver.New("david")
+	for _, name := range []string{"a", "b", "c", "d"} {
+		server.AddBlog(name, name)
+	}
+	for _, follow := range [][2]string{{"david", "a"}, {"a", "b"}, {"b", "a"}, {"a", "c"}, {"c", "d"}} {
+		if err := server.AddFollower(follow[0], follow[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tumblr.Follow(server.Client(), "b"); err != nil {
+		t.Fatal(err)
+	}
+	return server
+}
+
+func TestCrawl(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	crawler := graph.NewCrawler(server.Client())
+	crawler.Depth = 2
+	g, err := crawler.Crawl(context.Background(), "david")
+	if err != nil || g.Truncated {
+		t.Fatalf("Expected a complete crawl, got %v, %v", g, err)
+	}
+	expected := []graph.Edge{{From: "a", To: "b"}, {From: "a", To: "david"}, {From: "b", To: "a"}, {From: "c", To: "a"}, {From: "david", To: "b"}}
+	if edges := g.Edges(); !reflect.DeepEqual(edges, expected) {
+		t.Errorf("Expected edges %v, got %v", expected, edges)
+	}
+	depths := map[string]int{"david": 0, "a": 1, "b": 1, "c": 2}
+	for _, node := range g.Nodes() {
+		if node.Depth != depths[node.Name] || node.Crawled != (node.Depth < 2) {
+			t.Errorf("Unexpected node %+v", node)
+		}
+	}
+	// d is beyond the depth
+	if g.Node("d") != nil {
+		t.Error("Expected d not to be reached")
+	}
+	if node := g.Node("b"); node.FollowerTotal != 2 || node.FollowingTotal != 1 {
+		t.Errorf("Expected b's totals, got %+v", node)
+	}
+	if shared := g.SharedFollowers("david", "b"); !reflect.DeepEqual(shared, []string{"a"}) {
+		t.Errorf("Expected a to follow both david and b, got %v", shared)
+	}
+}
+
+func TestCrawlBudget(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	crawler := graph.NewCrawler(server.Client())
+	crawler.Depth = 3
+	crawler.MaxRequests = 2
+	crawler.PerSecond = 100
+	g, err := crawler.Crawl(context.Background(), "david")
+	if err != nil || !g.Truncated {
+		t.Fatalf("Expected a truncated crawl, got %v", err)
+	}
+	if len(g.Nodes()) != 3 || !g.Node("david").Crawled || g.Node("a").Crawled {
+		t.Errorf("Expected only the seed to be listed, got %v", g.Edges())
+	}
+}
+
+func TestCrawlConcurrent(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	crawler := graph.NewCrawler(server.Client())
+	crawler.Depth = 3
+	crawler.MaxRequests = 4
+	graphs := make(chan *graph.Graph, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			g, _ := crawler.Crawl(context.Background(), "david")
+			graphs <- g
+		}()
+	}
+	// each crawl has its own budget
+	for i := 0; i < 2; i++ {
+		if g := <-graphs; g == nil || !g.Truncated || !g.Node("b").Crawled {
+			t.Errorf("Expected two listed blogs per crawl, got %+v", g)
+		}
+	}
+}
+
+func TestCrawlPacedCancellation(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	crawler := graph.NewCrawler(server.Client())
+	crawler.Depth = 3
+	crawler.Pacer = tumblr.NewPacer(0.5)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := crawler.Crawl(ctx, "david"); err != context.DeadlineExceeded {
+		t.Errorf("Expected the crawl to end with its context, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the paced crawl to stop waiting, took %s", elapsed)
+	}
+}
+
+func TestCrawlErrors(t *testing.T) {
+	client := tumblrtest.NewClient()
+	client.On(http.MethodGet, "/blog/david.tumblr.com/following").Fail(errors.New("Forbidden"))
+	client.On(http.MethodGet, "/blog/david.tumblr.com/followers").
+		Respond(`{"response": {"total_users": 1, "users": [{"name": "a"}]}}`)
+	client.On(http.MethodGet, "/blog/a.tumblr.com/following").
+		Fail(&tumblr.RateLimitError{Reset: time.Now().Add(time.Hour)})
+
+	crawler := graph.NewCrawler(client)
+	crawler.Depth = 2
+	g, err := crawler.Crawl(context.Background(), "david")
+	var limit *tumblr.RateLimitError
+	if !errors.As(err, &limit) {
+		t.Errorf("Expected the rate limit to stop the crawl, got %v", err)
+	}
+	// the failed listing is recorded and the other one still read
+	node := g.Node("david")
+	if node.Err == nil || node.Crawled || !g.Follows("a", "david") {
+		t.Errorf("Expected the followers to be listed despite the error, got %+v", node)
+	}
+}
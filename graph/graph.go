@@ -0,0 +1,191 @@
+// Package graph maps the follow relationships around Tumblr blogs.
+//
+// A Crawler walks GetBlogFollowing and GetFollowers outward from seed blogs into a Graph, which answers
+// questions such as who follows whom back, how connected a blog is, or which followers two blogs share, and
+// can be written as GraphML or DOT for visualization:
+//
+//	crawler := graph.NewCrawler(client)
+//	crawler.Depth = 2
+//	g, err := crawler.Crawl(ctx, "staff")
+//	g.WriteDOT(w)
+package graph
+
+import (
+	"sort"
+	"strings"
+)
+
+// Node is a blog of a Graph.
+type Node struct {
+	Name string
+	// Hops from the nearest seed blog
+	Depth int
+	// Whether the blog's followers and followed blogs were listed
+	Crawled bool
+	// Totals reported by the API when crawled, which may exceed the edges of the graph
+	FollowerTotal  uint32
+	FollowingTotal uint32
+	// Why listing the blog's followers or followed blogs failed, e.g. because the blog does not share them
+	Err error
+}
+
+// Edge is a follow relationship: From follows To.
+type Edge struct {
+	From string
+	To   string
+}
+
+// Graph is a directed graph of blogs following each other.
+type Graph struct {
+	// Set when a crawl ran out of its request budget before listing every blog within its depth
+	Truncated bool
+	nodes     map[string]*Node
+	following map[string]map[string]bool
+	followers map[string]map[string]bool
+}
+
+// New creates an empty Graph.
+func New() *Graph {
+	return &Graph{
+		nodes:     map[string]*Node{},
+		following: map[string]map[string]bool{},
+		followers: map[string]map[string]bool{},
+	}
+}
+
+// Returns a blog name as used in the graph, without the .tumblr.com suffix
+func nodeName(name string) string {
+	return strings.TrimSuffix(name, ".tumblr.com")
+}
+
+// Returns the keys of a set, sorted
+func sortedNames(set map[string]bool) []string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AddNode returns the node of a blog, adding it if it is not in the graph yet.
+func (g *Graph) AddNode(name string) *Node {
+	name = nodeName(name)
+	node, ok := g.nodes[name]
+	if !ok {
+		node = &Node{Name: name}
+		g.nodes[name] = node
+	}
+	return node
+}
+
+// Node returns the node of a blog, or nil if it is not in the graph.
+func (g *Graph) Node(name string) *Node {
+	return g.nodes[nodeName(name)]
+}
+
+// Nodes returns every node of the graph, by name.
+func (g *Graph) Nodes() []*Node {
+	nodes := make([]*Node, 0, len(g.nodes))
+	for _, name := range sortedNames(g.names()) {
+		nodes = append(nodes, g.nodes[name])
+	}
+	return nodes
+}
+
+// Returns the names of the nodes as a set
+func (g *Graph) names() map[string]bool {
+	names := make(map[string]bool, len(g.nodes))
+	for name := range g.nodes {
+		names[name] = true
+	}
+	return names
+}
+
+// AddEdge records that follower follows followed, adding missing nodes.
+func (g *Graph) AddEdge(follower, followed string) {
+	from := g.AddNode(follower).Name
+	to := g.AddNode(followed).Name
+	if from == to {
+		return
+	}
+	if g.following[from] == nil {
+		g.following[from] = map[string]bool{}
+	}
+	g.following[from][to] = true
+	if g.followers[to] == nil {
+		g.followers[to] = map[string]bool{}
+	}
+	g.followers[to][from] = true
+}
+
+// Edges returns every follow relationship of the graph, by follower then followed blog.
+func (g *Graph) Edges() []Edge {
+	edges := []Edge{}
+	for _, from := range sortedNames(g.names()) {
+		for _, to := range sortedNames(g.following[from]) {
+			edges = append(edges, Edge{From: from, To: to})
+		}
+	}
+	return edges
+}
+
+// Follows reports whether follower follows followed.
+func (g *Graph) Follows(follower, followed string) bool {
+	return g.following[nodeName(follower)][nodeName(followed)]
+}
+
+// Followers returns the blogs following a blog, by name.
+func (g *Graph) Followers(name string) []string {
+	return sortedNames(g.followers[nodeName(name)])
+}
+
+// Following returns the blogs a blog follows, by name.
+func (g *Graph) Following(name string) []string {
+	return sortedNames(g.following[nodeName(name)])
+}
+
+// InDegree returns the number of blogs of the graph following a blog.
+func (g *Graph) InDegree(name string) int {
+	return len(g.followers[nodeName(name)])
+}
+
+// OutDegree returns the number of blogs of the graph a blog follows.
+func (g *Graph) OutDegree(name string) int {
+	return len(g.following[nodeName(name)])
+}
+
+// Mutuals returns the blogs which a blog follows and which follow it back, by name.
+func (g *Graph) Mutuals(name string) []string {
+	name = nodeName(name)
+	mutuals := map[string]bool{}
+	for followed := range g.following[name] {
+		if g.following[followed][name] {
+			mutuals[followed] = true
+		}
+	}
+	return sortedNames(mutuals)
+}
+
+// MutualEdges returns every pair of blogs following each other, once, with From sorting before To.
+func (g *Graph) MutualEdges() []Edge {
+	edges := []Edge{}
+	for _, edge := range g.Edges() {
+		if edge.From < edge.To && g.following[edge.To][edge.From] {
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
+
+// SharedFollowers returns the blogs following both a and b, by name.
+func (g *Graph) SharedFollowers(a, b string) []string {
+	shared := map[string]bool{}
+	others := g.followers[nodeName(b)]
+	for follower := range g.followers[nodeName(a)] {
+		if others[follower] {
+			shared[follower] = true
+		}
+	}
+	return sortedNames(shared)
+}
@@ -0,0 +1,104 @@
+package graph_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tumblr/tumblr.go/graph"
+)
+
+// Builds a graph where a and b follow each other, and c follows both
+func newTestGraph() *graph.Graph {
+	g := graph.New()
+	g.AddNode("a")
+	g.AddEdge("a", "b")
+	g.AddEdge("b.tumblr.com", "a")
+	g.AddEdge("c", "a")
+	g.AddEdge("c", "b")
+	return g
+}
+
+func TestGraphAnalysis(t *testing.T) {
+	g := newTestGraph()
+	if mutuals := g.Mutuals("a"); !reflect.DeepEqual(mutuals, []string{"b"}) {
+		t.Errorf("Expected b to be a's only mutual, got %v", mutuals)
+	}
+	if mutuals := g.Mutuals("c"); len(mutuals) != 0 {
+		t.Errorf("Expected c to have no mutuals, got %v", mutuals)
+	}
+	if edges := g.MutualEdges(); !reflect.DeepEqual(edges, []graph.Edge{{From: "a", To: "b"}}) {
+		t.Errorf("Expected the a-b pair once, got %v", edges)
+	}
+	if in, out := g.InDegree("a"), g.OutDegree("a"); in != 2 || out != 1 {
+		t.Errorf("Expected a to have in degree 2 and out degree 1, got %d and %d", in, out)
+	}
+	if in, out := g.InDegree("c"), g.OutDegree("c"); in != 0 || out != 2 {
+		t.Errorf("Expected c to have in degree 0 and out degree 2, got %d and %d", in, out)
+	}
+	if shared := g.SharedFollowers("a", "b"); !reflect.DeepEqual(shared, []string{"c"}) {
+		t.Errorf("Expected c as the shared follower, got %v", shared)
+	}
+	if followers := g.Followers("a"); !reflect.DeepEqual(followers, []string{"b", "c"}) {
+		t.Errorf("Unexpected followers %v", followers)
+	}
+	if len(g.Nodes()) != 3 || g.Node("b.tumblr.com") == nil {
+		t.Errorf("Expected 3 nodes named without suffix, got %v", g.Nodes())
+	}
+}
+
+func TestWriteGraphML(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	if err := newTestGraph().WriteGraphML(buffer); err != nil {
+		t.Fatal(err)
+	}
+	doc := struct {
+		Graph struct {
+			EdgeDefault string `xml:"edgedefault,attr"`
+			Nodes       []struct {
+				Id string `xml:"id,attr"`
+			} `xml:"node"`
+			Edges []struct {
+				Source string `xml:"source,attr"`
+				Target string `xml:"target,attr"`
+				Data   []struct {
+					Key   string `xml:"key,attr"`
+					Value string `xml:",chardata"`
+				} `xml:"data"`
+			} `xml:"edge"`
+		} `xml:"graph"`
+	}{}
+	if err := xml.Unmarshal(buffer.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Graph.EdgeDefault != "directed" || len(doc.Graph.Nodes) != 3 || len(doc.Graph.Edges) != 4 {
+		t.Fatalf("Unexpected document %s", buffer)
+	}
+	edge := doc.Graph.Edges[0]
+	if edge.Source != "a" || edge.Target != "b" || edge.Data[0].Key != "mutual" || edge.Data[0].Value != "true" {
+		t.Errorf("Expected a mutual edge from a to b, got %+v", edge)
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	if err := newTestGraph().WriteDOT(buffer); err != nil {
+		t.Fatal(err)
+	}
+	dot := buffer.String()
+	for _, line := range []string{
+		`digraph follows {`,
+		`  "a" -> "b" [dir=both];`,
+		`  "c" -> "a";`,
+		`  "c" -> "b";`,
+	} {
+		if !strings.Contains(dot, line+"\n") {
+			t.Errorf("Expected %q in %s", line, dot)
+		}
+	}
+	if strings.Contains(dot, `"b" -> "a"`) {
+		t.Errorf("Expected the mutual follow to be drawn once, got %s", dot)
+	}
+}
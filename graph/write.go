@@ -0,0 +1,113 @@
+package graph
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// GraphML document
+type graphML struct {
+	XMLName xml.Name     `xml:"http://graphml.graphdrawing.org/xmlns graphml"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	Id   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	Id          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	Id   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// Attributes written for nodes and edges
+var graphMLKeys = []graphMLKey{
+	{Id: "depth", For: "node", Name: "depth", Type: "int"},
+	{Id: "crawled", For: "node", Name: "crawled", Type: "boolean"},
+	{Id: "in", For: "node", Name: "in_degree", Type: "int"},
+	{Id: "out", For: "node", Name: "out_degree", Type: "int"},
+	{Id: "followers", For: "node", Name: "follower_total", Type: "long"},
+	{Id: "following", For: "node", Name: "following_total", Type: "long"},
+	{Id: "mutual", For: "edge", Name: "mutual", Type: "boolean"},
+}
+
+// WriteGraphML writes the graph as a directed GraphML document, with each blog's depth, degrees and totals,
+// and whether each follow is mutual.
+func (g *Graph) WriteGraphML(w io.Writer) error {
+	doc := graphML{Keys: graphMLKeys, Graph: graphMLGraph{Id: "follows", EdgeDefault: "directed"}}
+	for _, node := range g.Nodes() {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{Id: node.Name, Data: []graphMLData{
+			{Key: "depth", Value: strconv.Itoa(node.Depth)},
+			{Key: "crawled", Value: strconv.FormatBool(node.Crawled)},
+			{Key: "in", Value: strconv.Itoa(g.InDegree(node.Name))},
+			{Key: "out", Value: strconv.Itoa(g.OutDegree(node.Name))},
+			{Key: "followers", Value: strconv.FormatUint(uint64(node.FollowerTotal), 10)},
+			{Key: "following", Value: strconv.FormatUint(uint64(node.FollowingTotal), 10)},
+		}})
+	}
+	for _, edge := range g.Edges() {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{Source: edge.From, Target: edge.To, Data: []graphMLData{
+			{Key: "mutual", Value: strconv.FormatBool(g.Follows(edge.To, edge.From))},
+		}})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return encoder.Encode(doc)
+}
+
+// Quotes an identifier for DOT
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// WriteDOT writes the graph as a Graphviz digraph. Mutual follows are drawn once, as a two-headed edge, and
+// the seeds are boxed.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintln(b, "digraph follows {")
+	for _, node := range g.Nodes() {
+		shape := "ellipse"
+		if node.Depth == 0 {
+			shape = "box"
+		}
+		fmt.Fprintf(b, "  %s [shape=%s, depth=%d];\n", dotQuote(node.Name), shape, node.Depth)
+	}
+	for _, edge := range g.Edges() {
+		if !g.Follows(edge.To, edge.From) {
+			fmt.Fprintf(b, "  %s -> %s;\n", dotQuote(edge.From), dotQuote(edge.To))
+		} else if edge.From < edge.To {
+			fmt.Fprintf(b, "  %s -> %s [dir=both];\n", dotQuote(edge.From), dotQuote(edge.To))
+		}
+	}
+	fmt.Fprintln(b, "}")
+	return b.Flush()
+}
@@ -7,8 +7,8 @@ import (
 
 type Likes struct {
 	client      ClientInterface
-	response    *Response
 	parsedPosts []PostInterface
+	decodeErrs  PostDecodeErrors
 	Posts       []MiniPost `json:"liked_posts"`
 	TotalLikes  uint64     `json:"liked_count"`
 }
@@ -26,14 +26,23 @@ func GetLikes(client ClientInterface, params url.Values) (*Likes, error) {
 	}
 
 	result := struct {
-		Response Likes `json:"response"`
+		Response struct {
+			Posts      *decodedPostList `json:"liked_posts"`
+			TotalLikes uint64           `json:"liked_count"`
+		} `json:"response"`
 	}{}
 	if err = json.Unmarshal(response.body, &result); err != nil {
 		return nil, err
 	}
-	result.Response.client = client
-	result.Response.response = &response
-	return &result.Response, nil
+	likes := &Likes{
+		client:     client,
+		TotalLikes: result.Response.TotalLikes,
+	}
+	if list := result.Response.Posts; list != nil && list.posts != nil {
+		likes.Posts = list.minis()
+		likes.parsedPosts, likes.decodeErrs = list.result(client)
+	}
+	return likes, nil
 }
 
 // Convenience method for performing a like/unlike operation
@@ -54,21 +63,31 @@ func UnlikePost(client ClientInterface, postId uint64, reblogKey string) error {
 	return doLike(client, "/user/unlike", postId, reblogKey)
 }
 
-// Return an array of full post objects (instead of the default array of MiniPosts initially created)
+// Return an array of full post objects, which are decoded along with the likes
+// Posts which fail to decode are kept as RawPosts and reported by DecodeErrors.
 func (l *Likes) Full() ([]PostInterface, error) {
-	var err error = nil
-	if l.parsedPosts == nil {
-		r := struct {
-			Response struct {
-				Posts []PostInterface `json:"liked_posts"`
-			} `json:"response"`
-		}{}
-		r.Response.Posts = makePostsFromMinis(l.Posts, l.client)
-		if err = json.Unmarshal(l.response.body, &r); err != nil {
-			l.parsedPosts = []PostInterface{}
-		} else {
-			l.parsedPosts = r.Response.Posts
-		}
+	if err := l.decode(); err != nil {
+		return nil, err
+	}
+	return l.parsedPosts, nil
+}
+
+// DecodeErrors returns the liked posts which failed to decode, and are kept as RawPosts.
+func (l *Likes) DecodeErrors() PostDecodeErrors {
+	l.decode()
+	return l.decodeErrs
+}
+
+// Decodes the posts, along with their errors, unless the response already did. Likes built without a
+// response only have their MiniPosts to go on.
+func (l *Likes) decode() error {
+	if l.parsedPosts != nil {
+		return nil
+	}
+	posts, errs, err := decodeMiniPosts(l.Posts, l.client)
+	if err != nil {
+		return err
 	}
-	return l.parsedPosts, err
+	l.parsedPosts, l.decodeErrs = posts, errs
+	return nil
 }
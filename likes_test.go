@@ -34,8 +34,6 @@ func TestGetLikesSuccess(t *testing.T) {
 	)
 	if response, err := GetLikes(client, params); err != nil || response == nil {
 		t.Fatal("Request should succeed")
-	} else if response.response == nil {
-		t.Fatal("Response should be set")
 	} else if response.client != client {
 		t.Fatal("Response should set client")
 	}
@@ -117,20 +115,21 @@ func TestUnlike(t *testing.T) {
 }
 
 func TestFullLikesWithJsonError(t *testing.T) {
-	client := newTestClient("{}", nil)
+	client := newTestClient(`{"response": {"liked_posts": [{"id": 1, "type": "quote"}, {"id": 2, "type": "quote", "text": 5}]}}`, nil)
 	response, _ := GetLikes(client, url.Values{})
 	if response == nil {
 		t.Fatal("Unable to get likes")
 	}
-	if response.parsedPosts != nil {
-		t.Fatal("Parsed posts should start out uninitialized")
+	if len(response.Posts) != 2 || response.Posts[1].Id != 2 {
+		t.Fatal("Mini posts should be decoded along with the full posts")
 	}
-	response.response.body = []byte("{")
-	_, err := response.Full()
-	if err == nil {
-		t.Fatal("JSON Unmarshal failure should be returned")
+	posts, err := response.Full()
+	if err != nil || len(posts) != 2 {
+		t.Fatal("A post failing to decode should not fail the others", err)
+	}
+	if errs := response.DecodeErrors(); len(errs) != 1 || errs[0].Index != 1 {
+		t.Fatal("The post failing to decode should be reported", errs)
 	}
-
 }
 
 func TestFullLikesSuccess(t *testing.T) {
@@ -0,0 +1,208 @@
+package tumblr
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, of the latency histogram used by NewMetrics.
+var DefaultLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Labels identifying a series of requests
+type endpointLabels struct {
+	method   string
+	endpoint string
+}
+
+// Latency histogram of an endpoint
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Metrics collects API usage: request counts per endpoint and status, error counts, latency histograms and
+// the remaining rate limit quota. Endpoints are reduced to their EndpointTemplate to bound the number of series.
+// Metrics implements http.Handler, serving the collected values in the Prometheus text exposition format.
+type Metrics struct {
+	buckets    []float64
+	mutex      sync.Mutex
+	requests   map[endpointLabels]map[string]uint64
+	errors     map[endpointLabels]uint64
+	latencies  map[endpointLabels]*histogram
+	rateLimits RateLimitState
+	limitsSeen bool
+}
+
+// NewMetrics creates an empty Metrics using the given latency buckets, or DefaultLatencyBuckets if there are none.
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) < 1 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+	return &Metrics{
+		buckets:   buckets,
+		requests:  map[endpointLabels]map[string]uint64{},
+		errors:    map[endpointLabels]uint64{},
+		latencies: map[endpointLabels]*histogram{},
+	}
+}
+
+// NewMetricsClient wraps the provided client, collecting the metrics of its requests into metrics.
+func NewMetricsClient(client ClientInterface, metrics *Metrics) *HookClient {
+	return NewHookClient(client, metrics.Hooks())
+}
+
+// Hooks returns the Hooks collecting metrics, for use with a HookClient.
+func (m *Metrics) Hooks() Hooks {
+	return Hooks{AfterResponse: m.observe}
+}
+
+// Records the outcome of a request
+func (m *Metrics) observe(res *ResponseInfo) {
+	labels := endpointLabels{method: res.Request.Method, endpoint: EndpointTemplate(res.Request.Endpoint)}
+	status := "none"
+	if res.Status != 0 {
+		status = strconv.Itoa(res.Status)
+	}
+	limits, found := ParseRateLimitHeaders(res.Response.Headers, res.Request.Start.Add(res.Latency))
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.requests[labels] == nil {
+		m.requests[labels] = map[string]uint64{}
+	}
+	m.requests[labels][status]++
+	if res.Err != nil || res.Status >= 400 {
+		m.errors[labels]++
+	}
+	h := m.latencies[labels]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(m.buckets))}
+		m.latencies[labels] = h
+	}
+	seconds := res.Latency.Seconds()
+	for i, bound := range m.buckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+	if found {
+		m.rateLimits = limits
+		m.limitsSeen = true
+	}
+}
+
+// Escapes a label value for the exposition format
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// Formats a float for the exposition format
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// Returns the labels in a stable order
+func sortedLabels(labels []endpointLabels) []endpointLabels {
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].endpoint != labels[j].endpoint {
+			return labels[i].endpoint < labels[j].endpoint
+		}
+		return labels[i].method < labels[j].method
+	})
+	return labels
+}
+
+// Formats the method and endpoint labels
+func (l endpointLabels) String() string {
+	return fmt.Sprintf(`method="%s",endpoint="%s"`, escapeLabel(l.method), escapeLabel(l.endpoint))
+}
+
+// WriteTo writes the collected metrics in the Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	b := &strings.Builder{}
+
+	b.WriteString("# HELP tumblr_api_requests_total Number of Tumblr API requests by endpoint and status.\n")
+	b.WriteString("# TYPE tumblr_api_requests_total counter\n")
+	labels := []endpointLabels{}
+	for l := range m.requests {
+		labels = append(labels, l)
+	}
+	for _, l := range sortedLabels(labels) {
+		statuses := []string{}
+		for status := range m.requests[l] {
+			statuses = append(statuses, status)
+		}
+		sort.Strings(statuses)
+		for _, status := range statuses {
+			fmt.Fprintf(b, "tumblr_api_requests_total{%s,status=\"%s\"} %d\n", l, status, m.requests[l][status])
+		}
+	}
+
+	b.WriteString("# HELP tumblr_api_errors_total Number of failed Tumblr API requests by endpoint.\n")
+	b.WriteString("# TYPE tumblr_api_errors_total counter\n")
+	for _, l := range sortedLabels(labels) {
+		fmt.Fprintf(b, "tumblr_api_errors_total{%s} %d\n", l, m.errors[l])
+	}
+
+	b.WriteString("# HELP tumblr_api_request_duration_seconds Latency of Tumblr API requests by endpoint.\n")
+	b.WriteString("# TYPE tumblr_api_request_duration_seconds histogram\n")
+	for _, l := range sortedLabels(labels) {
+		h := m.latencies[l]
+		for i, bound := range m.buckets {
+			fmt.Fprintf(b, "tumblr_api_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", l, formatFloat(bound), h.counts[i])
+		}
+		fmt.Fprintf(b, "tumblr_api_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", l, h.count)
+		fmt.Fprintf(b, "tumblr_api_request_duration_seconds_sum{%s} %s\n", l, formatFloat(h.sum))
+		fmt.Fprintf(b, "tumblr_api_request_duration_seconds_count{%s} %d\n", l, h.count)
+	}
+
+	if m.limitsSeen {
+		windows := []struct {
+			name      string
+			remaining int
+			reset     time.Time
+		}{
+			{"day", m.rateLimits.PerDayRemaining, m.rateLimits.PerDayReset},
+			{"hour", m.rateLimits.PerHourRemaining, m.rateLimits.PerHourReset},
+		}
+		b.WriteString("# HELP tumblr_api_ratelimit_remaining Remaining Tumblr API quota by window, as last reported.\n")
+		b.WriteString("# TYPE tumblr_api_ratelimit_remaining gauge\n")
+		for _, window := range windows {
+			if window.remaining >= 0 {
+				fmt.Fprintf(b, "tumblr_api_ratelimit_remaining{window=\"%s\"} %d\n", window.name, window.remaining)
+			}
+		}
+		b.WriteString("# HELP tumblr_api_ratelimit_reset_timestamp_seconds When the Tumblr API quota resets, by window.\n")
+		b.WriteString("# TYPE tumblr_api_ratelimit_reset_timestamp_seconds gauge\n")
+		for _, window := range windows {
+			if !window.reset.IsZero() {
+				fmt.Fprintf(b, "tumblr_api_ratelimit_reset_timestamp_seconds{window=\"%s\"} %d\n", window.name, window.reset.Unix())
+			}
+		}
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// ServeHTTP implements http.Handler.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
@@ -0,0 +1,56 @@
+package tumblr
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsClient(t *testing.T) {
+	metrics := NewMetrics(0.5, 1)
+	inner := newTestClient(`{"meta": {"status": 200}}`, nil)
+	inner.response.Headers = rateLimitHeaders("4000", "3600", "900", "60")
+	client := NewMetricsClient(inner, metrics)
+	tick := time.Unix(1000, 0)
+	client.now = func() time.Time {
+		tick = tick.Add(400 * time.Millisecond)
+		return tick
+	}
+	GetPosts(client, "a", url.Values{})
+	GetPosts(client, "b", url.Values{})
+	inner.response = Response{body: []byte(`{"meta": {"status": 404}}`)}
+	inner.err = errors.New("Not found")
+	GetPosts(client, "c", url.Values{})
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	out := recorder.Body.String()
+	for _, expected := range []string{
+		`tumblr_api_requests_total{method="GET",endpoint="/blog/{blog}/posts",status="200"} 2`,
+		`tumblr_api_requests_total{method="GET",endpoint="/blog/{blog}/posts",status="404"} 1`,
+		`tumblr_api_errors_total{method="GET",endpoint="/blog/{blog}/posts"} 1`,
+		`tumblr_api_request_duration_seconds_bucket{method="GET",endpoint="/blog/{blog}/posts",le="0.5"} 3`,
+		`tumblr_api_request_duration_seconds_bucket{method="GET",endpoint="/blog/{blog}/posts",le="+Inf"} 3`,
+		`tumblr_api_request_duration_seconds_count{method="GET",endpoint="/blog/{blog}/posts"} 3`,
+		`tumblr_api_ratelimit_remaining{window="hour"} 900`,
+		`tumblr_api_ratelimit_reset_timestamp_seconds{window="day"} 4601`,
+		"# TYPE tumblr_api_request_duration_seconds histogram",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected %q in exposition:\n%s", expected, out)
+		}
+	}
+	if strings.Contains(out, "a.tumblr.com") {
+		t.Fatal("Blog names should not be used as labels")
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	if escaped := escapeLabel("a\"b\\c\nd"); escaped != `a\"b\\c\nd` {
+		t.Fatalf("Unexpected escaped label %s", escaped)
+	}
+}
@@ -0,0 +1,148 @@
+package tumblr
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Middleware decorates a ClientInterface, e.g. NewRetryClient or WithHooks.
+type Middleware func(ClientInterface) ClientInterface
+
+// Chain wraps client in the given middleware. The first middleware is the outermost one, seeing requests first.
+func Chain(client ClientInterface, middleware ...Middleware) ClientInterface {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		client = middleware[i](client)
+	}
+	return client
+}
+
+// RequestInfo describes a request about to be issued. The same pointer is passed to the AfterResponse hooks,
+// so hooks can use it to correlate both calls.
+type RequestInfo struct {
+	Method   string
+	Endpoint string
+	Params   url.Values
+	Start    time.Time
+}
+
+// ResponseInfo describes the outcome of a request.
+type ResponseInfo struct {
+	Request *RequestInfo
+	// Status reported by the API, 0 if there was no response
+	Status  int
+	Latency time.Duration
+	// Size of the response body
+	Bytes    int
+	Err      error
+	Response Response
+}
+
+// Hooks observe the requests issued through a HookClient; either function may be nil.
+type Hooks struct {
+	BeforeRequest func(req *RequestInfo)
+	AfterResponse func(res *ResponseInfo)
+}
+
+// HookClient wraps a ClientInterface, calling hooks around every request.
+type HookClient struct {
+	client ClientInterface
+	hooks  []Hooks
+	now    func() time.Time
+}
+
+// NewHookClient wraps the provided client. BeforeRequest hooks are called in order, AfterResponse hooks in
+// reverse order.
+func NewHookClient(client ClientInterface, hooks ...Hooks) *HookClient {
+	return &HookClient{client: client, hooks: hooks, now: time.Now}
+}
+
+// WithHooks returns a Middleware wrapping clients in a HookClient.
+func WithHooks(hooks ...Hooks) Middleware {
+	return func(client ClientInterface) ClientInterface {
+		return NewHookClient(client, hooks...)
+	}
+}
+
+// Issues the request between the hooks
+func (c *HookClient) do(method, endpoint string, params url.Values) (Response, error) {
+	req := &RequestInfo{Method: method, Endpoint: endpoint, Params: params, Start: c.now()}
+	for _, hook := range c.hooks {
+		if hook.BeforeRequest != nil {
+			hook.BeforeRequest(req)
+		}
+	}
+	response, err := callClient(c.client, method, endpoint, params)
+	res := &ResponseInfo{
+		Request:  req,
+		Status:   response.StatusCode(),
+		Latency:  c.now().Sub(req.Start),
+		Bytes:    len(response.GetBody()),
+		Err:      err,
+		Response: response,
+	}
+	for i := len(c.hooks) - 1; i >= 0; i-- {
+		if c.hooks[i].AfterResponse != nil {
+			c.hooks[i].AfterResponse(res)
+		}
+	}
+	return response, err
+}
+
+// Get implements ClientInterface.
+func (c *HookClient) Get(endpoint string) (Response, error) {
+	return c.do(http.MethodGet, endpoint, nil)
+}
+
+// GetWithParams implements ClientInterface.
+func (c *HookClient) GetWithParams(endpoint string, params url.Values) (Response, error) {
+	return c.do(http.MethodGet, endpoint, params)
+}
+
+// Post implements ClientInterface.
+func (c *HookClient) Post(endpoint string) (Response, error) {
+	return c.do(http.MethodPost, endpoint, nil)
+}
+
+// PostWithParams implements ClientInterface.
+func (c *HookClient) PostWithParams(endpoint string, params url.Values) (Response, error) {
+	return c.do(http.MethodPost, endpoint, params)
+}
+
+// Put implements ClientInterface.
+func (c *HookClient) Put(endpoint string) (Response, error) {
+	return c.do(http.MethodPut, endpoint, nil)
+}
+
+// PutWithParams implements ClientInterface.
+func (c *HookClient) PutWithParams(endpoint string, params url.Values) (Response, error) {
+	return c.do(http.MethodPut, endpoint, params)
+}
+
+// Delete implements ClientInterface.
+func (c *HookClient) Delete(endpoint string) (Response, error) {
+	return c.do(http.MethodDelete, endpoint, nil)
+}
+
+// DeleteWithParams implements ClientInterface.
+func (c *HookClient) DeleteWithParams(endpoint string, params url.Values) (Response, error) {
+	return c.do(http.MethodDelete, endpoint, params)
+}
+
+// EndpointTemplate reduces an endpoint to its template, replacing the blog identifier with {blog} and numeric
+// ids with {id}, e.g. "/blog/{blog}/posts/{id}". Query strings are dropped.
+func EndpointTemplate(endpoint string) string {
+	if i := strings.Index(endpoint, "?"); i >= 0 {
+		endpoint = endpoint[:i]
+	}
+	_, template := splitBlogPath(endpoint)
+	segments := strings.Split(template, "/")
+	for i, segment := range segments {
+		if _, err := strconv.ParseUint(segment, 10, 64); err == nil {
+			segments[i] = "{id}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
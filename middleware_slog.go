@@ -0,0 +1,35 @@
+package tumblr
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogHooks returns Hooks logging every response to logger: failures at the error level, error statuses at
+// the warning level and everything else at the info level. Credentials in params are redacted.
+func SlogHooks(logger *slog.Logger) Hooks {
+	return Hooks{
+		AfterResponse: func(res *ResponseInfo) {
+			level := slog.LevelInfo
+			if res.Err != nil {
+				level = slog.LevelError
+			} else if res.Status >= 400 {
+				level = slog.LevelWarn
+			}
+			// the query string is logged among the params, so credentials it carries are redacted too
+			path, params := redactRequest(res.Request.Endpoint, res.Request.Params)
+			attrs := []slog.Attr{
+				slog.String("method", res.Request.Method),
+				slog.String("endpoint", path),
+				slog.String("params", params.Encode()),
+				slog.Int("status", res.Status),
+				slog.Duration("latency", res.Latency),
+				slog.Int("bytes", res.Bytes),
+			}
+			if res.Err != nil {
+				attrs = append(attrs, slog.String("error", res.Err.Error()))
+			}
+			logger.LogAttrs(context.Background(), level, "tumblr API request", attrs...)
+		},
+	}
+}
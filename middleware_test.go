@@ -0,0 +1,95 @@
+package tumblr
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEndpointTemplate(t *testing.T) {
+	cases := map[string]string{
+		"/blog/b.tumblr.com/posts":          "/blog/{blog}/posts",
+		"/blog/b.tumblr.com/posts/1234":     "/blog/{blog}/posts/{id}",
+		"/blog/b.tumblr.com/info?api_key=x": "/blog/{blog}/info",
+		"/user/dashboard":                   "/user/dashboard",
+		"/blog/b.tumblr.com/posts/text":     "/blog/{blog}/posts/text",
+	}
+	for endpoint, expected := range cases {
+		if actual := EndpointTemplate(endpoint); actual != expected {
+			t.Errorf("Expected template %s for %s, got %s", expected, endpoint, actual)
+		}
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	calls := []string{}
+	tag := func(name string) Middleware {
+		return WithHooks(Hooks{BeforeRequest: func(*RequestInfo) { calls = append(calls, name) }})
+	}
+	client := Chain(newTestClient("{}", nil), tag("outer"), tag("inner"))
+	client.Get("/user/info")
+	if strings.Join(calls, ",") != "outer,inner" {
+		t.Fatalf("Middleware called in the wrong order: %v", calls)
+	}
+}
+
+func TestHookClient(t *testing.T) {
+	inner := newTestClient(`{"meta": {"status": 200}}`, nil)
+	var before *RequestInfo
+	var after *ResponseInfo
+	client := NewHookClient(inner, Hooks{
+		BeforeRequest: func(req *RequestInfo) { before = req },
+		AfterResponse: func(res *ResponseInfo) { after = res },
+	})
+	tick := time.Unix(1000, 0)
+	client.now = func() time.Time {
+		tick = tick.Add(time.Second)
+		return tick
+	}
+	client.GetWithParams("/blog/b.tumblr.com/posts", url.Values{"limit": []string{"5"}})
+	if before == nil || before.Method != http.MethodGet || before.Params.Get("limit") != "5" {
+		t.Fatalf("Unexpected request info %+v", before)
+	}
+	if after == nil || after.Request != before || after.Status != 200 || after.Latency != time.Second || after.Bytes != 25 {
+		t.Fatalf("Unexpected response info %+v", after)
+	}
+}
+
+func TestSlogHooks(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(out, nil))
+	client := NewHookClient(newTestClient("", errors.New("Network error")), SlogHooks(logger))
+	client.GetWithParams("/tagged?api_key=secret", url.Values{"tag": []string{"go"}})
+	logged := out.String()
+	for _, expected := range []string{"level=ERROR", "endpoint=/tagged ", "tag=go", `error="Network error"`} {
+		if !strings.Contains(logged, expected) {
+			t.Fatalf("Expected %q in log %q", expected, logged)
+		}
+	}
+	if strings.Contains(logged, "secret") {
+		t.Fatal("Credentials should not be logged")
+	}
+}
+
+func TestTracingHooks(t *testing.T) {
+	recorder := NewSpanRecorder()
+	failure := errors.New("Server error")
+	client := NewHookClient(newTestClient(`{"meta": {"status": 503}}`, failure), TracingHooks(recorder))
+	client.PostWithParams("/blog/b.tumblr.com/post/delete?api_key=secret", url.Values{"id": []string{"12"}})
+	spans := recorder.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("Expected one span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "POST /blog/{blog}/post/delete" || span.Attributes["url.path"] != "/blog/b.tumblr.com/post/delete" || !span.Ended() {
+		t.Fatalf("Unexpected span %+v", span)
+	}
+	if span.Attributes["http.response.status_code"] != 503 || len(span.Errors) != 1 || span.Errors[0] != failure {
+		t.Fatalf("Span should carry the status and error: %+v", span)
+	}
+}
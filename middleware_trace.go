@@ -0,0 +1,135 @@
+package tumblr
+
+import (
+	"sync"
+	"time"
+)
+
+// Tracer starts spans, following the OpenTelemetry model. An adapter for an OpenTelemetry tracer only needs
+// to forward these calls; SpanRecorder is an in-memory implementation for tests.
+type Tracer interface {
+	StartSpan(name string, attributes map[string]interface{}) Span
+}
+
+// Span is a traced operation, following the OpenTelemetry model.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// TracingHooks returns Hooks tracing every request as a span named after the method and endpoint template,
+// with OpenTelemetry semantic convention attributes. The query string is left out, as it may carry credentials.
+func TracingHooks(tracer Tracer) Hooks {
+	spans := sync.Map{}
+	return Hooks{
+		BeforeRequest: func(req *RequestInfo) {
+			template := EndpointTemplate(req.Endpoint)
+			path, _ := redactRequest(req.Endpoint, nil)
+			spans.Store(req, tracer.StartSpan(req.Method+" "+template, map[string]interface{}{
+				"http.request.method": req.Method,
+				"url.path":            path,
+				"http.route":          template,
+			}))
+		},
+		AfterResponse: func(res *ResponseInfo) {
+			stored, ok := spans.LoadAndDelete(res.Request)
+			if !ok {
+				return
+			}
+			span := stored.(Span)
+			if res.Status != 0 {
+				span.SetAttribute("http.response.status_code", res.Status)
+			}
+			span.SetAttribute("http.response.body.size", res.Bytes)
+			if res.Err != nil {
+				span.RecordError(res.Err)
+			}
+			span.End()
+		},
+	}
+}
+
+// RecordedSpan is a span captured by a SpanRecorder.
+type RecordedSpan struct {
+	Name       string
+	Attributes map[string]interface{}
+	Errors     []error
+	Start      time.Time
+	End        time.Time
+}
+
+// Ended reports whether the span has ended.
+func (s RecordedSpan) Ended() bool {
+	return !s.End.IsZero()
+}
+
+// SpanRecorder is a Tracer keeping spans in memory, so traces can be checked without a collector.
+type SpanRecorder struct {
+	mutex sync.Mutex
+	spans []*RecordedSpan
+	now   func() time.Time
+}
+
+// NewSpanRecorder creates an empty SpanRecorder.
+func NewSpanRecorder() *SpanRecorder {
+	return &SpanRecorder{now: time.Now}
+}
+
+// A span being recorded by a SpanRecorder
+type recorderSpan struct {
+	recorder *SpanRecorder
+	span     *RecordedSpan
+}
+
+// StartSpan implements Tracer.
+func (r *SpanRecorder) StartSpan(name string, attributes map[string]interface{}) Span {
+	span := &RecordedSpan{Name: name, Attributes: map[string]interface{}{}, Start: r.now()}
+	for key, value := range attributes {
+		span.Attributes[key] = value
+	}
+	r.mutex.Lock()
+	r.spans = append(r.spans, span)
+	r.mutex.Unlock()
+	return &recorderSpan{recorder: r, span: span}
+}
+
+// Spans returns copies of the spans recorded so far, in the order they started.
+func (r *SpanRecorder) Spans() []RecordedSpan {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	spans := make([]RecordedSpan, len(r.spans))
+	for i, span := range r.spans {
+		spans[i] = *span
+		spans[i].Attributes = map[string]interface{}{}
+		for key, value := range span.Attributes {
+			spans[i].Attributes[key] = value
+		}
+		spans[i].Errors = append([]error{}, span.Errors...)
+	}
+	return spans
+}
+
+// SetAttribute implements Span.
+func (s *recorderSpan) SetAttribute(key string, value interface{}) {
+	s.recorder.mutex.Lock()
+	defer s.recorder.mutex.Unlock()
+	s.span.Attributes[key] = value
+}
+
+// RecordError implements Span.
+func (s *recorderSpan) RecordError(err error) {
+	s.recorder.mutex.Lock()
+	defer s.recorder.mutex.Unlock()
+	s.span.Errors = append(s.span.Errors, err)
+}
+
+// End implements Span.
+func (s *recorderSpan) End() {
+	now := s.recorder.now()
+	s.recorder.mutex.Lock()
+	defer s.recorder.mutex.Unlock()
+	if s.span.End.IsZero() {
+		s.span.End = now
+	}
+}
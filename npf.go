@@ -0,0 +1,167 @@
+package tumblr
+
+import (
+	"encoding/json"
+	"unicode/utf16"
+)
+
+// ContentBlock is a single block of Neue Post Format (NPF) content.
+// Which fields are populated depends on the block's Type (text, image, link, audio, video).
+type ContentBlock struct {
+	Type string `json:"type"`
+	// text blocks
+	Subtype     string           `json:"subtype,omitempty"`
+	Text        string           `json:"text,omitempty"`
+	IndentLevel int              `json:"indent_level,omitempty"`
+	Formatting  []TextFormatting `json:"formatting,omitempty"`
+	// media blocks
+	Media       MediaList         `json:"media,omitempty"`
+	Poster      MediaList         `json:"poster,omitempty"`
+	Colors      map[string]string `json:"colors,omitempty"`
+	AltText     string            `json:"alt_text,omitempty"`
+	Caption     string            `json:"caption,omitempty"`
+	Attribution *Attribution      `json:"attribution,omitempty"`
+	Provider    string            `json:"provider,omitempty"`
+	EmbedHtml   string            `json:"embed_html,omitempty"`
+	EmbedUrl    string            `json:"embed_url,omitempty"`
+	Artist      string            `json:"artist,omitempty"`
+	Album       string            `json:"album,omitempty"`
+	// link, audio and video blocks
+	Url         string `json:"url,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Author      string `json:"author,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+	DisplayUrl  string `json:"display_url,omitempty"`
+}
+
+// TextFormatting is an inline formatting range of a text block. Start and End are UTF-16 offsets.
+type TextFormatting struct {
+	Start int          `json:"start"`
+	End   int          `json:"end"`
+	Type  string       `json:"type"`
+	Url   string       `json:"url,omitempty"`
+	Blog  *BlogMention `json:"blog,omitempty"`
+	Hex   string       `json:"hex,omitempty"`
+}
+
+// BlogMention is the minimal blog object NPF uses for mentions and attributions.
+type BlogMention struct {
+	Uuid string `json:"uuid,omitempty"`
+	Name string `json:"name,omitempty"`
+	Url  string `json:"url,omitempty"`
+}
+
+// MediaObject describes one rendition of a media file.
+type MediaObject struct {
+	Url                       string `json:"url"`
+	Type                      string `json:"type,omitempty"`
+	Width                     uint32 `json:"width,omitempty"`
+	Height                    uint32 `json:"height,omitempty"`
+	OriginalDimensionsMissing bool   `json:"original_dimensions_missing,omitempty"`
+	Cropped                   bool   `json:"cropped,omitempty"`
+	HasOriginalDimensions     bool   `json:"has_original_dimensions,omitempty"`
+	Identifier                string `json:"identifier,omitempty"`
+}
+
+// MediaList is a list of MediaObjects that can be unmarshalled from a single object or an array.
+type MediaList []MediaObject
+
+// UnmarshalJSON implements the json.Unmarshaler interface to ingest objects or arrays.
+func (m *MediaList) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '{' {
+		var single MediaObject
+		if err := json.Unmarshal(b, &single); err != nil {
+			return err
+		}
+		*m = MediaList{single}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]MediaObject)(m))
+}
+
+// Attribution describes where a content block (or an ask) came from.
+type Attribution struct {
+	Type string `json:"type"`
+	Url  string `json:"url,omitempty"`
+	Post *struct {
+		Id string `json:"id"`
+	} `json:"post,omitempty"`
+	Blog        *BlogMention `json:"blog,omitempty"`
+	AppName     string       `json:"app_name,omitempty"`
+	DisplayText string       `json:"display_text,omitempty"`
+	Logo        *MediaObject `json:"logo,omitempty"`
+}
+
+// LayoutBlock describes how content blocks are arranged (rows, ask or condensed).
+type LayoutBlock struct {
+	Type          string          `json:"type"`
+	Display       []LayoutDisplay `json:"display,omitempty"`
+	TruncateAfter *int            `json:"truncate_after,omitempty"`
+	Blocks        []int           `json:"blocks,omitempty"`
+	Attribution   *Attribution    `json:"attribution,omitempty"`
+}
+
+// LayoutDisplay is a single row of a rows layout, referencing content blocks by index.
+type LayoutDisplay struct {
+	Blocks []int `json:"blocks"`
+	Mode   *struct {
+		Type string `json:"type"`
+	} `json:"mode,omitempty"`
+}
+
+// MarshalJSON implements the json.Marshaler interface. Audio and video blocks carry a single media
+// object and image blocks a single poster, so those lists are written as plain objects.
+func (b ContentBlock) MarshalJSON() ([]byte, error) {
+	type plain ContentBlock
+	out := struct {
+		plain
+		Media  interface{} `json:"media,omitempty"`
+		Poster interface{} `json:"poster,omitempty"`
+	}{plain: plain(b)}
+	if len(b.Media) > 0 {
+		if b.Type == "audio" || b.Type == "video" {
+			out.Media = b.Media[0]
+		} else {
+			out.Media = []MediaObject(b.Media)
+		}
+	}
+	if len(b.Poster) > 0 {
+		if b.Type == "image" {
+			out.Poster = b.Poster[0]
+		} else {
+			out.Poster = []MediaObject(b.Poster)
+		}
+	}
+	return json.Marshal(out)
+}
+
+// Returns the ask layout of a post's layout, if any
+func askLayout(layout []LayoutBlock) *LayoutBlock {
+	for i := range layout {
+		if layout[i].Type == "ask" {
+			return &layout[i]
+		}
+	}
+	return nil
+}
+
+// Length of a string in UTF-16 code units, which is how NPF measures formatting ranges
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+// Substring of s between two UTF-16 offsets, clamped to the string's length
+func utf16Slice(s string, start, end int) string {
+	units := utf16.Encode([]rune(s))
+	if end > len(units) {
+		end = len(units)
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start >= end {
+		return ""
+	}
+	return string(utf16.Decode(units[start:end]))
+}
@@ -0,0 +1,407 @@
+package tumblr
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// NPFConversion holds the NPF content built from a legacy post, along with a note for everything that
+// could only be approximated.
+type NPFConversion struct {
+	Content []ContentBlock
+	Layout  []LayoutBlock
+	// Trail items of a reblog with their HTML converted into content blocks
+	Trail          []ReblogTrailItem
+	Approximations []string
+}
+
+// LegacyConversion holds the legacy post built from NPF content, along with a note for everything that
+// could only be approximated.
+type LegacyConversion struct {
+	Post           PostInterface
+	Approximations []string
+}
+
+// Records an approximation made during a conversion
+func (c *NPFConversion) approximate(format string, args ...interface{}) {
+	c.Approximations = appendApproximation(c.Approximations, fmt.Sprintf(format, args...))
+}
+
+// Records an approximation made during a conversion
+func (c *LegacyConversion) approximate(format string, args ...interface{}) {
+	c.Approximations = appendApproximation(c.Approximations, fmt.Sprintf(format, args...))
+}
+
+// Appends a note unless the same note was already recorded
+func appendApproximation(notes []string, note string) []string {
+	for _, n := range notes {
+		if n == note {
+			return notes
+		}
+	}
+	return append(notes, note)
+}
+
+// LegacyToNPF converts a legacy post (TextPost, PhotoPost, ChatPost, ...) into NPF content blocks and layout.
+// Posts which already carry NPF content are returned as-is.
+//
+// The body or caption of a legacy reblog repeats the whole trail. As NPF keeps the trail apart, the trail items
+// of other blogs are converted into Trail and the content only holds what the post itself added, taken from
+// its current trail item.
+func LegacyToNPF(post PostInterface) (*NPFConversion, error) {
+	if post == nil {
+		return nil, errors.New("No post provided")
+	}
+	c := &NPFConversion{}
+	self := post.GetSelf()
+	own := c.convertTrail(self.Trail)
+	switch p := post.(type) {
+	case *TextPost:
+		if p.Title != "" {
+			c.Content = append(c.Content, ContentBlock{Type: "text", Subtype: "heading1", Text: p.Title})
+		}
+		c.addHTML(own(p.Body), "")
+	case *PhotoPost:
+		for _, photo := range p.Photos {
+			c.Content = append(c.Content, photoToImageBlock(photo))
+		}
+		c.addHTML(own(p.Caption), "")
+	case *QuotePost:
+		c.addHTML(p.Text, "quote")
+		// the source keeps its formatting, such as links, in text blocks of its own
+		c.addHTML(p.Source, "")
+	case *LinkPost:
+		c.Content = append(c.Content, ContentBlock{
+			Type:        "link",
+			Url:         p.Url,
+			Title:       p.Title,
+			Description: p.Excerpt,
+			Author:      p.LinkAuthor,
+		})
+		c.addHTML(own(p.Description), "")
+	case *ChatPost:
+		for _, line := range p.Dialog {
+			block := ContentBlock{Type: "text", Subtype: "chat", Text: line.Phrase}
+			if line.Label != "" {
+				block.Text = line.Label + " " + line.Phrase
+				block.Formatting = []TextFormatting{{Start: 0, End: utf16Len(line.Label), Type: "bold"}}
+			}
+			c.Content = append(c.Content, block)
+		}
+		if len(p.Dialog) > 0 {
+			c.approximate("chat dialog converted to chat-subtype text blocks")
+		}
+	case *AnswerPost:
+		c.addHTML(p.Question, "")
+		ask := LayoutBlock{Type: "ask"}
+		for i := range c.Content {
+			ask.Blocks = append(ask.Blocks, i)
+		}
+		if p.AskingName != "" && p.AskingName != "Anonymous" {
+			ask.Attribution = &Attribution{
+				Type: "blog",
+				Blog: &BlogMention{Name: p.AskingName, Url: p.AskingUrl},
+			}
+		}
+		c.Layout = append(c.Layout, ask)
+		c.addHTML(own(p.Answer), "")
+	case *AudioPost:
+		block := ContentBlock{
+			Type:      "audio",
+			Provider:  p.AudioType,
+			Url:       p.AudioSourceUrl,
+			Title:     p.TrackName,
+			Artist:    p.Artist,
+			EmbedHtml: p.Embed,
+		}
+		if p.AudioUrl != "" {
+			block.Media = MediaList{{Url: p.AudioUrl}}
+		}
+		if p.AlbumArt != "" {
+			block.Poster = MediaList{{Url: p.AlbumArt}}
+		}
+		c.Content = append(c.Content, block)
+		c.addHTML(own(p.Caption), "")
+	case *VideoPost:
+		block := ContentBlock{Type: "video", Provider: p.VideoType, Url: p.PermalinkUrl}
+		if p.VideoUrl != "" {
+			block.Media = MediaList{{Url: p.VideoUrl, Type: "video/mp4"}}
+		}
+		if p.ThumbnailUrl != "" {
+			block.Poster = MediaList{{Url: p.ThumbnailUrl, Width: p.ThumbnailWidth, Height: p.ThumbnailHeight}}
+		}
+		// the last player is the largest one
+		if n := len(p.Players); n > 0 && p.Players[n-1].EmbedCode != "false" {
+			block.EmbedHtml = string(p.Players[n-1].EmbedCode)
+		}
+		c.Content = append(c.Content, block)
+		c.addHTML(own(p.Caption), "")
+	default:
+		if len(self.Content) > 0 {
+			c.Content = self.Content
+			c.Layout = self.Layout
+			return c, nil
+		}
+		return nil, errors.New(fmt.Sprintf("Unable to convert post of type %s", self.Type))
+	}
+	if len(c.Trail) > 0 {
+		switch post.(type) {
+		case *PhotoPost, *QuotePost, *LinkPost, *ChatPost, *AudioPost, *VideoPost:
+			c.approximate("reblogged %s kept in the post content rather than the trail", self.Type)
+		}
+	}
+	return c, nil
+}
+
+// Converts the trail items of other blogs into Trail, and returns a function choosing the post's own HTML
+// over a legacy field which repeats the trail. Posts which are not reblogs keep their fields as they are.
+func (c *NPFConversion) convertTrail(trail []ReblogTrailItem) func(string) string {
+	current := ""
+	for _, item := range trail {
+		if item.IsCurrentItem {
+			current = item.Content
+			continue
+		}
+		if len(item.ContentBlocks) < 1 {
+			item.ContentBlocks = ParseHTML(item.Content)
+		}
+		c.Trail = append(c.Trail, item)
+	}
+	return func(body string) string {
+		if len(c.Trail) > 0 {
+			return current
+		}
+		return body
+	}
+}
+
+// Converts a legacy photo into an image block, listing the original size first
+func photoToImageBlock(photo Photo) ContentBlock {
+	block := ContentBlock{Type: "image", Caption: htmlToPlainText(photo.Caption)}
+	seen := map[string]bool{}
+	for _, size := range append([]PhotoSize{photo.OriginalSize}, photo.AltSizes...) {
+		if size.Url == "" || seen[size.Url] {
+			continue
+		}
+		seen[size.Url] = true
+		block.Media = append(block.Media, MediaObject{Url: size.Url, Width: size.Width, Height: size.Height})
+	}
+	return block
+}
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// Appends an HTML field of a legacy post as NPF blocks, applying the subtype (if any) to its text blocks
+func (c *NPFConversion) addHTML(body, subtype string) {
+	for _, block := range ParseHTML(body) {
+		if subtype != "" && block.Type == "text" {
+			block.Subtype = subtype
+		}
+		c.Content = append(c.Content, block)
+	}
+}
+
+// Strips all markup from an HTML fragment
+func htmlToPlainText(s string) string {
+	return strings.TrimSpace(html.UnescapeString(htmlTagPattern.ReplaceAllString(s, "")))
+}
+
+// NPFToLegacy converts a post's NPF content and layout into the closest legacy post type, rendering
+// blocks that type cannot hold into its HTML body or caption. The trail is kept as it is, so a reblog which
+// added nothing becomes a text post with an empty body.
+func NPFToLegacy(post PostInterface) (*LegacyConversion, error) {
+	if post == nil {
+		return nil, errors.New("No post provided")
+	}
+	self := post.GetSelf()
+	if len(self.Content) < 1 && len(self.Trail) < 1 {
+		return nil, errors.New("Post has no NPF content")
+	}
+	c := &LegacyConversion{}
+	base := *self
+	base.Content, base.Layout = nil, nil
+	base.Format = "html"
+	content := self.Content
+	for _, l := range self.Layout {
+		for _, row := range l.Display {
+			if len(row.Blocks) > 1 {
+				c.approximate("rows layout flattened to one block per row")
+				break
+			}
+		}
+	}
+
+	counts := map[string]int{}
+	for _, block := range content {
+		counts[block.Type]++
+		if block.Subtype == "chat" {
+			counts["chat"]++
+		}
+	}
+	media := counts["image"] + counts["link"] + counts["audio"] + counts["video"]
+	// reblogs which added nothing have no content, and fall through to a text post
+	first := ContentBlock{}
+	if len(content) > 0 {
+		first = content[0]
+	}
+
+	switch {
+	case askLayout(self.Layout) != nil:
+		ask := askLayout(self.Layout)
+		inAsk := map[int]bool{}
+		for _, i := range ask.Blocks {
+			inAsk[i] = true
+		}
+		var question, answer []ContentBlock
+		for i, block := range content {
+			if inAsk[i] {
+				question = append(question, block)
+			} else {
+				answer = append(answer, block)
+			}
+		}
+		p := &AnswerPost{Post: base, AskingName: "Anonymous"}
+		if ask.Attribution != nil && ask.Attribution.Blog != nil {
+			p.AskingName = ask.Attribution.Blog.Name
+			p.AskingUrl = ask.Attribution.Blog.Url
+		}
+		p.Question = c.render(question)
+		p.Answer = c.render(answer)
+		p.Type = "answer"
+		c.Post = p
+	case counts["image"] > 0 && counts["image"] == media:
+		p := &PhotoPost{Post: base}
+		var rest []ContentBlock
+		for _, block := range content {
+			if block.Type != "image" {
+				rest = append(rest, block)
+				continue
+			}
+			if len(rest) > 0 {
+				c.approximate("text preceding images moved into the caption")
+			}
+			p.Photos = append(p.Photos, imageBlockToPhoto(block))
+		}
+		p.Caption = c.render(rest)
+		p.Type = "photo"
+		c.Post = p
+	case first.Type == "link" && media == 1:
+		p := &LinkPost{Post: base, Url: first.Url, Title: first.Title, Excerpt: first.Description, LinkAuthor: first.Author}
+		p.Description = c.render(content[1:])
+		p.Type = "link"
+		c.Post = p
+	case first.Type == "audio" && media == 1:
+		p := &AudioPost{Post: base, AudioSourceUrl: first.Url, AudioType: first.Provider, Artist: first.Artist, Embed: first.EmbedHtml}
+		p.TrackName = first.Title
+		if len(first.Media) > 0 {
+			p.AudioUrl = first.Media[0].Url
+		}
+		if len(first.Poster) > 0 {
+			p.AlbumArt = first.Poster[0].Url
+		}
+		p.Caption = c.render(content[1:])
+		p.Type = "audio"
+		c.Post = p
+	case first.Type == "video" && media == 1:
+		p := &VideoPost{Post: base, VideoType: first.Provider, PermalinkUrl: first.Url}
+		if len(first.Media) > 0 {
+			p.VideoUrl = first.Media[0].Url
+		}
+		if len(first.Poster) > 0 {
+			p.ThumbnailUrl = first.Poster[0].Url
+			p.ThumbnailWidth = first.Poster[0].Width
+			p.ThumbnailHeight = first.Poster[0].Height
+		}
+		if first.EmbedHtml != "" {
+			p.Players = append(p.Players, struct {
+				EmbedCode StringOrBool `json:"embed_code"`
+				Width     interface{}  `json:"width"`
+			}{EmbedCode: StringOrBool(first.EmbedHtml)})
+		}
+		p.Caption = c.render(content[1:])
+		p.Type = "video"
+		c.Post = p
+	case len(content) > 0 && counts["chat"] == len(content):
+		p := &ChatPost{Post: base}
+		for _, block := range content {
+			label, phrase := splitChatLine(block)
+			p.Dialog = append(p.Dialog, struct {
+				Label  string `json:"label"`
+				Name   string `json:"name"`
+				Phrase string `json:"phrase"`
+			}{Label: label, Name: strings.TrimSuffix(label, ":"), Phrase: phrase})
+		}
+		p.Body = c.render(content)
+		p.Type = "chat"
+		c.approximate("chat-subtype text blocks converted to dialog lines")
+		c.Post = p
+	case first.Type == "text" && first.Subtype == "quote" && media == 0:
+		// the leading quote blocks make the quote, keeping their formatting
+		quotes := 1
+		for quotes < len(content) && content[quotes].Type == "text" && content[quotes].Subtype == "quote" {
+			quotes++
+		}
+		p := &QuotePost{Post: base}
+		for _, block := range content[:quotes] {
+			text := renderInline(block.Text, block.Formatting, htmlInline{})
+			if quotes > 1 {
+				text = "<p>" + text + "</p>"
+			}
+			p.Text += text
+		}
+		p.Source = c.render(content[quotes:])
+		p.Type = "quote"
+		c.Post = p
+	default:
+		p := &TextPost{Post: base}
+		if first.Type == "text" && first.Subtype == "heading1" {
+			p.Title = first.Text
+			content = content[1:]
+		}
+		p.Body = c.render(content)
+		p.Type = "text"
+		c.Post = p
+	}
+	return c, nil
+}
+
+// Converts an image block into a legacy photo, using the widest media as the original size
+func imageBlockToPhoto(block ContentBlock) Photo {
+	photo := Photo{Caption: block.Caption}
+	for _, m := range block.Media {
+		size := PhotoSize{Url: m.Url, Width: m.Width, Height: m.Height}
+		if m.Width >= photo.OriginalSize.Width || photo.OriginalSize.Url == "" {
+			photo.OriginalSize = size
+		}
+		photo.AltSizes = append(photo.AltSizes, size)
+	}
+	return photo
+}
+
+// Splits a chat text block into its label and phrase, preferring a bold range at the start as the label
+func splitChatLine(block ContentBlock) (string, string) {
+	for _, f := range block.Formatting {
+		if f.Type == "bold" && f.Start == 0 && f.End > 0 {
+			return utf16Slice(block.Text, 0, f.End), strings.TrimSpace(utf16Slice(block.Text, f.End, utf16Len(block.Text)))
+		}
+	}
+	if i := strings.Index(block.Text, ":"); i >= 0 {
+		return block.Text[:i+1], strings.TrimSpace(block.Text[i+1:])
+	}
+	return "", block.Text
+}
+
+// Renders content blocks as a legacy-style HTML body
+func (c *LegacyConversion) render(blocks []ContentBlock) string {
+	for _, block := range blocks {
+		switch block.Type {
+		case "text", "image", "link", "audio", "video":
+		default:
+			c.approximate("%s block dropped", block.Type)
+		}
+	}
+	return (&NPFRenderer{AllowEmbeds: true}).HTML(blocks, nil)
+}
@@ -0,0 +1,222 @@
+package tumblr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLegacyToNPFTextPost(t *testing.T) {
+	post := &TextPost{Title: "Hello", Post: Post{Body: "<p>First &amp; foremost</p><p>Second <b>bold</b></p><img src=\"https://64.media.tumblr.com/a.jpg\"/>"}}
+	c, err := LegacyToNPF(post)
+	if err != nil {
+		t.Fatal("Unexpected conversion error", err)
+	}
+	if len(c.Content) != 4 {
+		t.Fatalf("Expected 4 blocks, got %d", len(c.Content))
+	}
+	if c.Content[0].Subtype != "heading1" || c.Content[0].Text != "Hello" {
+		t.Fatal("Title should become a heading1 block")
+	}
+	if c.Content[1].Text != "First & foremost" || c.Content[2].Text != "Second bold" {
+		t.Fatal("Body paragraphs should become unescaped text blocks")
+	}
+	if f := c.Content[2].Formatting; len(f) != 1 || f[0].Type != "bold" || f[0].Start != 7 || f[0].End != 11 {
+		t.Fatal("Inline formatting should be kept", f)
+	}
+	if c.Content[3].Type != "image" || c.Content[3].Media[0].Url != "https://64.media.tumblr.com/a.jpg" {
+		t.Fatal("Inline images should become image blocks")
+	}
+	if len(c.Approximations) != 0 {
+		t.Fatal("Text posts should convert without approximations", c.Approximations)
+	}
+}
+
+func TestLegacyToNPFChatPost(t *testing.T) {
+	// the body repeats the dialog as text, so only the dialog is converted
+	post := &ChatPost{Post: Post{Body: "Ädam: hi"}}
+	post.Dialog = append(post.Dialog, struct {
+		Label  string `json:"label"`
+		Name   string `json:"name"`
+		Phrase string `json:"phrase"`
+	}{Label: "Ädam:", Name: "Ädam", Phrase: "hi"})
+	c, err := LegacyToNPF(post)
+	if err != nil {
+		t.Fatal("Unexpected conversion error", err)
+	}
+	if len(c.Content) != 1 {
+		t.Fatal("Chat should be converted once", c.Content)
+	}
+	block := c.Content[0]
+	if block.Subtype != "chat" || block.Text != "Ädam: hi" {
+		t.Fatal("Dialog should become chat subtype blocks", block)
+	}
+	if len(block.Formatting) != 1 || block.Formatting[0].End != 5 {
+		t.Fatal("Label should be bolded using UTF-16 offsets", block.Formatting)
+	}
+	if len(c.Approximations) != 1 {
+		t.Fatal("Chat conversion should be reported as an approximation")
+	}
+}
+
+func TestLegacyToNPFAnswerPost(t *testing.T) {
+	post := &AnswerPost{Question: "Why?", AskingName: "asker", Answer: "<p>Because</p>"}
+	c, _ := LegacyToNPF(post)
+	if len(c.Layout) != 1 || c.Layout[0].Type != "ask" || len(c.Layout[0].Blocks) != 1 {
+		t.Fatal("Answer posts should produce an ask layout over the question", c.Layout)
+	}
+	if c.Layout[0].Attribution == nil || c.Layout[0].Attribution.Blog.Name != "asker" {
+		t.Fatal("Ask layout should be attributed to the asking blog")
+	}
+	anon, _ := LegacyToNPF(&AnswerPost{Question: "Why?", AskingName: "Anonymous"})
+	if anon.Layout[0].Attribution != nil {
+		t.Fatal("Anonymous asks should have no attribution")
+	}
+}
+
+func TestLegacyToNPFPhotoPost(t *testing.T) {
+	post := &PhotoPost{Photos: []Photo{{
+		Caption:      "cap",
+		OriginalSize: PhotoSize{Url: "big.jpg", Width: 1280},
+		AltSizes:     []PhotoSize{{Url: "big.jpg", Width: 1280}, {Url: "small.jpg", Width: 75}},
+	}}}
+	c, _ := LegacyToNPF(post)
+	if len(c.Content) != 1 || len(c.Content[0].Media) != 2 || c.Content[0].Caption != "cap" {
+		t.Fatal("Photos should become image blocks with deduplicated media", c.Content)
+	}
+}
+
+func TestLegacyToNPFUnknownType(t *testing.T) {
+	if _, err := LegacyToNPF(&Post{}); err == nil {
+		t.Fatal("Posts without a known type or NPF content should fail")
+	}
+	post := &Post{Content: []ContentBlock{{Type: "text", Text: "npf"}}}
+	if c, err := LegacyToNPF(post); err != nil || c.Content[0].Text != "npf" {
+		t.Fatal("NPF content should be passed through")
+	}
+}
+
+func TestNPFToLegacyTextPost(t *testing.T) {
+	post := &Post{Content: []ContentBlock{
+		{Type: "text", Subtype: "heading1", Text: "Title"},
+		{Type: "text", Text: "a < b"},
+		{Type: "text", Subtype: "unordered-list-item", Text: "one"},
+		{Type: "text", Subtype: "unordered-list-item", Text: "two"},
+		{Type: "video", Url: "https://example.com/v"},
+	}}
+	c, err := NPFToLegacy(post)
+	if err != nil {
+		t.Fatal("Unexpected conversion error", err)
+	}
+	text, ok := c.Post.(*TextPost)
+	if !ok {
+		t.Fatal("Mixed content should convert into a text post")
+	}
+	if text.Title != "Title" || text.Type != "text" {
+		t.Fatal("Leading heading should become the title")
+	}
+	expected := "<p>a &lt; b</p><ul><li>one</li><li>two</li></ul><p class=\"npf-video\"><a href=\"https://example.com/v\">https://example.com/v</a></p>"
+	if text.Body != expected {
+		t.Fatalf("Unexpected body %s", text.Body)
+	}
+}
+
+func TestNPFToLegacyPhotoAndAnswer(t *testing.T) {
+	photo, _ := NPFToLegacy(&Post{Content: []ContentBlock{
+		{Type: "image", Media: MediaList{{Url: "small.jpg", Width: 100}, {Url: "big.jpg", Width: 1000}}},
+		{Type: "text", Text: "caption"},
+	}})
+	p, ok := photo.Post.(*PhotoPost)
+	if !ok || p.Photos[0].OriginalSize.Url != "big.jpg" || p.Caption != "<p>caption</p>" {
+		t.Fatal("Image content should convert into a photo post", photo.Post)
+	}
+	answer, _ := NPFToLegacy(&Post{
+		Content: []ContentBlock{{Type: "text", Text: "Why?"}, {Type: "text", Text: "Because"}},
+		Layout:  []LayoutBlock{{Type: "ask", Blocks: []int{0}}},
+	})
+	a, ok := answer.Post.(*AnswerPost)
+	if !ok || a.Question != "<p>Why?</p>" || a.Answer != "<p>Because</p>" || a.AskingName != "Anonymous" {
+		t.Fatal("Ask layouts should convert into an answer post", answer.Post)
+	}
+}
+
+func TestNPFToLegacyChatPost(t *testing.T) {
+	c, _ := NPFToLegacy(&Post{Content: []ContentBlock{
+		{Type: "text", Subtype: "chat", Text: "Me: hi", Formatting: []TextFormatting{{Start: 0, End: 3, Type: "bold"}}},
+		{Type: "text", Subtype: "chat", Text: "You: hey"},
+	}})
+	chat, ok := c.Post.(*ChatPost)
+	if !ok || len(chat.Dialog) != 2 {
+		t.Fatal("Chat blocks should convert into a chat post")
+	}
+	if chat.Dialog[0].Label != "Me:" || chat.Dialog[0].Phrase != "hi" || chat.Dialog[1].Name != "You" {
+		t.Fatal("Dialog lines were not split correctly", chat.Dialog)
+	}
+	if len(c.Approximations) < 1 || !strings.Contains(strings.Join(c.Approximations, ","), "chat") {
+		t.Fatal("Chat conversion should be reported as an approximation")
+	}
+}
+
+func TestNPFToLegacyWithoutContent(t *testing.T) {
+	if _, err := NPFToLegacy(&Post{}); err == nil {
+		t.Fatal("Posts without NPF content should fail")
+	}
+}
+
+func TestLegacyReblogRoundTrip(t *testing.T) {
+	post := &TextPost{Post: Post{
+		Body: "<p><a class=\"tumblr_blog\">alice</a>:</p><blockquote><p>root</p></blockquote>",
+		Trail: []ReblogTrailItem{{
+			Blog:       Blog{BlogRef: BlogRef{Name: "alice"}},
+			Content:    "<p>root</p>",
+			IsRootItem: true,
+		}},
+	}}
+	c, err := LegacyToNPF(post)
+	if err != nil {
+		t.Fatal("Unexpected conversion error", err)
+	}
+	if len(c.Content) != 0 || len(c.Trail) != 1 || len(c.Approximations) != 0 {
+		t.Fatal("A reblog which added nothing should only have a trail", c)
+	}
+	if blocks := c.Trail[0].ContentBlocks; len(blocks) != 1 || blocks[0].Text != "root" {
+		t.Fatal("Trail HTML should be converted into content blocks", blocks)
+	}
+	back, err := NPFToLegacy(&Post{Content: c.Content, Trail: c.Trail})
+	if err != nil {
+		t.Fatal("Unexpected conversion error", err)
+	}
+	text, ok := back.Post.(*TextPost)
+	if !ok || text.Body != "" || len(text.Trail) != 1 || text.Trail[0].HTML() != "<p>root</p>" {
+		t.Fatal("The trail should survive a round trip", back.Post)
+	}
+
+	// the post's own addition is the current trail item, not the flattened body
+	post.Body += "<p>mine</p>"
+	post.Trail = append(post.Trail, ReblogTrailItem{Content: "<p>mine</p>", IsCurrentItem: true})
+	c, _ = LegacyToNPF(post)
+	if len(c.Content) != 1 || c.Content[0].Text != "mine" || len(c.Trail) != 1 {
+		t.Fatal("Only the post's own addition should be in the content", c)
+	}
+}
+
+func TestLegacyQuoteRoundTrip(t *testing.T) {
+	post := &QuotePost{Text: "To be <b>or</b> not", Source: "<a href=\"https://example.com\">Hamlet</a>, Act 3"}
+	c, err := LegacyToNPF(post)
+	if err != nil {
+		t.Fatal("Unexpected conversion error", err)
+	}
+	if len(c.Content) != 2 || c.Content[0].Subtype != "quote" || len(c.Content[1].Formatting) != 1 {
+		t.Fatal("The quote and its formatted source should become text blocks", c.Content)
+	}
+	back, _ := NPFToLegacy(&Post{Content: c.Content})
+	quote, ok := back.Post.(*QuotePost)
+	if !ok {
+		t.Fatal("Quote content should convert into a quote post", back.Post)
+	}
+	if quote.Text != "To be <strong>or</strong> not" {
+		t.Fatal("The quote should keep its formatting", quote.Text)
+	}
+	if quote.Source != "<p><a href=\"https://example.com\">Hamlet</a>, Act 3</p>" {
+		t.Fatal("The source should keep its formatting", quote.Source)
+	}
+}
@@ -0,0 +1,698 @@
+package tumblr
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// NPF allows at most this many levels of nesting for lists and indented blocks
+const maxIndentLevel = 7
+
+// Accumulates text, formatting ranges and standalone blocks into NPF content blocks
+type npfBuilder struct {
+	blocks   []ContentBlock
+	text     strings.Builder
+	length   int
+	subtype  string
+	indent   int
+	closed   []TextFormatting
+	open     []TextFormatting
+	last     byte
+	preserve bool
+}
+
+// Finishes the current text block (if any) and sets the subtype and indent level of the next one
+func (b *npfBuilder) startBlock(subtype string, indent int) {
+	b.flush()
+	if indent > maxIndentLevel {
+		indent = maxIndentLevel
+	}
+	b.subtype, b.indent = subtype, indent
+}
+
+// Appends text to the current block, collapsing whitespace unless preserving it
+func (b *npfBuilder) write(s string) {
+	if s == "" {
+		return
+	}
+	if !b.preserve {
+		collapsed := strings.Join(strings.Fields(s), " ")
+		if collapsed == "" {
+			collapsed = " "
+		} else {
+			if strings.TrimLeft(s, " \t\r\n\f") != s {
+				collapsed = " " + collapsed
+			}
+			if strings.TrimRight(s, " \t\r\n\f") != s {
+				collapsed += " "
+			}
+		}
+		// no leading space at the start of a block or line, nor after another space
+		if b.length == 0 || b.last == ' ' || b.last == '\n' {
+			collapsed = strings.TrimLeft(collapsed, " ")
+		}
+		s = collapsed
+	}
+	if s == "" {
+		return
+	}
+	b.text.WriteString(s)
+	b.length += utf16Len(s)
+	b.last = s[len(s)-1]
+}
+
+// Starts a new line within the current block
+func (b *npfBuilder) lineBreak() {
+	if b.last == ' ' {
+		current := strings.TrimRight(b.text.String(), " ")
+		b.text.Reset()
+		b.text.WriteString(current)
+		b.length = utf16Len(current)
+	}
+	b.text.WriteString("\n")
+	b.length++
+	b.last = '\n'
+}
+
+// Opens a formatting range at the current position
+func (b *npfBuilder) openFormat(f TextFormatting) {
+	f.Start = b.length
+	b.open = append(b.open, f)
+}
+
+// Closes the most recently opened formatting range of the given type
+func (b *npfBuilder) closeFormat(formatType string) {
+	for i := len(b.open) - 1; i >= 0; i-- {
+		if b.open[i].Type == formatType {
+			f := b.open[i]
+			f.End = b.length
+			b.closed = append(b.closed, f)
+			b.open = append(b.open[:i], b.open[i+1:]...)
+			return
+		}
+	}
+}
+
+// Adds a standalone (image or link) block after the current text block
+func (b *npfBuilder) addBlock(block ContentBlock) {
+	subtype, indent := b.subtype, b.indent
+	b.flush()
+	b.blocks = append(b.blocks, block)
+	b.subtype, b.indent = subtype, indent
+}
+
+var bareURLPattern = regexp.MustCompile(`^https?://\S+$`)
+
+// Turns the accumulated text into a content block. Formatting still open is continued in the next block.
+// A paragraph holding nothing but a URL becomes a link block.
+func (b *npfBuilder) flush() {
+	text := strings.TrimRight(b.text.String(), " \n")
+	if !b.preserve {
+		text = strings.TrimLeft(text, " \n")
+	}
+	lead := b.length - utf16Len(strings.TrimLeft(b.text.String(), " \n"))
+	if b.preserve {
+		lead = 0
+	}
+	end := lead + utf16Len(text)
+	formats := append([]TextFormatting{}, b.closed...)
+	for _, f := range b.open {
+		f.End = b.length
+		formats = append(formats, f)
+	}
+	b.text.Reset()
+	b.length = 0
+	b.last = 0
+	b.closed = nil
+	for i := range b.open {
+		b.open[i].Start = 0
+	}
+	if text == "" {
+		return
+	}
+
+	block := ContentBlock{Type: "text", Subtype: b.subtype, Text: text, IndentLevel: b.indent}
+	for _, f := range formats {
+		f.Start, f.End = clampRange(f.Start-lead, end-lead), clampRange(f.End-lead, end-lead)
+		if f.Start >= f.End {
+			continue
+		}
+		// merge with an adjacent or overlapping range of the same kind
+		merged := false
+		for i := range block.Formatting {
+			g := &block.Formatting[i]
+			if g.Type == f.Type && g.Url == f.Url && g.Hex == f.Hex && f.Start <= g.End && f.End >= g.Start {
+				if f.Start < g.Start {
+					g.Start = f.Start
+				}
+				if f.End > g.End {
+					g.End = f.End
+				}
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			block.Formatting = append(block.Formatting, f)
+		}
+	}
+	if b.subtype == "" && b.indent == 0 {
+		if bareURLPattern.MatchString(text) && (len(block.Formatting) == 0 || isWholeLink(block)) {
+			b.blocks = append(b.blocks, ContentBlock{Type: "link", Url: text})
+			return
+		}
+	}
+	b.blocks = append(b.blocks, block)
+}
+
+// Whether a text block consists of a single link covering all of its text
+func isWholeLink(block ContentBlock) bool {
+	f := block.Formatting
+	return len(f) == 1 && f[0].Type == "link" && f[0].Start == 0 && f[0].End == utf16Len(block.Text) && f[0].Url == block.Text
+}
+
+// Clamps an offset into [0, max]
+func clampRange(offset, max int) int {
+	if offset < 0 {
+		return 0
+	}
+	if offset > max {
+		return max
+	}
+	return offset
+}
+
+// Returns the finished blocks
+func (b *npfBuilder) result() []ContentBlock {
+	b.flush()
+	if b.blocks == nil {
+		return []ContentBlock{}
+	}
+	return b.blocks
+}
+
+var (
+	htmlTokenPattern     = regexp.MustCompile(`(?s)<!--.*?-->|<![^>]*>|<(/?)([a-zA-Z][a-zA-Z0-9]*)((?:[^>"']|"[^"]*"|'[^']*')*)>`)
+	htmlAttributePattern = regexp.MustCompile(`([a-zA-Z_:][-a-zA-Z0-9_:.]*)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'=<>` + "`" + `]+)))?`)
+	cssColorPattern      = regexp.MustCompile(`(?i)(?:^|;)\s*color\s*:\s*(#[0-9a-f]{3}(?:[0-9a-f]{3})?)\b`)
+	htmlInlineFormats    = map[string]string{
+		"b": "bold", "strong": "bold", "i": "italic", "em": "italic",
+		"s": "strikethrough", "strike": "strikethrough", "del": "strikethrough", "small": "small",
+	}
+)
+
+// Parses the attributes of an HTML tag
+func parseHTMLAttributes(s string) map[string]string {
+	attrs := map[string]string{}
+	for _, m := range htmlAttributePattern.FindAllStringSubmatch(s, -1) {
+		attrs[strings.ToLower(m[1])] = html.UnescapeString(m[2] + m[3] + m[4])
+	}
+	return attrs
+}
+
+// ParseHTML converts an HTML fragment into NPF content blocks. Paragraphs, headings, lists and blockquotes
+// become text blocks (nesting is kept as indent_level), inline markup becomes formatting ranges measured in
+// UTF-16 code units, images become image blocks and a paragraph holding only a URL becomes a link block.
+// Unsupported markup is dropped, keeping its text.
+func ParseHTML(s string) []ContentBlock {
+	b := &npfBuilder{}
+	var lists []string
+	var inline []string
+	quotes, heading := 0, ""
+	var caption *strings.Builder
+	context := func() {
+		switch {
+		case heading != "":
+			b.startBlock(heading, 0)
+		case len(lists) > 0:
+			b.startBlock(lists[len(lists)-1], len(lists)-1)
+		case quotes > 0:
+			b.startBlock("indented", quotes-1)
+		default:
+			b.startBlock("", 0)
+		}
+	}
+	writeText := func(text string) {
+		if caption != nil {
+			caption.WriteString(text)
+		} else {
+			b.write(html.UnescapeString(text))
+		}
+	}
+
+	last := 0
+	for _, m := range htmlTokenPattern.FindAllStringSubmatchIndex(s, -1) {
+		writeText(s[last:m[0]])
+		last = m[1]
+		if m[4] < 0 {
+			// comment or doctype
+			continue
+		}
+		closing := m[3] > m[2]
+		name := strings.ToLower(s[m[4]:m[5]])
+		attrs := parseHTMLAttributes(s[m[6]:m[7]])
+		if name == "script" || name == "style" {
+			if !closing {
+				if end := strings.Index(strings.ToLower(s[last:]), "</"+name); end >= 0 {
+					last += end
+				}
+			}
+			continue
+		}
+		switch name {
+		case "p", "div", "section", "article", "header", "footer", "hr":
+			context()
+		case "h1", "h2", "h3", "h4", "h5", "h6":
+			heading = ""
+			if !closing {
+				heading = "heading2"
+				if name == "h1" {
+					heading = "heading1"
+				}
+			}
+			context()
+		case "pre":
+			context()
+			b.preserve = !closing
+		case "blockquote":
+			if closing && quotes > 0 {
+				quotes--
+			} else if !closing {
+				quotes++
+			}
+			context()
+		case "ul", "ol":
+			if closing && len(lists) > 0 {
+				lists = lists[:len(lists)-1]
+			} else if !closing {
+				subtype := "unordered-list-item"
+				if name == "ol" {
+					subtype = "ordered-list-item"
+				}
+				lists = append(lists, subtype)
+			}
+			context()
+		case "li":
+			context()
+		case "br":
+			b.lineBreak()
+		case "img":
+			if src := safeURL(attrs["src"]); src != "" {
+				media := MediaObject{Url: src}
+				if w, err := strconv.ParseUint(attrs["width"], 10, 32); err == nil {
+					media.Width = uint32(w)
+				}
+				if h, err := strconv.ParseUint(attrs["height"], 10, 32); err == nil {
+					media.Height = uint32(h)
+				}
+				b.addBlock(ContentBlock{Type: "image", Media: MediaList{media}, AltText: attrs["alt"]})
+			}
+		case "figure":
+			context()
+		case "figcaption":
+			if !closing {
+				b.flush()
+				caption = &strings.Builder{}
+				continue
+			}
+			if caption != nil {
+				if n := len(b.blocks); n > 0 && b.blocks[n-1].Type == "image" {
+					b.blocks[n-1].Caption = strings.Join(strings.Fields(html.UnescapeString(htmlToPlainText(caption.String()))), " ")
+				}
+				caption = nil
+			}
+		default:
+			if closing {
+				for i := len(inline) - 1; i >= 0; i-- {
+					tag, format := splitInlineTag(inline[i])
+					if tag == name {
+						if format != "" {
+							b.closeFormat(format)
+						}
+						inline = inline[:i]
+						break
+					}
+				}
+				continue
+			}
+			format := ""
+			switch {
+			case htmlInlineFormats[name] != "":
+				format = htmlInlineFormats[name]
+				b.openFormat(TextFormatting{Type: format})
+			case name == "a" && safeURL(attrs["href"]) != "":
+				format = "link"
+				b.openFormat(TextFormatting{Type: format, Url: safeURL(attrs["href"])})
+			case name == "span" || name == "font":
+				hex := attrs["color"]
+				if m := cssColorPattern.FindStringSubmatch(attrs["style"]); m != nil {
+					hex = m[1]
+				}
+				if hexColorPattern.MatchString(hex) {
+					format = "color"
+					b.openFormat(TextFormatting{Type: format, Hex: "#" + strings.TrimPrefix(hex, "#")})
+				}
+			}
+			inline = append(inline, name+" "+format)
+		}
+	}
+	writeText(s[last:])
+	return b.result()
+}
+
+// Splits an entry of the open inline tag stack into its tag name and formatting type
+func splitInlineTag(entry string) (string, string) {
+	parts := strings.SplitN(entry, " ", 2)
+	return parts[0], parts[1]
+}
+
+var (
+	mdHeadingPattern  = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	mdRulePattern     = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	mdQuotePattern    = regexp.MustCompile(`^ {0,3}> ?(.*)$`)
+	mdListItemPattern = regexp.MustCompile(`^( *)([-*+]|\d{1,9}[.)])( +|$)(.*)$`)
+	mdFencePattern    = regexp.MustCompile("^ {0,3}(```+|~~~+)")
+)
+
+// Block context a Markdown fragment is parsed in
+type mdContext struct {
+	quotes int
+	lists  []string
+}
+
+// Subtype and indent level for paragraphs in the context
+func (c mdContext) paragraph() (string, int) {
+	if len(c.lists) > 0 {
+		return c.lists[len(c.lists)-1], len(c.lists) - 1
+	}
+	if c.quotes > 0 {
+		return "indented", c.quotes - 1
+	}
+	return "", 0
+}
+
+// ParseMarkdown converts Markdown into NPF content blocks. Headings, paragraphs, (nested) lists, blockquotes
+// and fenced code become text blocks, emphasis, strikethrough and links become formatting ranges measured in
+// UTF-16 code units, images become image blocks and a paragraph holding only a URL becomes a link block.
+func ParseMarkdown(s string) []ContentBlock {
+	b := &npfBuilder{}
+	s = strings.Replace(strings.Replace(s, "\r\n", "\n", -1), "\t", "    ", -1)
+	parseMarkdownBlocks(b, strings.Split(s, "\n"), mdContext{})
+	return b.result()
+}
+
+// Parses lines of Markdown into blocks
+func parseMarkdownBlocks(b *npfBuilder, lines []string, ctx mdContext) {
+	var paragraph []string
+	flushParagraph := func() {
+		if len(paragraph) == 0 {
+			return
+		}
+		subtype, indent := ctx.paragraph()
+		b.startBlock(subtype, indent)
+		for i, line := range paragraph {
+			hard := strings.HasSuffix(line, "  ") || strings.HasSuffix(line, "\\")
+			line = strings.TrimSpace(line)
+			if hard && i < len(paragraph)-1 {
+				parseMarkdownInline(b, strings.TrimSuffix(line, "\\"))
+				b.lineBreak()
+				continue
+			}
+			parseMarkdownInline(b, line)
+			b.write(" ")
+		}
+		b.flush()
+		paragraph = nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			flushParagraph()
+		case mdFencePattern.MatchString(line):
+			flushParagraph()
+			fence := mdFencePattern.FindStringSubmatch(line)[1]
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence); i++ {
+				code = append(code, lines[i])
+			}
+			subtype, indent := ctx.paragraph()
+			b.startBlock(subtype, indent)
+			b.preserve = true
+			b.write(strings.Join(code, "\n"))
+			b.flush()
+			b.preserve = false
+		case mdHeadingPattern.MatchString(line):
+			flushParagraph()
+			m := mdHeadingPattern.FindStringSubmatch(line)
+			subtype := "heading2"
+			if m[1] == "#" {
+				subtype = "heading1"
+			}
+			b.startBlock(subtype, 0)
+			parseMarkdownInline(b, m[2])
+			b.flush()
+		case mdRulePattern.MatchString(line):
+			flushParagraph()
+		case mdQuotePattern.MatchString(line):
+			flushParagraph()
+			var quoted []string
+			for ; i < len(lines) && mdQuotePattern.MatchString(lines[i]); i++ {
+				quoted = append(quoted, mdQuotePattern.FindStringSubmatch(lines[i])[1])
+			}
+			i--
+			nested := ctx
+			nested.quotes++
+			parseMarkdownBlocks(b, quoted, nested)
+		case mdListItemPattern.MatchString(line) && (len(paragraph) == 0 || !strings.HasPrefix(line, " ")):
+			flushParagraph()
+			i = parseMarkdownList(b, lines, i, ctx) - 1
+		default:
+			paragraph = append(paragraph, line)
+		}
+	}
+	flushParagraph()
+}
+
+// Parses the list starting at lines[start] and returns the index of the first line after it
+func parseMarkdownList(b *npfBuilder, lines []string, start int, ctx mdContext) int {
+	i := start
+	for i < len(lines) {
+		m := mdListItemPattern.FindStringSubmatch(lines[i])
+		if m == nil {
+			break
+		}
+		subtype := "unordered-list-item"
+		if m[2] != "-" && m[2] != "*" && m[2] != "+" {
+			subtype = "ordered-list-item"
+		}
+		// continuation lines are indented at least as far as the item's content
+		column := len(m[1]) + len(m[2]) + len(m[3])
+		if m[4] == "" {
+			column = len(m[1]) + len(m[2]) + 1
+		}
+		item := []string{m[4]}
+		for i++; i < len(lines); i++ {
+			line := lines[i]
+			if strings.TrimSpace(line) == "" {
+				if i+1 < len(lines) && indentation(lines[i+1]) >= column {
+					item = append(item, "")
+					continue
+				}
+				break
+			}
+			if indentation(line) >= column {
+				item = append(item, line[column:])
+				continue
+			}
+			if mdListItemPattern.MatchString(line) || mdQuotePattern.MatchString(line) || mdHeadingPattern.MatchString(line) {
+				break
+			}
+			// lazy continuation of the item's paragraph
+			item = append(item, strings.TrimSpace(line))
+		}
+		nested := ctx
+		nested.lists = append(append([]string{}, ctx.lists...), subtype)
+		parseMarkdownBlocks(b, item, nested)
+		if i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+			i++
+		}
+	}
+	return i
+}
+
+// Number of leading spaces of a line
+func indentation(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// Parses inline Markdown into the builder
+func parseMarkdownInline(b *npfBuilder, s string) {
+	var text strings.Builder
+	flushText := func() {
+		if text.Len() > 0 {
+			b.write(text.String())
+			text.Reset()
+		}
+	}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_{}[]()#+-.!<>~|", s[i+1]) >= 0:
+			text.WriteByte(s[i+1])
+			i += 2
+			continue
+		case c == '`':
+			run := len(s[i:]) - len(strings.TrimLeft(s[i:], "`"))
+			delim := s[i : i+run]
+			if end := strings.Index(s[i+run:], delim); end >= 0 {
+				text.WriteString(strings.TrimSpace(s[i+run : i+run+end]))
+				i += run + end + run
+				continue
+			}
+			text.WriteString(delim)
+			i += run
+			continue
+		case c == '!' && strings.HasPrefix(s[i:], "!["):
+			if alt, dest, n := parseMarkdownLink(s[i+1:]); n > 0 {
+				if src := safeURL(dest); src != "" {
+					flushText()
+					b.addBlock(ContentBlock{Type: "image", Media: MediaList{{Url: src}}, AltText: alt})
+					i += 1 + n
+					continue
+				}
+			}
+		case c == '[':
+			if label, dest, n := parseMarkdownLink(s[i:]); n > 0 {
+				flushText()
+				href := safeURL(dest)
+				if href != "" {
+					b.openFormat(TextFormatting{Type: "link", Url: href})
+				}
+				parseMarkdownInline(b, label)
+				if href != "" {
+					b.closeFormat("link")
+				}
+				i += n
+				continue
+			}
+		case c == '<':
+			if end := strings.IndexByte(s[i:], '>'); end > 0 && bareURLPattern.MatchString(s[i+1:i+end]) {
+				flushText()
+				href := s[i+1 : i+end]
+				b.openFormat(TextFormatting{Type: "link", Url: href})
+				b.write(href)
+				b.closeFormat("link")
+				i += end + 1
+				continue
+			}
+		case c == '*' || c == '_' || c == '~':
+			delim, format := string(c), "italic"
+			if strings.HasPrefix(s[i:], string([]byte{c, c})) {
+				delim, format = string([]byte{c, c}), "bold"
+			}
+			if c == '~' {
+				format = "strikethrough"
+				if len(delim) == 1 {
+					break
+				}
+			}
+			if end := findClosingDelimiter(s, i+len(delim), delim); end > 0 {
+				flushText()
+				b.openFormat(TextFormatting{Type: format})
+				parseMarkdownInline(b, s[i+len(delim):end])
+				b.closeFormat(format)
+				i = end + len(delim)
+				continue
+			}
+			text.WriteString(delim)
+			i += len(delim)
+			continue
+		}
+		text.WriteByte(c)
+		i++
+	}
+	flushText()
+}
+
+// Finds the closing emphasis delimiter for an opening one ending at start, or -1. Emphasis cannot start
+// or end with whitespace, and underscores only count at word boundaries.
+func findClosingDelimiter(s string, start int, delim string) int {
+	if start >= len(s) || s[start] == ' ' {
+		return -1
+	}
+	if delim[0] == '_' && start-len(delim) > 0 && isWordByte(s[start-len(delim)-1]) {
+		return -1
+	}
+	for j := start + 1; j <= len(s)-len(delim); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+			continue
+		case '`':
+			if end := strings.IndexByte(s[j+1:], '`'); end >= 0 {
+				j += end + 1
+			}
+			continue
+		}
+		if !strings.HasPrefix(s[j:], delim) || s[j-1] == ' ' {
+			continue
+		}
+		if len(delim) == 1 && j+1 < len(s) && s[j+1] == delim[0] {
+			// part of a double delimiter; skip over the nested emphasis
+			if end := findClosingDelimiter(s, j+2, delim+delim); end > 0 {
+				j = end + 1
+			} else {
+				j++
+			}
+			continue
+		}
+		if delim[0] == '_' && j+len(delim) < len(s) && isWordByte(s[j+len(delim)]) {
+			continue
+		}
+		return j
+	}
+	return -1
+}
+
+// Whether a byte is part of a word (letters, digits, or any multi-byte character)
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+// Parses a Markdown link starting with `[`, returning its label, destination and length in bytes
+// (or a zero length if s does not start with a link)
+func parseMarkdownLink(s string) (string, string, int) {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth > 0 {
+				continue
+			}
+			if i+1 >= len(s) || s[i+1] != '(' {
+				return "", "", 0
+			}
+			end := strings.IndexByte(s[i+2:], ')')
+			if end < 0 {
+				return "", "", 0
+			}
+			dest := strings.TrimSpace(s[i+2 : i+2+end])
+			// drop an optional title
+			if sp := strings.IndexAny(dest, " \t"); sp >= 0 {
+				dest = dest[:sp]
+			}
+			dest = strings.TrimSuffix(strings.TrimPrefix(dest, "<"), ">")
+			return s[1:i], dest, i + 3 + end
+		}
+	}
+	return "", "", 0
+}
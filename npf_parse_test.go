@@ -0,0 +1,113 @@
+package tumblr
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// Compares parsed blocks against the expected JSON representation
+func expectBlocks(t *testing.T, name string, actual []ContentBlock, expected string) {
+	var want []ContentBlock
+	if err := json.Unmarshal([]byte(expected), &want); err != nil {
+		t.Fatalf("%s: invalid expectation: %s", name, err)
+	}
+	if len(actual) != len(want) {
+		got, _ := json.Marshal(actual)
+		t.Fatalf("%s: expected %d blocks, got %d: %s", name, len(want), len(actual), got)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(actual[i], want[i]) {
+			got, _ := json.Marshal(actual[i])
+			exp, _ := json.Marshal(want[i])
+			t.Fatalf("%s: unexpected block %d\nexpected %s\ngot      %s", name, i, exp, got)
+		}
+	}
+}
+
+func TestParseHTMLFormatting(t *testing.T) {
+	blocks := ParseHTML("<p>Hello <b>bold <i>both</i></b> and <a href=\"https://example.com\">a&nbsp;link</a></p>\n<p>😀 <em>emoji</em></p>")
+	expectBlocks(t, "ParseHTML", blocks, `[
+		{"type": "text", "text": "Hello bold both and a link", "formatting": [
+			{"start": 11, "end": 15, "type": "italic"},
+			{"start": 6, "end": 15, "type": "bold"},
+			{"start": 20, "end": 26, "type": "link", "url": "https://example.com"}
+		]},
+		{"type": "text", "text": "😀 emoji", "formatting": [{"start": 3, "end": 8, "type": "italic"}]}
+	]`)
+}
+
+func TestParseHTMLStructure(t *testing.T) {
+	blocks := ParseHTML(`<h1>Title</h1><h3>Sub</h3>
+		<ul><li>one<ol><li>nested</li></ol></li><li>two</li></ul>
+		<blockquote><p>quoted</p><blockquote>deeper</blockquote></blockquote>
+		<figure><img src="https://64.media.tumblr.com/a.jpg" alt="cat" width="500" height="400"><figcaption>A <b>cat</b></figcaption></figure>
+		<p>line one<br>line two</p>
+		<p>https://example.com/page</p>
+		<p><a href="javascript:alert(1)">bad</a><script>alert(1)</script><span style="color: #ff0000">red</span></p>`)
+	expectBlocks(t, "ParseHTML", blocks, `[
+		{"type": "text", "subtype": "heading1", "text": "Title"},
+		{"type": "text", "subtype": "heading2", "text": "Sub"},
+		{"type": "text", "subtype": "unordered-list-item", "text": "one"},
+		{"type": "text", "subtype": "ordered-list-item", "text": "nested", "indent_level": 1},
+		{"type": "text", "subtype": "unordered-list-item", "text": "two"},
+		{"type": "text", "subtype": "indented", "text": "quoted"},
+		{"type": "text", "subtype": "indented", "text": "deeper", "indent_level": 1},
+		{"type": "image", "media": [{"url": "https://64.media.tumblr.com/a.jpg", "width": 500, "height": 400}], "alt_text": "cat", "caption": "A cat"},
+		{"type": "text", "text": "line one\nline two"},
+		{"type": "link", "url": "https://example.com/page"},
+		{"type": "text", "text": "badred", "formatting": [{"start": 3, "end": 6, "type": "color", "hex": "#ff0000"}]}
+	]`)
+}
+
+func TestParseMarkdown(t *testing.T) {
+	md := "# Title\n\n" +
+		"Some **bold _and_ italic** text with a [link](https://example.com \"title\"), `co*de` and ~~strike~~.\n" +
+		"snake_case_name stays.\n\n" +
+		"- one\n" +
+		"- two\n" +
+		"  1. nested\n" +
+		"  2. nested again\n" +
+		"- three\n\n" +
+		"> quoted\n> > deeper\n\n" +
+		"![alt text](https://64.media.tumblr.com/a.jpg)\n\n" +
+		"<https://example.com/page>\n\n" +
+		"```\ncode  block\n```\n\n" +
+		"line one  \nline two"
+	expectBlocks(t, "ParseMarkdown", ParseMarkdown(md), `[
+		{"type": "text", "subtype": "heading1", "text": "Title"},
+		{"type": "text", "text": "Some bold and italic text with a link, co*de and strike. snake_case_name stays.", "formatting": [
+			{"start": 10, "end": 13, "type": "italic"},
+			{"start": 5, "end": 20, "type": "bold"},
+			{"start": 33, "end": 37, "type": "link", "url": "https://example.com"},
+			{"start": 49, "end": 55, "type": "strikethrough"}
+		]},
+		{"type": "text", "subtype": "unordered-list-item", "text": "one"},
+		{"type": "text", "subtype": "unordered-list-item", "text": "two"},
+		{"type": "text", "subtype": "ordered-list-item", "text": "nested", "indent_level": 1},
+		{"type": "text", "subtype": "ordered-list-item", "text": "nested again", "indent_level": 1},
+		{"type": "text", "subtype": "unordered-list-item", "text": "three"},
+		{"type": "text", "subtype": "indented", "text": "quoted"},
+		{"type": "text", "subtype": "indented", "text": "deeper", "indent_level": 1},
+		{"type": "image", "media": [{"url": "https://64.media.tumblr.com/a.jpg"}], "alt_text": "alt text"},
+		{"type": "link", "url": "https://example.com/page"},
+		{"type": "text", "text": "code  block"},
+		{"type": "text", "text": "line one\nline two"}
+	]`)
+}
+
+func TestParseMarkdownRoundTrip(t *testing.T) {
+	content := ParseMarkdown("Hello **world**, see [this](https://example.com).\n\n1. a\n2. b")
+	if actual := RenderNPFMarkdown(content, nil); actual != "Hello **world**, see [this](https://example.com).\n\n1. a\n2. b" {
+		t.Fatalf("Parsed Markdown should render back to itself, got %q", actual)
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	if blocks := ParseHTML("  "); blocks == nil || len(blocks) != 0 {
+		t.Fatal("Empty HTML should produce an empty block list")
+	}
+	if blocks := ParseMarkdown(""); blocks == nil || len(blocks) != 0 {
+		t.Fatal("Empty Markdown should produce an empty block list")
+	}
+}
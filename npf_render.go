@@ -0,0 +1,701 @@
+package tumblr
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// NPFRenderer renders NPF content and layout as HTML or Markdown.
+// The zero value renders everything, marking the read-more point with an HTML comment.
+type NPFRenderer struct {
+	// Truncate stops rendering at the layout's read-more point
+	Truncate bool
+	// ReadMoreUrl is linked to in place of truncated content, if set
+	ReadMoreUrl string
+	// AllowEmbeds renders the provider embed HTML of audio and video blocks; it is not escaped
+	AllowEmbeds bool
+}
+
+// RenderNPFHTML renders NPF content and layout as HTML with the default renderer.
+func RenderNPFHTML(content []ContentBlock, layout []LayoutBlock) string {
+	return (&NPFRenderer{}).HTML(content, layout)
+}
+
+// RenderNPFMarkdown renders NPF content and layout as Markdown with the default renderer.
+func RenderNPFMarkdown(content []ContentBlock, layout []LayoutBlock) string {
+	return (&NPFRenderer{}).Markdown(content, layout)
+}
+
+// Display order of content blocks as determined by a post's layout
+type npfOrder struct {
+	ask           []int
+	attribution   *Attribution
+	rows          [][]int
+	truncateAfter int
+}
+
+// Works out the display order of the content: ask blocks first, then rows of the rows layout (or one
+// row per block), followed by any blocks the layout does not mention
+func newNPFOrder(content []ContentBlock, layout []LayoutBlock) npfOrder {
+	order := npfOrder{truncateAfter: -1}
+	placed := map[int]bool{}
+	valid := func(i int) bool {
+		return i >= 0 && i < len(content) && !placed[i]
+	}
+	if ask := askLayout(layout); ask != nil {
+		for _, i := range ask.Blocks {
+			if valid(i) {
+				order.ask = append(order.ask, i)
+				placed[i] = true
+			}
+		}
+		order.attribution = ask.Attribution
+	}
+	for _, l := range layout {
+		switch l.Type {
+		case "rows":
+			for _, display := range l.Display {
+				row := []int{}
+				for _, i := range display.Blocks {
+					if valid(i) {
+						row = append(row, i)
+						placed[i] = true
+					}
+				}
+				if len(row) > 0 {
+					order.rows = append(order.rows, row)
+				}
+			}
+			if l.TruncateAfter != nil {
+				order.truncateAfter = *l.TruncateAfter
+			}
+		case "condensed":
+			if l.TruncateAfter != nil {
+				order.truncateAfter = *l.TruncateAfter
+			} else if n := len(l.Blocks); n > 0 {
+				order.truncateAfter = l.Blocks[n-1]
+			}
+		}
+	}
+	for i := range content {
+		if !placed[i] {
+			order.rows = append(order.rows, []int{i})
+		}
+	}
+	return order
+}
+
+// Index of the row after which the read-more point falls, or -1 if there is none
+func (o npfOrder) truncateRow() int {
+	if o.truncateAfter < 0 {
+		return -1
+	}
+	for r, row := range o.rows {
+		for _, i := range row {
+			if i == o.truncateAfter {
+				if r == len(o.rows)-1 {
+					return -1
+				}
+				return r
+			}
+		}
+	}
+	return -1
+}
+
+// HTML renders NPF content and layout as HTML. All text and attributes are escaped and only
+// http(s) and mailto links are kept.
+func (r *NPFRenderer) HTML(content []ContentBlock, layout []LayoutBlock) string {
+	order := newNPFOrder(content, layout)
+	var out strings.Builder
+	if len(order.ask) > 0 {
+		out.WriteString("<blockquote class=\"npf-ask\"><p class=\"npf-ask-attribution\">")
+		if blog := askingBlog(order.attribution); blog != nil {
+			if href := safeURL(blog.Url); href != "" {
+				fmt.Fprintf(&out, "<a href=\"%s\">%s</a>", html.EscapeString(href), html.EscapeString(blog.Name))
+			} else {
+				out.WriteString(html.EscapeString(blog.Name))
+			}
+		} else {
+			out.WriteString("Anonymous")
+		}
+		out.WriteString(" asked:</p>")
+		lists := &htmlLists{}
+		for _, i := range order.ask {
+			r.blockHTML(&out, content[i], lists)
+		}
+		lists.closeAll(&out)
+		out.WriteString("</blockquote>")
+	}
+	truncateRow := order.truncateRow()
+	lists := &htmlLists{}
+	for n, row := range order.rows {
+		if len(row) > 1 {
+			lists.closeAll(&out)
+			out.WriteString("<div class=\"npf-row\">")
+			for _, i := range row {
+				r.blockHTML(&out, content[i], lists)
+				lists.closeAll(&out)
+			}
+			out.WriteString("</div>")
+		} else {
+			r.blockHTML(&out, content[row[0]], lists)
+		}
+		if n == truncateRow {
+			lists.closeAll(&out)
+			if !r.Truncate {
+				out.WriteString("<!-- more -->")
+				continue
+			}
+			if href := safeURL(r.ReadMoreUrl); href != "" {
+				fmt.Fprintf(&out, "<p class=\"npf-read-more\"><a href=\"%s\">Keep reading</a></p>", html.EscapeString(href))
+			}
+			break
+		}
+	}
+	lists.closeAll(&out)
+	return out.String()
+}
+
+// Open (possibly nested) HTML lists while rendering consecutive list items
+type htmlLists struct {
+	tags []string
+}
+
+// Moves the list nesting to the given level and tag, closing and opening lists as needed
+func (l *htmlLists) item(out *strings.Builder, tag string, level int) {
+	for len(l.tags) > level+1 {
+		l.pop(out)
+	}
+	if len(l.tags) == level+1 {
+		if l.tags[level] != tag {
+			l.pop(out)
+		} else {
+			out.WriteString("</li>")
+		}
+	}
+	for len(l.tags) < level+1 {
+		fmt.Fprintf(out, "<%s>", tag)
+		l.tags = append(l.tags, tag)
+	}
+	out.WriteString("<li>")
+}
+
+// Closes the innermost list
+func (l *htmlLists) pop(out *strings.Builder) {
+	fmt.Fprintf(out, "</li></%s>", l.tags[len(l.tags)-1])
+	l.tags = l.tags[:len(l.tags)-1]
+}
+
+// Closes all open lists
+func (l *htmlLists) closeAll(out *strings.Builder) {
+	for len(l.tags) > 0 {
+		l.pop(out)
+	}
+}
+
+var npfHTMLTextTags = map[string][2]string{
+	"":         {"<p>", "</p>"},
+	"heading1": {"<h1>", "</h1>"},
+	"heading2": {"<h2>", "</h2>"},
+	"quote":    {"<blockquote>", "</blockquote>"},
+	"chat":     {"<p class=\"npf-chat\">", "</p>"},
+	"quirky":   {"<p class=\"npf-quirky\">", "</p>"},
+	"indented": {"<blockquote class=\"npf-indented\">", "</blockquote>"},
+}
+
+// Writes a single content block as HTML
+func (r *NPFRenderer) blockHTML(out *strings.Builder, block ContentBlock, lists *htmlLists) {
+	if block.Type == "text" && (block.Subtype == "ordered-list-item" || block.Subtype == "unordered-list-item") {
+		tag := "ul"
+		if block.Subtype == "ordered-list-item" {
+			tag = "ol"
+		}
+		lists.item(out, tag, block.IndentLevel)
+		out.WriteString(renderInline(block.Text, block.Formatting, htmlInline{}))
+		return
+	}
+	lists.closeAll(out)
+	switch block.Type {
+	case "text":
+		tags, ok := npfHTMLTextTags[block.Subtype]
+		if !ok {
+			tags = npfHTMLTextTags[""]
+		}
+		if block.Subtype == "indented" {
+			for i := 0; i < block.IndentLevel; i++ {
+				out.WriteString(tags[0])
+			}
+		}
+		out.WriteString(tags[0])
+		out.WriteString(renderInline(block.Text, block.Formatting, htmlInline{}))
+		out.WriteString(tags[1])
+		if block.Subtype == "indented" {
+			for i := 0; i < block.IndentLevel; i++ {
+				out.WriteString(tags[1])
+			}
+		}
+	case "image":
+		media := bestMedia(block.Media)
+		if media == nil || safeURL(media.Url) == "" {
+			return
+		}
+		out.WriteString("<figure class=\"npf-image\">")
+		fmt.Fprintf(out, "<img src=\"%s\" alt=\"%s\"", html.EscapeString(safeURL(media.Url)), html.EscapeString(block.AltText))
+		if media.Width > 0 && media.Height > 0 {
+			fmt.Fprintf(out, " width=\"%d\" height=\"%d\"", media.Width, media.Height)
+		}
+		out.WriteString("/>")
+		if block.Caption != "" {
+			fmt.Fprintf(out, "<figcaption>%s</figcaption>", html.EscapeString(block.Caption))
+		}
+		out.WriteString("</figure>")
+	case "link":
+		href := safeURL(block.Url)
+		if href == "" {
+			return
+		}
+		title := block.Title
+		if title == "" {
+			title = block.Url
+		}
+		fmt.Fprintf(out, "<p class=\"npf-link\"><a href=\"%s\">%s</a>", html.EscapeString(href), html.EscapeString(title))
+		if block.Description != "" {
+			fmt.Fprintf(out, "<br/>%s", html.EscapeString(block.Description))
+		}
+		out.WriteString("</p>")
+	case "audio", "video":
+		if r.AllowEmbeds && block.EmbedHtml != "" {
+			out.WriteString(block.EmbedHtml)
+			return
+		}
+		if media := bestMedia(block.Media); media != nil && safeURL(media.Url) != "" {
+			fmt.Fprintf(out, "<%s controls src=\"%s\"", block.Type, html.EscapeString(safeURL(media.Url)))
+			if poster := bestMedia(block.Poster); block.Type == "video" && poster != nil && safeURL(poster.Url) != "" {
+				fmt.Fprintf(out, " poster=\"%s\"", html.EscapeString(safeURL(poster.Url)))
+			}
+			fmt.Fprintf(out, "></%s>", block.Type)
+		} else if href := safeURL(block.Url); href != "" {
+			fmt.Fprintf(out, "<p class=\"npf-%s\"><a href=\"%s\">%s</a></p>", block.Type, html.EscapeString(href), html.EscapeString(mediaTitle(block)))
+		}
+	}
+}
+
+// Markdown renders NPF content and layout as Markdown.
+func (r *NPFRenderer) Markdown(content []ContentBlock, layout []LayoutBlock) string {
+	order := newNPFOrder(content, layout)
+	var parts []string
+	if len(order.ask) > 0 {
+		name := "Anonymous"
+		if blog := askingBlog(order.attribution); blog != nil {
+			name = "**" + escapeMarkdown(blog.Name) + "**"
+			if href := markdownURL(blog.Url); href != "" {
+				name = "[" + name + "](" + href + ")"
+			}
+		}
+		ask := name + " asked:\n\n" + r.blocksMarkdown(content, order.ask, map[int]int{})
+		parts = append(parts, "> "+strings.Replace(ask, "\n", "\n> ", -1))
+	}
+	rows := []int{}
+	counters := map[int]int{}
+	truncateRow := order.truncateRow()
+	for n, row := range order.rows {
+		rows = append(rows, row...)
+		if n != truncateRow {
+			continue
+		}
+		parts = append(parts, r.blocksMarkdown(content, rows, counters))
+		rows = rows[:0]
+		if !r.Truncate {
+			parts = append(parts, "<!-- more -->")
+			continue
+		}
+		if href := markdownURL(r.ReadMoreUrl); href != "" {
+			parts = append(parts, "[Keep reading]("+href+")")
+		}
+		break
+	}
+	if len(rows) > 0 {
+		parts = append(parts, r.blocksMarkdown(content, rows, counters))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// Renders the given blocks as Markdown, keeping consecutive list items together. Ordered list
+// counters are kept per indent level so a list split by the read-more point keeps its numbering.
+func (r *NPFRenderer) blocksMarkdown(content []ContentBlock, indices []int, counters map[int]int) string {
+	var out strings.Builder
+	wasListItem := false
+	for n, i := range indices {
+		block := content[i]
+		isListItem := block.Type == "text" && strings.HasSuffix(block.Subtype, "list-item")
+		if n > 0 {
+			if isListItem && wasListItem {
+				out.WriteString("\n")
+			} else {
+				out.WriteString("\n\n")
+			}
+		}
+		if !isListItem {
+			for level := range counters {
+				delete(counters, level)
+			}
+		}
+		wasListItem = isListItem
+		out.WriteString(r.blockMarkdown(block, counters))
+	}
+	return out.String()
+}
+
+// Renders a single content block as Markdown
+func (r *NPFRenderer) blockMarkdown(block ContentBlock, counters map[int]int) string {
+	switch block.Type {
+	case "text":
+		text := renderInline(block.Text, block.Formatting, markdownInline{})
+		text = escapeMarkdownLineStart(text)
+		indent := strings.Repeat("    ", block.IndentLevel)
+		switch block.Subtype {
+		case "heading1":
+			return "# " + text
+		case "heading2":
+			return "## " + text
+		case "quote":
+			return "> " + strings.Replace(text, "\n", "\n> ", -1)
+		case "indented":
+			prefix := strings.Repeat(">", block.IndentLevel+1) + " "
+			return prefix + strings.Replace(text, "\n", "\n"+prefix, -1)
+		case "ordered-list-item":
+			for level := range counters {
+				if level > block.IndentLevel {
+					delete(counters, level)
+				}
+			}
+			counters[block.IndentLevel]++
+			return fmt.Sprintf("%s%d. %s", indent, counters[block.IndentLevel], text)
+		case "unordered-list-item":
+			for level := range counters {
+				if level > block.IndentLevel {
+					delete(counters, level)
+				}
+			}
+			return indent + "- " + text
+		}
+		return text
+	case "image":
+		media := bestMedia(block.Media)
+		if media == nil || safeURL(media.Url) == "" {
+			return ""
+		}
+		out := "![" + escapeMarkdown(block.AltText) + "](" + markdownURL(media.Url) + ")"
+		if block.Caption != "" {
+			out += "\n\n_" + escapeMarkdown(block.Caption) + "_"
+		}
+		return out
+	case "link":
+		href := markdownURL(block.Url)
+		if href == "" {
+			return ""
+		}
+		title := block.Title
+		if title == "" {
+			title = block.Url
+		}
+		out := "[" + escapeMarkdown(title) + "](" + href + ")"
+		if block.Description != "" {
+			out += "  \n" + escapeMarkdown(block.Description)
+		}
+		return out
+	case "audio", "video":
+		href := markdownURL(block.Url)
+		if href == "" {
+			if media := bestMedia(block.Media); media != nil {
+				href = markdownURL(media.Url)
+			}
+		}
+		if href == "" {
+			return ""
+		}
+		return "[" + escapeMarkdown(mediaTitle(block)) + "](" + href + ")"
+	}
+	return ""
+}
+
+// Blog attributed in an ask layout, or nil for anonymous asks
+func askingBlog(a *Attribution) *BlogMention {
+	if a == nil || a.Blog == nil || a.Blog.Name == "" {
+		return nil
+	}
+	return a.Blog
+}
+
+// Picks the widest media object of a list, which NPF usually lists first
+func bestMedia(media MediaList) *MediaObject {
+	var best *MediaObject
+	for i := range media {
+		if best == nil || media[i].Width > best.Width {
+			best = &media[i]
+		}
+	}
+	return best
+}
+
+// Human readable title for an audio or video block
+func mediaTitle(block ContentBlock) string {
+	switch {
+	case block.Title != "" && block.Artist != "":
+		return block.Artist + " - " + block.Title
+	case block.Title != "":
+		return block.Title
+	case block.Url != "":
+		return block.Url
+	}
+	return block.Type
+}
+
+var hexColorPattern = regexp.MustCompile(`^#?[0-9a-fA-F]{3}([0-9a-fA-F]{3})?$`)
+
+// Returns the URL if it is safe to link to (http, https, mailto or relative), or an empty string
+func safeURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || raw == "" {
+		return ""
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "mailto":
+		return u.String()
+	case "":
+		if u.Host == "" && u.Opaque == "" {
+			return u.String()
+		}
+	}
+	return ""
+}
+
+var markdownURLEscaper = strings.NewReplacer(
+	"(", "%28", ")", "%29", "[", "%5B", "]", "%5D", "<", "%3C", ">", "%3E", " ", "%20", "\t", "%09", "\n", "%0A", "\r", "%0D",
+)
+
+// Returns the URL if it is safe to link to, like safeURL, with the characters which could end a Markdown
+// link destination percent-encoded
+func markdownURL(raw string) string {
+	return markdownURLEscaper.Replace(safeURL(raw))
+}
+
+// Markup used to render inline formatting
+type inlineStyle interface {
+	open(f TextFormatting) string
+	close(f TextFormatting) string
+	escape(text string) string
+}
+
+// Renders text with its formatting ranges. Ranges are nested by closing and re-opening any that overlap,
+// and surrounding whitespace is moved outside of the markup.
+func renderInline(text string, formatting []TextFormatting, style inlineStyle) string {
+	units := utf16.Encode([]rune(text))
+	points := []int{0, len(units)}
+	for _, f := range formatting {
+		if f.Start < f.End && f.Start >= 0 && f.End <= len(units) {
+			points = append(points, f.Start, f.End)
+		}
+	}
+	sort.Ints(points)
+
+	type opened struct {
+		format  int
+		written bool
+	}
+	var out strings.Builder
+	var stack []opened
+	pendingSpace := ""
+	for p := 0; p+1 < len(points); p++ {
+		start, end := points[p], points[p+1]
+		if start == end {
+			continue
+		}
+		active := map[int]bool{}
+		for i, f := range formatting {
+			if f.Start <= start && f.End >= end && style.open(f) != "" {
+				active[i] = true
+			}
+		}
+		keep := 0
+		for keep < len(stack) && active[stack[keep].format] {
+			keep++
+		}
+		for i := len(stack) - 1; i >= keep; i-- {
+			if stack[i].written {
+				out.WriteString(style.close(formatting[stack[i].format]))
+			}
+		}
+		stack = stack[:keep]
+		out.WriteString(pendingSpace)
+		pendingSpace = ""
+		added := []int{}
+		for i := range active {
+			already := false
+			for _, o := range stack {
+				already = already || o.format == i
+			}
+			if !already {
+				added = append(added, i)
+			}
+		}
+		// formats that last longer are opened first so they need re-opening less often
+		sort.Slice(added, func(a, b int) bool {
+			if formatting[added[a]].End != formatting[added[b]].End {
+				return formatting[added[a]].End > formatting[added[b]].End
+			}
+			return added[a] < added[b]
+		})
+		for _, i := range added {
+			stack = append(stack, opened{format: i})
+		}
+
+		segment := style.escape(string(utf16.Decode(units[start:end])))
+		core := strings.TrimLeft(segment, " \t\n")
+		out.WriteString(segment[:len(segment)-len(core)])
+		trimmed := strings.TrimRight(core, " \t\n")
+		pendingSpace = core[len(trimmed):]
+		if trimmed == "" {
+			continue
+		}
+		for i := range stack {
+			if !stack[i].written {
+				out.WriteString(style.open(formatting[stack[i].format]))
+				stack[i].written = true
+			}
+		}
+		out.WriteString(trimmed)
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i].written {
+			out.WriteString(style.close(formatting[stack[i].format]))
+		}
+	}
+	out.WriteString(pendingSpace)
+	return out.String()
+}
+
+// Inline HTML markup
+type htmlInline struct{}
+
+func (htmlInline) open(f TextFormatting) string {
+	switch f.Type {
+	case "bold":
+		return "<strong>"
+	case "italic":
+		return "<em>"
+	case "strikethrough":
+		return "<s>"
+	case "small":
+		return "<small>"
+	case "link":
+		if href := safeURL(f.Url); href != "" {
+			return "<a href=\"" + html.EscapeString(href) + "\">"
+		}
+	case "mention":
+		if f.Blog != nil && safeURL(f.Blog.Url) != "" {
+			return "<a class=\"npf-mention\" href=\"" + html.EscapeString(safeURL(f.Blog.Url)) + "\">"
+		}
+	case "color":
+		if hexColorPattern.MatchString(f.Hex) {
+			return "<span style=\"color: #" + strings.TrimPrefix(f.Hex, "#") + "\">"
+		}
+	}
+	return ""
+}
+
+func (htmlInline) close(f TextFormatting) string {
+	switch f.Type {
+	case "bold":
+		return "</strong>"
+	case "italic":
+		return "</em>"
+	case "strikethrough":
+		return "</s>"
+	case "small":
+		return "</small>"
+	case "link", "mention":
+		return "</a>"
+	case "color":
+		return "</span>"
+	}
+	return ""
+}
+
+func (htmlInline) escape(text string) string {
+	return strings.Replace(html.EscapeString(text), "\n", "<br/>", -1)
+}
+
+// Inline Markdown markup; small and color have no Markdown equivalent and are rendered as plain text
+type markdownInline struct{}
+
+func (markdownInline) open(f TextFormatting) string {
+	switch f.Type {
+	case "bold":
+		return "**"
+	case "italic":
+		return "_"
+	case "strikethrough":
+		return "~~"
+	case "link":
+		if safeURL(f.Url) != "" {
+			return "["
+		}
+	case "mention":
+		if f.Blog != nil && safeURL(f.Blog.Url) != "" {
+			return "["
+		}
+	}
+	return ""
+}
+
+func (markdownInline) close(f TextFormatting) string {
+	switch f.Type {
+	case "bold":
+		return "**"
+	case "italic":
+		return "_"
+	case "strikethrough":
+		return "~~"
+	case "link":
+		return "](" + markdownURL(f.Url) + ")"
+	case "mention":
+		return "](" + markdownURL(f.Blog.Url) + ")"
+	}
+	return ""
+}
+
+func (markdownInline) escape(text string) string {
+	return strings.Replace(escapeMarkdown(text), "\n", "  \n", -1)
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`, `<`, `\<`, `>`, `\>`, `~`, `\~`, `|`, `\|`,
+)
+
+// Escapes characters with an inline meaning in Markdown
+func escapeMarkdown(text string) string {
+	return markdownEscaper.Replace(text)
+}
+
+var markdownLineStartPattern = regexp.MustCompile(`^(\s*)([#+-]|\d+\.)(\s|$)`)
+
+// Escapes characters which would turn a line into a heading or list item
+func escapeMarkdownLineStart(text string) string {
+	return markdownLineStartPattern.ReplaceAllStringFunc(text, func(m string) string {
+		parts := markdownLineStartPattern.FindStringSubmatch(m)
+		marker := parts[2]
+		if strings.HasSuffix(marker, ".") {
+			marker = strings.TrimSuffix(marker, ".") + `\.`
+		} else {
+			marker = `\` + marker
+		}
+		return parts[1] + marker + parts[3]
+	})
+}
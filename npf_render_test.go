@@ -0,0 +1,145 @@
+package tumblr
+
+import (
+	"testing"
+)
+
+func TestRenderInlineHTMLFormatting(t *testing.T) {
+	formatting := []TextFormatting{
+		{Start: 0, End: 11, Type: "bold"},
+		{Start: 6, End: 16, Type: "italic"},
+		{Start: 12, End: 16, Type: "link", Url: "https://example.com/?a=1&b=2"},
+	}
+	actual := renderInline("Hello world <go>", formatting, htmlInline{})
+	expected := "<strong>Hello <em>world</em></strong> <em><a href=\"https://example.com/?a=1&amp;b=2\">&lt;go&gt;</a></em>"
+	if actual != expected {
+		t.Fatalf("Unexpected inline HTML\nexpected %s\ngot      %s", expected, actual)
+	}
+}
+
+func TestRenderInlineUsesUTF16Offsets(t *testing.T) {
+	actual := renderInline("😀 hi", []TextFormatting{{Start: 3, End: 5, Type: "bold"}}, htmlInline{})
+	if actual != "😀 <strong>hi</strong>" {
+		t.Fatalf("Formatting offsets should be UTF-16 code units, got %s", actual)
+	}
+}
+
+func TestRenderInlineDropsUnsafeMarkup(t *testing.T) {
+	formatting := []TextFormatting{
+		{Start: 0, End: 5, Type: "link", Url: "javascript:alert(1)"},
+		{Start: 0, End: 5, Type: "color", Hex: "red\" onclick=\"x"},
+		{Start: 0, End: 5, Type: "color", Hex: "#ff0000"},
+	}
+	actual := renderInline("click", formatting, htmlInline{})
+	if actual != "<span style=\"color: #ff0000\">click</span>" {
+		t.Fatalf("Unsafe links and colors should be dropped, got %s", actual)
+	}
+}
+
+func TestRenderInlineMarkdownWhitespace(t *testing.T) {
+	formatting := []TextFormatting{
+		{Start: 0, End: 6, Type: "bold"},
+		{Start: 6, End: 12, Type: "italic"},
+		{Start: 13, End: 17, Type: "link", Url: "https://example.com"},
+	}
+	actual := renderInline("bold, *both* link", formatting, markdownInline{})
+	expected := "**bold,** _\\*both\\*_ [link](https://example.com)"
+	if actual != expected {
+		t.Fatalf("Unexpected inline Markdown\nexpected %s\ngot      %s", expected, actual)
+	}
+}
+
+func TestNPFRendererHTMLLayout(t *testing.T) {
+	truncate := 1
+	content := []ContentBlock{
+		{Type: "text", Text: "What is this?"},
+		{Type: "text", Subtype: "heading1", Text: "Answer"},
+		{Type: "image", Media: MediaList{{Url: "https://64.media.tumblr.com/a.jpg", Width: 500, Height: 400}}, AltText: "a \"cat\""},
+		{Type: "text", Subtype: "ordered-list-item", Text: "one"},
+		{Type: "text", Subtype: "unordered-list-item", Text: "nested", IndentLevel: 1},
+		{Type: "text", Subtype: "ordered-list-item", Text: "two"},
+	}
+	layout := []LayoutBlock{
+		{Type: "ask", Blocks: []int{0}, Attribution: &Attribution{Type: "blog", Blog: &BlogMention{Name: "asker", Url: "https://asker.tumblr.com"}}},
+		{Type: "rows", Display: []LayoutDisplay{{Blocks: []int{1, 2}}, {Blocks: []int{3}}, {Blocks: []int{4}}, {Blocks: []int{5}}}, TruncateAfter: &truncate},
+	}
+	expected := "<blockquote class=\"npf-ask\"><p class=\"npf-ask-attribution\"><a href=\"https://asker.tumblr.com\">asker</a> asked:</p><p>What is this?</p></blockquote>" +
+		"<div class=\"npf-row\"><h1>Answer</h1><figure class=\"npf-image\"><img src=\"https://64.media.tumblr.com/a.jpg\" alt=\"a &#34;cat&#34;\" width=\"500\" height=\"400\"/></figure></div>" +
+		"<!-- more -->" +
+		"<ol><li>one<ul><li>nested</li></ul></li><li>two</li></ol>"
+	if actual := RenderNPFHTML(content, layout); actual != expected {
+		t.Fatalf("Unexpected HTML\nexpected %s\ngot      %s", expected, actual)
+	}
+	r := NPFRenderer{Truncate: true, ReadMoreUrl: "https://blog.tumblr.com/post/1"}
+	expected = "<blockquote class=\"npf-ask\"><p class=\"npf-ask-attribution\"><a href=\"https://asker.tumblr.com\">asker</a> asked:</p><p>What is this?</p></blockquote>" +
+		"<div class=\"npf-row\"><h1>Answer</h1><figure class=\"npf-image\"><img src=\"https://64.media.tumblr.com/a.jpg\" alt=\"a &#34;cat&#34;\" width=\"500\" height=\"400\"/></figure></div>" +
+		"<p class=\"npf-read-more\"><a href=\"https://blog.tumblr.com/post/1\">Keep reading</a></p>"
+	if actual := r.HTML(content, layout); actual != expected {
+		t.Fatalf("Unexpected truncated HTML\nexpected %s\ngot      %s", expected, actual)
+	}
+}
+
+func TestNPFRendererEmbeds(t *testing.T) {
+	content := []ContentBlock{{Type: "video", Url: "https://youtube.com/x", EmbedHtml: "<iframe></iframe>"}}
+	if actual := RenderNPFHTML(content, nil); actual != "<p class=\"npf-video\"><a href=\"https://youtube.com/x\">https://youtube.com/x</a></p>" {
+		t.Fatalf("Embeds should not be rendered by default, got %s", actual)
+	}
+	r := NPFRenderer{AllowEmbeds: true}
+	if actual := r.HTML(content, nil); actual != "<iframe></iframe>" {
+		t.Fatalf("Embeds should be rendered when allowed, got %s", actual)
+	}
+}
+
+func TestNPFRendererMarkdown(t *testing.T) {
+	truncate := 2
+	content := []ContentBlock{
+		{Type: "text", Text: "Why?"},
+		{Type: "text", Subtype: "heading2", Text: "Because"},
+		{Type: "text", Subtype: "ordered-list-item", Text: "one"},
+		{Type: "text", Subtype: "ordered-list-item", Text: "two"},
+		{Type: "text", Subtype: "unordered-list-item", Text: "sub", IndentLevel: 1},
+		{Type: "text", Text: "- not a list"},
+		{Type: "link", Url: "https://example.com", Title: "Example", Description: "An example"},
+		{Type: "image", Media: MediaList{{Url: "https://64.media.tumblr.com/a.jpg"}}, AltText: "alt", Caption: "cap"},
+	}
+	layout := []LayoutBlock{
+		{Type: "ask", Blocks: []int{0}},
+		{Type: "rows", TruncateAfter: &truncate},
+	}
+	expected := "> Anonymous asked:\n> \n> Why?\n\n" +
+		"## Because\n\n1. one\n\n<!-- more -->\n\n" +
+		"2. two\n    - sub\n\n\\- not a list\n\n[Example](https://example.com)  \nAn example\n\n![alt](https://64.media.tumblr.com/a.jpg)\n\n_cap_"
+	if actual := RenderNPFMarkdown(content, layout); actual != expected {
+		t.Fatalf("Unexpected Markdown\nexpected %q\ngot      %q", expected, actual)
+	}
+}
+
+func TestSafeURL(t *testing.T) {
+	cases := map[string]string{
+		"https://example.com/x": "https://example.com/x",
+		"mailto:a@example.com":  "mailto:a@example.com",
+		"/relative":             "/relative",
+		"javascript:alert(1)":   "",
+		"data:text/html,x":      "",
+		"":                      "",
+	}
+	for in, expected := range cases {
+		if actual := safeURL(in); actual != expected {
+			t.Errorf("safeURL(%q) expected %q, got %q", in, expected, actual)
+		}
+	}
+}
+
+func TestNPFRendererMarkdownEscapesDestinations(t *testing.T) {
+	content := []ContentBlock{
+		{Type: "text", Text: "link", Formatting: []TextFormatting{{Start: 0, End: 4, Type: "link", Url: "https://x/a)![y](javascript:alert(1))"}}},
+		{Type: "link", Url: "https://x/a) [evil](https://evil.example)"},
+		{Type: "image", Media: MediaList{{Url: "https://x/b <c>.jpg"}}},
+	}
+	expected := "[link](https://x/a%29!%5By%5D%28javascript:alert%281%29%29)\n\n" +
+		"[https://x/a) \\[evil\\](https://evil.example)](https://x/a%29%20%5Bevil%5D%28https://evil.example%29)\n\n" +
+		"![](https://x/b%20%3Cc%3E.jpg)"
+	if actual := RenderNPFMarkdown(content, nil); actual != expected {
+		t.Fatalf("Unexpected Markdown\nexpected %q\ngot      %q", expected, actual)
+	}
+}
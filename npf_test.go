@@ -0,0 +1,52 @@
+package tumblr
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMediaListUnmarshalObjectOrArray(t *testing.T) {
+	blocks := []ContentBlock{}
+	body := `[
+		{"type": "image", "media": [{"url": "https://64.media.tumblr.com/a.jpg", "width": 500}], "poster": {"url": "https://64.media.tumblr.com/a.gif"}},
+		{"type": "video", "media": {"url": "https://va.media.tumblr.com/v.mp4", "type": "video/mp4"}}
+	]`
+	if err := json.Unmarshal([]byte(body), &blocks); err != nil {
+		t.Fatal("Unexpected error unmarshalling content blocks", err)
+	}
+	if len(blocks[0].Media) != 1 || blocks[0].Media[0].Width != 500 {
+		t.Fatal("Image media array was not decoded")
+	}
+	if len(blocks[0].Poster) != 1 || len(blocks[1].Media) != 1 || blocks[1].Media[0].Type != "video/mp4" {
+		t.Fatal("Single media objects should be decoded into a one item list")
+	}
+}
+
+func TestContentBlockMarshalMediaShape(t *testing.T) {
+	video, err := json.Marshal(ContentBlock{Type: "video", Media: MediaList{{Url: "v.mp4"}}})
+	if err != nil {
+		t.Fatal("Unexpected error marshalling video block", err)
+	}
+	if !strings.Contains(string(video), `"media":{"url":"v.mp4"}`) {
+		t.Fatalf("Video media should be marshalled as an object, got %s", video)
+	}
+	image, _ := json.Marshal(ContentBlock{Type: "image", Media: MediaList{{Url: "a.jpg"}}, Poster: MediaList{{Url: "a.gif"}}})
+	if !strings.Contains(string(image), `"media":[{"url":"a.jpg"}]`) || !strings.Contains(string(image), `"poster":{"url":"a.gif"}`) {
+		t.Fatalf("Image media should be an array and poster an object, got %s", image)
+	}
+	text, _ := json.Marshal(ContentBlock{Type: "text", Text: "hi"})
+	if string(text) != `{"type":"text","text":"hi"}` {
+		t.Fatalf("Empty fields should be omitted, got %s", text)
+	}
+}
+
+func TestUtf16Helpers(t *testing.T) {
+	s := "a😀b"
+	if utf16Len(s) != 4 {
+		t.Fatalf("Expected UTF-16 length 4, got %d", utf16Len(s))
+	}
+	if utf16Slice(s, 1, 3) != "😀" || utf16Slice(s, 3, 10) != "b" || utf16Slice(s, 3, 1) != "" {
+		t.Fatal("UTF-16 slicing returned the wrong substring")
+	}
+}
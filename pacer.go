@@ -0,0 +1,59 @@
+package tumblr
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Pacer spaces out the starts of requests or operations so that they do not exceed a rate. It is safe for
+// concurrent use: batches and crawls given the same Pacer share its rate.
+type Pacer struct {
+	interval time.Duration
+	mutex    sync.Mutex
+	next     time.Time
+}
+
+// NewPacer creates a Pacer allowing perSecond starts per second; zero or less means no limit.
+func NewPacer(perSecond float64) *Pacer {
+	return &Pacer{interval: rateInterval(perSecond)}
+}
+
+// Returns the time between starts for a rate per second; zero, meaning unpaced, for a rate of zero or less
+func rateInterval(perSecond float64) time.Duration {
+	if perSecond <= 0 {
+		return 0
+	}
+	return time.Duration(float64(time.Second) / perSecond)
+}
+
+// Wait blocks until the next start slot, or until ctx is done and returns its error. A nil Pacer does not wait.
+func (p *Pacer) Wait(ctx context.Context) error {
+	if p == nil {
+		return ctx.Err()
+	}
+	return p.wait(ctx, p.interval)
+}
+
+// Waits for the next start slot, interval after the previous one, or until the context is done
+func (p *Pacer) wait(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return ctx.Err()
+	}
+	p.mutex.Lock()
+	now := time.Now()
+	if p.next.Before(now) {
+		p.next = now
+	}
+	slot := p.next
+	p.next = p.next.Add(interval)
+	p.mutex.Unlock()
+	timer := time.NewTimer(slot.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -5,46 +5,56 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
-	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 )
 
 // Posts represents a list of MiniPosts, which have a minimal set of information.
 type Posts struct {
 	client      ClientInterface
-	response    Response
 	parsedPosts []PostInterface
+	decodeErrs  PostDecodeErrors
 	Posts       []MiniPost `json:"posts"`
 	TotalPosts  int64      `json:"total_posts"`
 }
 
-// All will retrieve fully fleshed post data from stubs and cache result.
+// All returns the fully fleshed posts, which are decoded along with the response.
+// Posts which fail to decode are kept as RawPosts and reported by DecodeErrors.
 func (p *Posts) All() ([]PostInterface, error) {
-	var err error = nil
-	if p.parsedPosts == nil {
-		r := struct {
-			Response struct {
-				Posts []PostInterface `json:"posts"`
-			} `json:"response"`
-		}{}
-		r.Response.Posts = makePostsFromMinis(p.Posts, p.client)
-		if err = json.Unmarshal(p.response.body, &r); err != nil {
-			p.parsedPosts = []PostInterface{}
-		} else {
-			p.parsedPosts = r.Response.Posts
-		}
+	if err := p.decode(); err != nil {
+		return nil, err
+	}
+	return p.parsedPosts, nil
+}
+
+// DecodeErrors returns the posts which failed to decode, and are kept as RawPosts.
+func (p *Posts) DecodeErrors() PostDecodeErrors {
+	p.decode()
+	return p.decodeErrs
+}
+
+// Decodes the posts, along with their errors, unless the response already did. A Posts built without a
+// response only has its MiniPosts to go on.
+func (p *Posts) decode() error {
+	if p.parsedPosts != nil {
+		return nil
+	}
+	posts, errs, err := decodeMiniPosts(p.Posts, p.client)
+	if err != nil {
+		return err
 	}
-	return p.parsedPosts, err
+	p.parsedPosts, p.decodeErrs = posts, errs
+	return nil
 }
 
 // Get retrieves a single Post entity at a given index or returns nil if index is out of bounds.
 func (p *Posts) Get(index uint) PostInterface {
-	if posts, err := p.All(); err == nil {
-		if index >= uint(len(posts)) {
-			return nil
-		}
-		return posts[index]
+	posts, _ := p.All()
+	if index >= uint(len(posts)) {
+		return nil
 	}
-	return nil
+	return posts[index]
 }
 
 // MiniPost stores the basics for what is needed in a Post.
@@ -96,19 +106,23 @@ type Post struct {
 	FeaturedTimestamp uint64            `json:"featured_timestamp,omitempty"`
 	TrackName         string            `json:"track_name,omitempty"`
 	Trail             []ReblogTrailItem `json:"trail"`
+	Content           []ContentBlock    `json:"content,omitempty"`
+	Layout            []LayoutBlock     `json:"layout,omitempty"`
 }
 
 // ReblogTrailItem represents an item in the "trail" to the original, root Post.
+// Legacy trails carry HTML in Content; NPF trails carry ContentBlocks and Layout instead.
+// Items whose blog no longer exists only carry a BrokenBlogName.
 type ReblogTrailItem struct {
-	Blog          Blog   `json:"blog"`
-	Content       string `json:"content"`
-	ContentRaw    string `json:"content_raw"`
-	IsCurrentItem bool   `json:"is_current_item"`
-	IsRootItem    bool   `json:"is_root_item,omitempty"`
-	Post          struct {
-		// sometimes an actual int, sometimes a numeric string, always a headache
-		Id interface{} `json:"id"`
-	} `json:"post"`
+	Blog           Blog           `json:"blog"`
+	BrokenBlogName string         `json:"broken_blog_name,omitempty"`
+	Content        string         `json:"content"`
+	ContentRaw     string         `json:"content_raw"`
+	ContentBlocks  []ContentBlock `json:"-"`
+	Layout         []LayoutBlock  `json:"layout,omitempty"`
+	IsCurrentItem  bool           `json:"is_current_item"`
+	IsRootItem     bool           `json:"is_root_item,omitempty"`
+	Post           TrailPost      `json:"post"`
 }
 
 // PostInterface is the interface for any concrete Post type to retrieve a property.
@@ -117,6 +131,46 @@ type PostInterface interface {
 	GetSelf() *Post
 }
 
+// RawPost holds a post of a type without a registered decoder (such as NPF "blocks" posts), or a post which
+// failed to decode. The common Post fields are populated and the original JSON is kept in Raw.
+type RawPost struct {
+	Post
+	Raw json.RawMessage `json:"-"`
+	// Set when the post's type has no registered decoder; posts which failed to decode are reported by a
+	// PostDecodeError instead
+	UnknownType bool `json:"-"`
+}
+
+// PostDecodeError describes a single post of a response which could not be decoded.
+type PostDecodeError struct {
+	Index int
+	Id    uint64
+	Type  string
+	Err   error
+}
+
+// Error implements the error interface.
+func (e *PostDecodeError) Error() string {
+	return fmt.Sprintf("Unable to decode %s post %d at index %d: %s", e.Type, e.Id, e.Index, e.Err)
+}
+
+// Unwrap returns the underlying decoding error.
+func (e *PostDecodeError) Unwrap() error {
+	return e.Err
+}
+
+// PostDecodeErrors lists the posts of a response which failed to decode, see Posts.DecodeErrors.
+type PostDecodeErrors []*PostDecodeError
+
+// Error implements the error interface.
+func (e PostDecodeErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
 // QuotePost represents a Quote Post.
 type QuotePost struct {
 	Post
@@ -243,12 +297,9 @@ func (p *Post) String() string {
 	return jsonStringify(*p)
 }
 
-// GetProperty uses reflection to retrieve one-off field values.
+// GetProperty uses reflection to retrieve one-off field values by Go field name or JSON name.
 func (p *Post) GetProperty(key string) (interface{}, error) {
-	if field, exists := reflect.TypeOf(p).Elem().FieldByName(key); exists {
-		return reflect.ValueOf(p).Elem().FieldByIndex(field.Index), nil
-	}
-	return nil, errors.New(fmt.Sprintf("Property %s does not exist", key))
+	return getProperty(p, key)
 }
 
 // GetSelf returns the Post from a PostInterface.
@@ -262,16 +313,24 @@ func queryPosts(client ClientInterface, path, name string, params url.Values) (*
 	if err != nil {
 		return nil, err
 	}
-	posts := struct {
-		Response Posts `json:"response"`
+	result := struct {
+		Response struct {
+			Posts      *decodedPostList `json:"posts"`
+			TotalPosts int64            `json:"total_posts"`
+		} `json:"response"`
 	}{}
-	if err = json.Unmarshal(response.body, &posts); err == nil {
-		posts.Response.response = response
-		posts.Response.client = client
-		// store
-		return &posts.Response, nil
+	if err = json.Unmarshal(response.body, &result); err != nil {
+		return nil, err
 	}
-	return nil, err
+	posts := &Posts{
+		client:     client,
+		TotalPosts: result.Response.TotalPosts,
+	}
+	if list := result.Response.Posts; list != nil && list.posts != nil {
+		posts.Posts = list.minis()
+		posts.parsedPosts, posts.decodeErrs = list.result(client)
+	}
+	return posts, nil
 }
 
 // GetPosts retrieves a blog's posts, in the API docs you can find how to filter by ID, type, etc.
@@ -305,15 +364,22 @@ func doPost(client ClientInterface, path, blogName string, params url.Values) (*
 	}
 	post := struct {
 		Response struct {
-			Id uint64 `json:"id"`
+			// NPF endpoints return the id as a string
+			Id json.Number `json:"id"`
 		} `json:"response"`
 	}{}
-	if err = json.Unmarshal(response.body, &post); err == nil {
-		ref := NewPostRefById(client, post.Response.Id)
-		ref.BlogName = blogName
-		return ref, nil
+	if err = json.Unmarshal(response.body, &post); err != nil {
+		return nil, err
+	}
+	var id uint64
+	if post.Response.Id != "" {
+		if id, err = strconv.ParseUint(string(post.Response.Id), 10, 64); err != nil {
+			return nil, err
+		}
 	}
-	return nil, err
+	ref := NewPostRefById(client, id)
+	ref.BlogName = blogName
+	return ref, nil
 }
 
 // NewPostRefById creates a PostRef for the id.
@@ -344,6 +410,46 @@ func CreatePost(client ClientInterface, name string, params url.Values) (*PostRe
 	return doPost(client, "/blog/%s/post", name, params)
 }
 
+// Util method for encoding NPF content and layout into a copy of the given params
+func npfParams(content []ContentBlock, layout []LayoutBlock, params url.Values) (url.Values, error) {
+	if len(content) < 1 {
+		return nil, errors.New("No content provided")
+	}
+	params = copyParams(params)
+	encoded, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	params.Set("content", string(encoded))
+	if len(layout) > 0 {
+		if encoded, err = json.Marshal(layout); err != nil {
+			return nil, err
+		}
+		params.Set("layout", string(encoded))
+	}
+	return params, nil
+}
+
+// CreateNPFPost will create a Post on tumblr for the blog in name from NPF content blocks and layout.
+// Other NPF fields (state, tags, date, publish_on, source_url, ...) can be passed in params.
+func CreateNPFPost(client ClientInterface, name string, content []ContentBlock, layout []LayoutBlock, params url.Values) (*PostRef, error) {
+	params, err := npfParams(content, layout, params)
+	if err != nil {
+		return nil, err
+	}
+	return doPost(client, "/blog/%s/posts", name, params)
+}
+
+// EditNPFPost will replace the content and layout of the Post in postId on the blog in name.
+func EditNPFPost(client ClientInterface, name string, postId uint64, content []ContentBlock, layout []LayoutBlock, params url.Values) error {
+	params, err := npfParams(content, layout, params)
+	if err != nil {
+		return err
+	}
+	_, err = client.PutWithParams(blogPath("/blog/%s/posts/", name)+strconv.FormatUint(postId, 10), params)
+	return err
+}
+
 // EditPost will update a Post on tumblr for the blog in name and Post in postId.
 func EditPost(client ClientInterface, blogName string, postId uint64, params url.Values) error {
 	_, err := client.PostWithParams(blogPath("/blog/%s/post/edit", blogName), setPostId(postId, params))
@@ -380,27 +486,95 @@ func (p *PostRef) Delete() error {
 	return DeletePost(p.client, p.BlogName, p.Id)
 }
 
+// Registered constructors for each post type
+var postTypes = struct {
+	sync.RWMutex
+	factories map[string]func() PostInterface
+}{
+	factories: map[string]func() PostInterface{
+		"quote":  func() PostInterface { return &QuotePost{} },
+		"chat":   func() PostInterface { return &ChatPost{} },
+		"photo":  func() PostInterface { return &PhotoPost{} },
+		"text":   func() PostInterface { return &TextPost{} },
+		"link":   func() PostInterface { return &LinkPost{} },
+		"answer": func() PostInterface { return &AnswerPost{} },
+		"audio":  func() PostInterface { return &AudioPost{} },
+		"video":  func() PostInterface { return &VideoPost{} },
+	},
+}
+
+// RegisterPostType makes posts of the given type decode into the value returned by factory, which must be a
+// pointer to a struct embedding Post. Registering an existing type replaces its decoder.
+func RegisterPostType(postType string, factory func() PostInterface) {
+	postTypes.Lock()
+	defer postTypes.Unlock()
+	postTypes.factories[postType] = factory
+}
+
 // Utility function to create the proper instance of Post and return a reference to the generic interface
 func makePostFromType(t string) (PostInterface, error) {
-	switch t {
-	case "quote":
-		return &QuotePost{}, nil
-	case "chat":
-		return &ChatPost{}, nil
-	case "photo":
-		return &PhotoPost{}, nil
-	case "text":
-		return &TextPost{}, nil
-	case "link":
-		return &LinkPost{}, nil
-	case "answer":
-		return &AnswerPost{}, nil
-	case "audio":
-		return &AudioPost{}, nil
-	case "video":
-		return &VideoPost{}, nil
+	postTypes.RLock()
+	factory, ok := postTypes.factories[t]
+	postTypes.RUnlock()
+	if ok {
+		return factory(), nil
 	}
-	return &Post{}, errors.New(fmt.Sprintf("Unknown type %s", t))
+	return &RawPost{}, errors.New(fmt.Sprintf("Unknown type %s", t))
+}
+
+// Decodes a single post into the concrete type registered for its type. Posts of unknown types become
+// RawPosts; posts failing to decode become RawPosts as well, and the error is returned.
+func decodePost(raw json.RawMessage) (PostInterface, error) {
+	postType, err := scanPostType(raw)
+	if err != nil {
+		return &RawPost{Raw: raw}, err
+	}
+	post, unknown := makePostFromType(postType)
+	if err = json.Unmarshal(raw, post); err != nil {
+		fallback := &RawPost{Raw: raw}
+		// the common fields are still worth having, if they decode
+		json.Unmarshal(raw, &fallback.MiniPost)
+		return fallback, err
+	}
+	if r, ok := post.(*RawPost); ok {
+		r.Raw = raw
+		r.UnknownType = unknown != nil
+	}
+	return post, nil
+}
+
+// Decodes each raw post of a response, collecting per-post failures into a PostDecodeErrors error
+func decodePosts(raws []json.RawMessage, client ClientInterface) ([]PostInterface, error) {
+	posts := make([]PostInterface, 0, len(raws))
+	var errs PostDecodeErrors
+	for i, raw := range raws {
+		post, err := decodePost(raw)
+		self := post.GetSelf()
+		if err != nil {
+			errs = append(errs, &PostDecodeError{Index: i, Id: self.Id, Type: self.Type, Err: err})
+		}
+		self.client = client
+		posts = append(posts, post)
+	}
+	if len(errs) > 0 {
+		return posts, errs
+	}
+	return posts, nil
+}
+
+// Decodes posts from the MiniPosts alone, for lists built without a response
+func decodeMiniPosts(minis []MiniPost, client ClientInterface) ([]PostInterface, PostDecodeErrors, error) {
+	raws := make([]json.RawMessage, 0, len(minis))
+	for _, mini := range minis {
+		raw, err := json.Marshal(mini)
+		if err != nil {
+			return nil, nil, err
+		}
+		raws = append(raws, raw)
+	}
+	posts, err := decodePosts(raws, client)
+	errs, _ := err.(PostDecodeErrors)
+	return posts, errs, nil
 }
 
 // Like will like this Post on behalf of the current user.
@@ -412,14 +586,3 @@ func (p *PostRef) Like() error {
 func (p *PostRef) Unlike() error {
 	return UnlikePost(p.client, p.Id, p.ReblogKey)
 }
-
-// Create an array of PostInterfaces based on the array of MiniPost objects provided
-func makePostsFromMinis(minis []MiniPost, client ClientInterface) []PostInterface {
-	posts := []PostInterface{}
-	for _, mini := range minis {
-		post, _ := makePostFromType(mini.Type)
-		post.GetSelf().client = client
-		posts = append(posts, post)
-	}
-	return posts
-}
@@ -0,0 +1,251 @@
+package tumblr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Returns the end of the JSON string starting at raw[start], just past its closing quote
+func skipString(raw []byte, start int) int {
+	for i := start + 1; i < len(raw); i++ {
+		switch raw[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(raw)
+}
+
+// Reads the type discriminator of a post without decoding anything else. The bytes are scanned for the
+// top-level "type" key only, stopping there; the post as a whole is validated when it is decoded.
+func scanPostType(raw []byte) (string, error) {
+	trimmed := bytes.TrimSpace(raw)
+	if string(trimmed) == "null" {
+		return "", nil
+	}
+	if len(trimmed) < 1 || trimmed[0] != '{' {
+		return "", fmt.Errorf("Expected a post object, found %.20q", trimmed)
+	}
+	depth := 0
+	// whether the next string at the top level is a key
+	expectKey := false
+	for i := 0; i < len(raw); {
+		switch c := raw[i]; c {
+		case '"':
+			end := skipString(raw, i)
+			if depth == 1 && expectKey {
+				expectKey = false
+				if string(raw[i:end]) == `"type"` {
+					return readTypeValue(raw[end:])
+				}
+			}
+			i = end
+			continue
+		case '{', '[':
+			depth++
+			expectKey = c == '{' && depth == 1
+		case '}', ']':
+			depth--
+		case ',':
+			expectKey = depth == 1
+		}
+		i++
+	}
+	return "", nil
+}
+
+// Decodes the string following the "type" key
+func readTypeValue(rest []byte) (string, error) {
+	rest = bytes.TrimLeft(rest, " \t\r\n")
+	if len(rest) < 1 || rest[0] != ':' {
+		return "", errors.New("Expected a colon after the type key")
+	}
+	rest = bytes.TrimLeft(rest[1:], " \t\r\n")
+	if len(rest) < 1 || rest[0] != '"' {
+		return "", errors.New("Expected the post type to be a string")
+	}
+	var postType string
+	err := json.Unmarshal(rest[:skipString(rest, 0)], &postType)
+	return postType, err
+}
+
+// A post decoded straight into the concrete type its discriminator names, along with its decode error
+type typedPost struct {
+	post PostInterface
+	err  error
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. A post failing to decode is kept as a RawPost and
+// its error stored, so the surrounding decode carries on.
+func (t *typedPost) UnmarshalJSON(b []byte) error {
+	t.post, t.err = decodePost(b)
+	if raw, ok := t.post.(*RawPost); ok {
+		// b belongs to the decoder
+		raw.Raw = append(json.RawMessage{}, b...)
+	}
+	return nil
+}
+
+// A list of posts decoded in a single pass, straight into their concrete types.
+// Per-post failures do not abort the surrounding decode, and are kept in errs instead.
+type decodedPostList struct {
+	posts []PostInterface
+	errs  PostDecodeErrors
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (d *decodedPostList) UnmarshalJSON(b []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	token, err := decoder.Token()
+	if err != nil || token == nil {
+		return err
+	}
+	if token != json.Delim('[') {
+		return fmt.Errorf("Expected a list of posts, found %v", token)
+	}
+	d.posts = []PostInterface{}
+	for index := 0; decoder.More(); index++ {
+		entry := typedPost{}
+		if err = decoder.Decode(&entry); err != nil {
+			return err
+		}
+		if entry.err != nil {
+			self := entry.post.GetSelf()
+			d.errs = append(d.errs, &PostDecodeError{Index: index, Id: self.Id, Type: self.Type, Err: entry.err})
+		}
+		d.posts = append(d.posts, entry.post)
+	}
+	return nil
+}
+
+// Decodes every post of the stream
+func (d *decodedPostList) read(stream *PostStream) error {
+	d.posts = []PostInterface{}
+	for {
+		post, err := stream.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if decodeErr, ok := err.(*PostDecodeError); ok {
+			d.errs = append(d.errs, decodeErr)
+		} else if err != nil {
+			return err
+		}
+		d.posts = append(d.posts, post)
+	}
+}
+
+// Returns the decoded posts bound to client, along with any per-post errors
+func (d *decodedPostList) result(client ClientInterface) ([]PostInterface, PostDecodeErrors) {
+	for _, post := range d.posts {
+		post.GetSelf().client = client
+	}
+	return d.posts, d.errs
+}
+
+// Returns the MiniPosts of the decoded posts
+func (d *decodedPostList) minis() []MiniPost {
+	minis := make([]MiniPost, len(d.posts))
+	for i, post := range d.posts {
+		minis[i] = post.GetSelf().MiniPost
+	}
+	return minis
+}
+
+// PostStream decodes posts one at a time from a JSON array, straight into their concrete types, without
+// holding the whole input in memory.
+type PostStream struct {
+	decoder *json.Decoder
+	path    []string
+	index   int
+	started bool
+}
+
+// NewPostStream creates a PostStream reading from r. If path is given, the posts are read from the array
+// found by following those object keys, e.g. NewPostStream(body, "response", "posts") for a blog's posts.
+func NewPostStream(r io.Reader, path ...string) *PostStream {
+	return &PostStream{decoder: json.NewDecoder(r), path: path}
+}
+
+// Expects the next token to be the given delimiter
+func (s *PostStream) expectDelim(delim json.Delim) error {
+	token, err := s.decoder.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := token.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("Expected %s in posts stream, found %v", delim, token)
+	}
+	return nil
+}
+
+// Moves the decoder to the start of the posts array
+func (s *PostStream) start() error {
+	for _, key := range s.path {
+		if err := s.expectDelim('{'); err != nil {
+			return err
+		}
+		for {
+			token, err := s.decoder.Token()
+			if err != nil {
+				return err
+			}
+			if token == json.Delim('}') {
+				return fmt.Errorf("No %s key in posts stream", key)
+			}
+			if token == key {
+				break
+			}
+			// skip the value of any other key
+			skipped := json.RawMessage{}
+			if err = s.decoder.Decode(&skipped); err != nil {
+				return err
+			}
+		}
+	}
+	return s.expectDelim('[')
+}
+
+// Next decodes the next post. It returns io.EOF once the array is exhausted. A post which fails to decode
+// is returned as a RawPost along with a *PostDecodeError; the stream can continue past it.
+// Any other error ends the stream.
+func (s *PostStream) Next() (PostInterface, error) {
+	if !s.started {
+		s.started = true
+		if err := s.start(); err != nil {
+			return nil, err
+		}
+	}
+	if !s.decoder.More() {
+		return nil, io.EOF
+	}
+	entry := typedPost{}
+	if err := s.decoder.Decode(&entry); err != nil {
+		return nil, err
+	}
+	index := s.index
+	s.index++
+	if entry.err != nil {
+		self := entry.post.GetSelf()
+		return entry.post, &PostDecodeError{Index: index, Id: self.Id, Type: self.Type, Err: entry.err}
+	}
+	return entry.post, nil
+}
+
+// ReadPosts decodes all posts of a stream, see NewPostStream. Posts which fail to decode are kept as RawPosts
+// and reported in a PostDecodeErrors error.
+func ReadPosts(r io.Reader, path ...string) ([]PostInterface, error) {
+	list := decodedPostList{}
+	if err := list.read(NewPostStream(r, path...)); err != nil {
+		return list.posts, err
+	}
+	if posts, errs := list.result(nil); len(errs) > 0 {
+		return posts, errs
+	}
+	return list.posts, nil
+}
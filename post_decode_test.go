@@ -0,0 +1,215 @@
+package tumblr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPostStream(t *testing.T) {
+	body := `{"meta": {"status": 200}, "response": {"blog": {"name": "b"}, "posts": [
+		{"id": 1, "type": "quote", "text": "q"},
+		{"id": 2, "type": "photo", "photos": 5},
+		{"id": 3, "type": "blocks", "content": [{"type": "text", "text": "t"}]}
+	], "total_posts": 3}}`
+	stream := NewPostStream(strings.NewReader(body), "response", "posts")
+	post, err := stream.Next()
+	if quote, ok := AsQuote(post); err != nil || !ok || quote.Text != "q" {
+		t.Fatalf("Expected a quote post, got %+v, %v", post, err)
+	}
+	post, err = stream.Next()
+	decodeErr, ok := err.(*PostDecodeError)
+	if !ok || decodeErr.Index != 1 || decodeErr.Id != 2 {
+		t.Fatalf("Expected a decode error for the second post, got %v", err)
+	}
+	if raw, ok := AsRaw(post); !ok || raw.Id != 2 {
+		t.Fatal("Posts failing to decode should be returned raw")
+	}
+	if post, err = stream.Next(); err != nil || post.GetSelf().Id != 3 {
+		t.Fatalf("Stream should continue past decode errors, got %v", err)
+	}
+	if _, err = stream.Next(); err != io.EOF {
+		t.Fatalf("Expected the end of the stream, got %v", err)
+	}
+}
+
+func TestScanPostType(t *testing.T) {
+	for raw, expected := range map[string]string{
+		`{"id": 1, "type": "quote"}`: "quote",
+		`{"content": [{"type": "text", "text": "\"type\": \"x\""}], "blog": {"type": "y"}, "type" : "blocks"}`: "blocks",
+		`{"type": "\u0074ext"}`: "text",
+		`{"id": 1}`:             "",
+		`null`:                  "",
+	} {
+		if postType, err := scanPostType([]byte(raw)); err != nil || postType != expected {
+			t.Errorf("Expected type %q for %s, got %q, %v", expected, raw, postType, err)
+		}
+	}
+	for _, raw := range []string{`[]`, `{"type": 5}`, `"text"`} {
+		if _, err := scanPostType([]byte(raw)); err == nil {
+			t.Errorf("Expected an error for %s", raw)
+		}
+	}
+}
+
+func TestPostStreamMissingPath(t *testing.T) {
+	if _, err := ReadPosts(strings.NewReader(`{"response": {"users": []}}`), "response", "posts"); err == nil {
+		t.Fatal("A missing posts key should be an error")
+	}
+	if _, err := ReadPosts(strings.NewReader(`{"response": [`)); err == nil {
+		t.Fatal("Truncated input should be an error")
+	}
+}
+
+func TestReadPosts(t *testing.T) {
+	posts, err := ReadPosts(strings.NewReader(`[{"id": 1, "type": "text", "title": "a"}, {"id": 2, "type": "link"}]`))
+	if err != nil || len(posts) != 2 {
+		t.Fatalf("Expected 2 posts, got %d, %v", len(posts), err)
+	}
+	if text, ok := AsText(posts[0]); !ok || text.Title != "a" {
+		t.Fatal("Posts should be decoded into their concrete types")
+	}
+}
+
+// A dashboard page of n posts of every legacy type
+func largeDashboard(n int) []byte {
+	types := []string{
+		`"type": "text", "title": "Title", "body": "<p>` + strings.Repeat("Lorem ipsum dolor sit amet. ", 20) + `</p>"`,
+		`"type": "photo", "caption": "<p>Caption</p>", "photos": [{"caption": "", "original_size": {"url": "https://64.media.tumblr.com/a.jpg", "width": 1280, "height": 960}, "alt_sizes": [{"url": "https://64.media.tumblr.com/a_500.jpg", "width": 500, "height": 375}]}]`,
+		`"type": "quote", "text": "Quote", "source": "Source"`,
+		`"type": "link", "title": "Link", "url": "https://example.com", "description": "<p>Description</p>"`,
+		`"type": "video", "caption": "", "player": [{"width": 250, "embed_code": "<iframe></iframe>"}]`,
+	}
+	posts := make([]string, n)
+	for i := range posts {
+		posts[i] = fmt.Sprintf(`{"id": %d, "blog_name": "blog%d", "reblog_key": "key", "timestamp": 1500000000, "tags": ["a", "b"], "note_count": 12, %s}`, i+1, i%10, types[i%len(types)])
+	}
+	return []byte(`{"meta": {"status": 200, "msg": "OK"}, "response": {"posts": [` + strings.Join(posts, ",") + `]}}`)
+}
+
+// How posts used to be decoded: minis first, then each post's type, then the post itself
+func decodeDashboardTwoPass(body []byte) ([]PostInterface, error) {
+	result := struct {
+		Response struct {
+			Minis []MiniPost `json:"posts"`
+		} `json:"response"`
+	}{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	raws := struct {
+		Response struct {
+			Posts []json.RawMessage `json:"posts"`
+		} `json:"response"`
+	}{}
+	if err := json.Unmarshal(body, &raws); err != nil {
+		return nil, err
+	}
+	posts := []PostInterface{}
+	for _, raw := range raws.Response.Posts {
+		mini := MiniPost{}
+		if err := json.Unmarshal(raw, &mini); err != nil {
+			return nil, err
+		}
+		post, _ := makePostFromType(mini.Type)
+		if err := json.Unmarshal(raw, post); err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, nil
+}
+
+// How the post list was decoded before the json.Decoder: split into raw posts, then each post's type
+// unmarshalled, then the post itself
+func decodeDashboardSplit(body []byte) ([]PostInterface, error) {
+	raws := struct {
+		Response struct {
+			Posts []json.RawMessage `json:"posts"`
+		} `json:"response"`
+	}{}
+	if err := json.Unmarshal(body, &raws); err != nil {
+		return nil, err
+	}
+	posts := make([]PostInterface, len(raws.Response.Posts))
+	for i, raw := range raws.Response.Posts {
+		discriminator := struct {
+			Type string `json:"type"`
+		}{}
+		if err := json.Unmarshal(raw, &discriminator); err != nil {
+			return nil, err
+		}
+		post, _ := makePostFromType(discriminator.Type)
+		if err := json.Unmarshal(raw, post); err != nil {
+			return nil, err
+		}
+		posts[i] = post
+	}
+	return posts, nil
+}
+
+func TestLargeDashboardDecodesIdentically(t *testing.T) {
+	body := largeDashboard(50)
+	expected, err := decodeDashboardTwoPass(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dashboard, err := GetDashboard(newTestClient(string(body), nil), url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jsonStringify(dashboard.Posts) != jsonStringify(expected) {
+		t.Fatal("Single pass decoding should produce the same posts")
+	}
+	if split, _ := decodeDashboardSplit(body); jsonStringify(split) != jsonStringify(expected) {
+		t.Fatal("Split decoding should produce the same posts")
+	}
+}
+
+func BenchmarkDecodeDashboardTwoPass(b *testing.B) {
+	body := largeDashboard(200)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(body)))
+	for i := 0; i < b.N; i++ {
+		if _, err := decodeDashboardTwoPass(body); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeDashboardSplit(b *testing.B) {
+	body := largeDashboard(200)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(body)))
+	for i := 0; i < b.N; i++ {
+		if _, err := decodeDashboardSplit(body); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeDashboard(b *testing.B) {
+	client := newTestClient(string(largeDashboard(200)), nil)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(client.response.body)))
+	for i := 0; i < b.N; i++ {
+		if _, err := GetDashboard(client, url.Values{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadPostsStream(b *testing.B) {
+	body := largeDashboard(200)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(body)))
+	for i := 0; i < b.N; i++ {
+		if _, err := ReadPosts(bytes.NewReader(body), "response", "posts"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
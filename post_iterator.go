@@ -0,0 +1,98 @@
+package tumblr
+
+import (
+	"io"
+	"net/url"
+	"strconv"
+)
+
+// PostIterator yields posts one at a time; Next returns io.EOF once there are no more.
+// PostStream and PostPager are PostIterators.
+type PostIterator interface {
+	Next() (PostInterface, error)
+}
+
+// PostPager iterates over all of a blog's posts, fetching them a page at a time with GetPosts.
+type PostPager struct {
+	client ClientInterface
+	name   string
+	params url.Values
+	offset int
+	page   []PostInterface
+	// Decode errors of the current page, by index
+	errs    map[int]*PostDecodeError
+	index   int
+	total   int64
+	fetched bool
+	done    bool
+}
+
+// NewPostPager creates a PostPager over the posts of the blog in name. Filters such as type or tag can be
+// passed in params; the offset param is managed by the pager.
+func NewPostPager(client ClientInterface, name string, params url.Values) *PostPager {
+	if params == nil {
+		params = url.Values{}
+	}
+	return &PostPager{client: client, name: name, params: copyParams(params)}
+}
+
+// Next returns the next post, fetching the next page when the current one is exhausted.
+// A post which fails to decode is returned as a RawPost along with its *PostDecodeError, like PostStream.Next
+// does; iteration can continue past it.
+func (p *PostPager) Next() (PostInterface, error) {
+	for p.index >= len(p.page) {
+		if p.done {
+			return nil, io.EOF
+		}
+		params := copyParams(p.params)
+		params.Set("offset", strconv.Itoa(p.offset))
+		posts, err := GetPosts(p.client, p.name, params)
+		if err != nil {
+			return nil, err
+		}
+		p.page, _ = posts.All()
+		p.errs = map[int]*PostDecodeError{}
+		for _, decodeErr := range posts.DecodeErrors() {
+			p.errs[decodeErr.Index] = decodeErr
+		}
+		p.index = 0
+		p.total = posts.TotalPosts
+		p.fetched = true
+		p.offset += len(p.page)
+		p.done = len(p.page) < 1 || (p.total > 0 && int64(p.offset) >= p.total)
+	}
+	post := p.page[p.index]
+	p.index++
+	if decodeErr, ok := p.errs[p.index-1]; ok {
+		return post, decodeErr
+	}
+	return post, nil
+}
+
+// TotalPosts returns the number of posts the blog reported, or -1 before the first page is fetched.
+func (p *PostPager) TotalPosts() int64 {
+	if !p.fetched {
+		return -1
+	}
+	return p.total
+}
+
+// A PostIterator over a slice
+type slicePostIterator struct {
+	posts []PostInterface
+}
+
+// Next implements PostIterator.
+func (s *slicePostIterator) Next() (PostInterface, error) {
+	if len(s.posts) < 1 {
+		return nil, io.EOF
+	}
+	post := s.posts[0]
+	s.posts = s.posts[1:]
+	return post, nil
+}
+
+// IteratePosts returns a PostIterator over the given posts.
+func IteratePosts(posts []PostInterface) PostIterator {
+	return &slicePostIterator{posts: posts}
+}
@@ -0,0 +1,84 @@
+package tumblr
+
+import (
+	"errors"
+	"io"
+	"net/url"
+	"testing"
+)
+
+func TestPostPager(t *testing.T) {
+	client := newSequenceClient([]string{
+		`{"response": {"posts": [{"id": 3, "type": "text"}, {"id": 2, "type": "quote"}], "total_posts": 3}}`,
+		`{"response": {"posts": [{"id": 1, "type": "text"}], "total_posts": 3}}`,
+	}, []error{nil, nil})
+	pager := NewPostPager(client, "david", url.Values{"tag": {"cats"}})
+	if pager.TotalPosts() != -1 {
+		t.Error("Expected an unknown total before the first page")
+	}
+	ids := []uint64{}
+	for {
+		post, err := pager.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		ids = append(ids, post.GetSelf().Id)
+	}
+	if len(ids) != 3 || ids[0] != 3 || ids[2] != 1 {
+		t.Errorf("Unexpected posts %v", ids)
+	}
+	if client.calls != 2 || pager.TotalPosts() != 3 {
+		t.Errorf("Expected two pages of three posts, got %d calls and %d posts", client.calls, pager.TotalPosts())
+	}
+	if _, err := pager.Next(); err != io.EOF {
+		t.Errorf("Expected the pager to stay exhausted, got %v", err)
+	}
+}
+
+func TestPostPagerReportsDecodeErrors(t *testing.T) {
+	client := newSequenceClient([]string{
+		`{"response": {"posts": [{"id": 2, "type": "photo", "photos": 5}, {"id": 1, "type": "text"}], "total_posts": 2}}`,
+	}, []error{nil})
+	pager := NewPostPager(client, "david", nil)
+	post, err := pager.Next()
+	decodeErr := &PostDecodeError{}
+	if !errors.As(err, &decodeErr) || decodeErr.Id != 2 {
+		t.Fatalf("Expected a decode error for the first post, got %v", err)
+	}
+	if raw, ok := AsRaw(post); !ok || raw.Id != 2 {
+		t.Fatalf("Posts failing to decode should be returned raw, got %v", post)
+	}
+	if post, err = pager.Next(); err != nil || post.GetSelf().Id != 1 {
+		t.Fatalf("Pager should continue past decode errors, got %v, %v", post, err)
+	}
+}
+
+func TestPostPagerStopsOnEmptyPage(t *testing.T) {
+	client := newSequenceClient([]string{`{"response": {"posts": []}}`}, []error{nil})
+	if _, err := NewPostPager(client, "david", nil).Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
+
+func TestPostPagerReturnsClientError(t *testing.T) {
+	failure := errors.New("Failed")
+	client := newSequenceClient([]string{""}, []error{failure})
+	if _, err := NewPostPager(client, "david", nil).Next(); err != failure {
+		t.Errorf("Expected the client error, got %v", err)
+	}
+}
+
+func TestIteratePosts(t *testing.T) {
+	it := IteratePosts([]PostInterface{&TextPost{}, &QuotePost{}})
+	for i := 0; i < 2; i++ {
+		if post, err := it.Next(); err != nil || post == nil {
+			t.Fatalf("Unexpected post %d: %v", i, err)
+		}
+	}
+	if _, err := it.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package tumblr
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -142,8 +143,8 @@ func TestQueryPostsSuccess(t *testing.T) {
 	if err != nil {
 		t.Fatal("Posts should have been returned")
 	}
-	if string(response.response.body) != string(client.response.body) {
-		t.Fatal("Response should match client's response")
+	if response.client != client {
+		t.Fatal("Posts should keep the client")
 	}
 }
 
@@ -432,40 +433,29 @@ func TestPosts_All(t *testing.T) {
 }
 
 func TestPosts_AllWithJsonError(t *testing.T) {
-	client := newTestClient("{}", nil)
-	posts, err := GetPosts(client, "blog", url.Values{})
-	if err != nil {
-		t.Fatal("Failed to get posts")
-	}
-	posts.response.body = []byte("{")
-	_, err = posts.All()
-	if err == nil {
+	client := newTestClient(`{"response": {"posts": {}}}`, nil)
+	if _, err := GetPosts(client, "blog", url.Values{}); err == nil {
 		t.Fatal("Failed to return JSON parse error")
 	}
 }
 
 func TestPosts_Get(t *testing.T) {
-	client := newTestClient("{}", nil)
-	posts, err := GetPosts(client, "blog", url.Values{})
-	if err != nil {
-		t.Fatal("Failed to get posts")
-	}
 	mini := MiniPost{Type: "quote"}
-	posts.Posts = []MiniPost{mini}
 	mockResponse := struct {
 		Response struct {
 			Posts []QuotePost `json:"posts"`
 		} `json:"response"`
 	}{}
 	mockResponse.Response.Posts = []QuotePost{{Post: Post{PostRef: PostRef{MiniPost: mini}}}}
-	posts.response.body = []byte(jsonStringify(mockResponse))
-	if posts.parsedPosts != nil {
-		t.Fatal("Posts initialized with non-nil parsed posts")
+	client := newTestClient(jsonStringify(mockResponse), nil)
+	posts, err := GetPosts(client, "blog", url.Values{})
+	if err != nil {
+		t.Fatal("Failed to get posts")
 	}
-	post := posts.Get(0)
-	if posts.parsedPosts == nil {
-		t.Fatal("Get() should cache full array of parsed posts")
+	if posts.parsedPosts == nil || len(posts.Posts) != 1 {
+		t.Fatal("Posts should be decoded along with the response")
 	}
+	post := posts.Get(0)
 	if post.GetSelf().Type != mini.Type {
 		t.Fatalf("Get() should return correct type of mini post; expected %s, got %s", post.GetSelf().Type, mini.Type)
 	}
@@ -475,13 +465,228 @@ func TestPosts_Get(t *testing.T) {
 }
 
 func TestPosts_GetWithAllError(t *testing.T) {
-	client := newTestClient("{}", nil)
+	client := newTestClient(`{"response": {"posts": []}}`, nil)
 	posts, err := GetPosts(client, "blog", url.Values{})
 	if err != nil {
 		t.Fatal("Failed to get posts")
 	}
-	posts.response.body = []byte("{")
 	if post := posts.Get(0); post != nil {
-		t.Fatal("Get() should return nil on error from All()")
+		t.Fatal("Get() should return nil without posts")
+	}
+}
+
+func TestDoPostStringId(t *testing.T) {
+	client := newTestClient("{\"response\": {\"id\": \"1986\"}}", nil)
+	if result, err := doPost(client, "/blog/%s/posts", "david", url.Values{}); err != nil || result.Id != 1986 {
+		t.Fatal("String post ids should be parsed", err)
+	}
+}
+
+func TestCreateNPFPost(t *testing.T) {
+	client := newTestClient("{\"response\": {\"id\": \"1986\"}}", nil)
+	blog := "david"
+	content := ParseMarkdown("Hello **world**")
+	params := url.Values{}
+	params.Set("state", "draft")
+	client.confirmExpectedSet = expectClientCallParams(
+		t,
+		"CreateNPFPost",
+		http.MethodPost,
+		blogPath("/blog/%s/posts", blog),
+		url.Values{
+			"state":   []string{"draft"},
+			"content": []string{`[{"type":"text","text":"Hello world","formatting":[{"start":6,"end":11,"type":"bold"}]}]`},
+		},
+	)
+	ref, err := CreateNPFPost(client, blog, content, nil, params)
+	if err != nil || ref.Id != 1986 {
+		t.Fatal("NPF post should be created", err)
+	}
+	if params.Get("content") != "" {
+		t.Fatal("CreateNPFPost should not modify the given params")
+	}
+	if _, err := CreateNPFPost(client, blog, nil, nil, url.Values{}); err == nil {
+		t.Fatal("Creating a post without content should fail")
+	}
+}
+
+func TestEditNPFPost(t *testing.T) {
+	client := newTestClient("{}", nil)
+	blog := "david"
+	content := []ContentBlock{{Type: "text", Text: "edited"}}
+	layout := []LayoutBlock{{Type: "rows", Display: []LayoutDisplay{{Blocks: []int{0}}}}}
+	client.confirmExpectedSet = expectClientCallParams(
+		t,
+		"EditNPFPost",
+		http.MethodPut,
+		blogPath("/blog/%s/posts/1986", blog),
+		url.Values{
+			"content": []string{`[{"type":"text","text":"edited"}]`},
+			"layout":  []string{`[{"type":"rows","display":[{"blocks":[0]}]}]`},
+		},
+	)
+	if err := EditNPFPost(client, blog, 1986, content, layout, url.Values{}); err != nil {
+		t.Fatal("NPF post should be edited", err)
+	}
+}
+
+func TestMakePostFromUnknownTypeIsRaw(t *testing.T) {
+	post, err := makePostFromType("blocks")
+	if err == nil {
+		t.Fatal("Unknown type should generate an error")
+	}
+	if _, ok := post.(*RawPost); !ok {
+		t.Fatal("Unknown type should generate a RawPost")
+	}
+}
+
+type testCustomPost struct {
+	Post
+	Custom string `json:"custom"`
+}
+
+func TestRegisterPostType(t *testing.T) {
+	RegisterPostType("custom", func() PostInterface { return &testCustomPost{} })
+	defer func() {
+		postTypes.Lock()
+		delete(postTypes.factories, "custom")
+		postTypes.Unlock()
+	}()
+	posts, err := decodePosts([]json.RawMessage{json.RawMessage(`{"id": 1, "type": "custom", "custom": "value"}`)}, nil)
+	if err != nil {
+		t.Fatal("Registered type should decode", err)
+	}
+	if custom, ok := posts[0].(*testCustomPost); !ok || custom.Custom != "value" || custom.Id != 1 {
+		t.Fatal("Registered type should decode into its factory's value", posts[0])
+	}
+}
+
+func TestDecodePostsReportsPerPostErrors(t *testing.T) {
+	client := newTestClient("", nil)
+	raws := []json.RawMessage{
+		json.RawMessage(`{"id": 1, "type": "text", "title": "fine"}`),
+		json.RawMessage(`{"id": 2, "type": "photo", "photos": "not an array"}`),
+		json.RawMessage(`{"id": 3, "type": "blocks", "content": [{"type": "text", "text": "npf"}]}`),
+	}
+	posts, err := decodePosts(raws, client)
+	if len(posts) != 3 {
+		t.Fatal("All posts should be returned, including failed ones")
+	}
+	decodeErrs, ok := err.(PostDecodeErrors)
+	if !ok || len(decodeErrs) != 1 {
+		t.Fatal("A single decode error should be reported", err)
+	}
+	if e := decodeErrs[0]; e.Index != 1 || e.Id != 2 || e.Type != "photo" || e.Unwrap() == nil {
+		t.Fatal("Decode error should identify the failed post", e)
+	}
+	if text, ok := posts[0].(*TextPost); !ok || text.Title != "fine" || text.client != client {
+		t.Fatal("Valid posts should be decoded into their concrete type")
+	}
+	if failed, ok := posts[1].(*RawPost); !ok || string(failed.Raw) != string(raws[1]) || failed.Id != 2 || failed.UnknownType {
+		t.Fatal("Failed posts should be kept as raw posts of a known type")
+	}
+	blocks, ok := posts[2].(*RawPost)
+	if !ok || string(blocks.Raw) != string(raws[2]) || len(blocks.Content) != 1 || !blocks.UnknownType {
+		t.Fatal("Unknown types should be kept as raw posts flagged as such, with their common fields decoded")
+	}
+}
+
+func TestPosts_DecodeErrorsWithoutAll(t *testing.T) {
+	posts := &Posts{Posts: []MiniPost{{Id: 1, Type: "text"}, {Id: 2, Type: "blocks"}}}
+	if errs := posts.DecodeErrors(); len(errs) != 0 {
+		t.Fatal("MiniPosts should decode without errors", errs)
+	}
+	all, err := posts.All()
+	if err != nil || len(all) != 2 {
+		t.Fatal("All should return the posts decoded by DecodeErrors", all, err)
+	}
+	if raw, ok := all[1].(*RawPost); !ok || !raw.UnknownType {
+		t.Fatal("Unknown types should be flagged", all[1])
+	}
+}
+
+func TestPosts_AllKeepsValidPosts(t *testing.T) {
+	client := newTestClient(`{"response": {"posts": [{"id": 1, "type": "quote", "text": "q"}, {"id": 2, "type": "quote", "text": 5}]}}`, nil)
+	posts, err := GetPosts(client, "blog", url.Values{})
+	if err != nil {
+		t.Fatal("Failed to get posts")
+	}
+	all, err := posts.All()
+	if err != nil {
+		t.Fatal("Per-post decode errors should not fail the page", err)
+	}
+	if len(all) != 2 || all[0].(*QuotePost).Text != "q" {
+		t.Fatal("Valid posts should survive a failed sibling", all)
+	}
+	if errs := posts.DecodeErrors(); len(errs) != 1 || errs[0].Id != 2 {
+		t.Fatal("Per-post decode errors should be reported by DecodeErrors", errs)
+	}
+	if posts.Get(0) == nil {
+		t.Fatal("Get() should return valid posts despite decode errors")
+	}
+}
+
+func TestConcretePostGetProperty(t *testing.T) {
+	post := &QuotePost{Source: "somewhere"}
+	post.Id = 1986
+	post.Type = "quote"
+	for key, expected := range map[string]interface{}{
+		"Source": "somewhere",
+		"source": "somewhere",
+		"Id":     uint64(1986),
+		"id":     uint64(1986),
+		"type":   "quote",
+	} {
+		actual, err := post.GetProperty(key)
+		if err != nil {
+			t.Fatalf("Property %s should exist: %v", key, err)
+		}
+		if actual != expected {
+			t.Fatalf("Property %s expected %v got %v", key, expected, actual)
+		}
+	}
+	if _, err := post.GetProperty("client"); err == nil {
+		t.Fatal("Unexported fields should not be accessible")
+	}
+}
+
+type countingVisitor struct {
+	BasePostVisitor
+	photos int
+	raws   int
+}
+
+func (v *countingVisitor) VisitPhoto(*PhotoPost) error {
+	v.photos++
+	return nil
+}
+
+func (v *countingVisitor) VisitRaw(*RawPost) error {
+	v.raws++
+	return nil
+}
+
+func TestVisit(t *testing.T) {
+	v := &countingVisitor{}
+	for _, post := range []PostInterface{&PhotoPost{}, &TextPost{}, &RawPost{}, &PhotoPost{}, &Post{}} {
+		if err := Visit(post, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if v.photos != 2 || v.raws != 1 {
+		t.Fatalf("Unexpected visits: %d photos, %d raw", v.photos, v.raws)
+	}
+	if err := Visit(nil, v); err == nil {
+		t.Fatal("Visiting nothing should error")
+	}
+}
+
+func TestAsAccessors(t *testing.T) {
+	var post PostInterface = &VideoPost{}
+	if _, ok := AsVideo(post); !ok {
+		t.Fatal("Video post should be a video")
+	}
+	if p, ok := AsText(post); ok || p != nil {
+		t.Fatal("Video post should not be a text post")
 	}
 }
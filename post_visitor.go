@@ -0,0 +1,164 @@
+package tumblr
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// PostVisitor is implemented by types handling each concrete post type; see Visit.
+// Embed BasePostVisitor to only implement the methods of interest.
+type PostVisitor interface {
+	VisitText(*TextPost) error
+	VisitPhoto(*PhotoPost) error
+	VisitQuote(*QuotePost) error
+	VisitLink(*LinkPost) error
+	VisitChat(*ChatPost) error
+	VisitAnswer(*AnswerPost) error
+	VisitAudio(*AudioPost) error
+	VisitVideo(*VideoPost) error
+	VisitRaw(*RawPost) error
+	// VisitOther handles a bare *Post and types registered through RegisterPostType
+	VisitOther(PostInterface) error
+}
+
+// BasePostVisitor implements PostVisitor, ignoring every post.
+type BasePostVisitor struct{}
+
+func (BasePostVisitor) VisitText(*TextPost) error      { return nil }
+func (BasePostVisitor) VisitPhoto(*PhotoPost) error    { return nil }
+func (BasePostVisitor) VisitQuote(*QuotePost) error    { return nil }
+func (BasePostVisitor) VisitLink(*LinkPost) error      { return nil }
+func (BasePostVisitor) VisitChat(*ChatPost) error      { return nil }
+func (BasePostVisitor) VisitAnswer(*AnswerPost) error  { return nil }
+func (BasePostVisitor) VisitAudio(*AudioPost) error    { return nil }
+func (BasePostVisitor) VisitVideo(*VideoPost) error    { return nil }
+func (BasePostVisitor) VisitRaw(*RawPost) error        { return nil }
+func (BasePostVisitor) VisitOther(PostInterface) error { return nil }
+
+// Visit calls the method of the visitor matching the post's concrete type.
+func Visit(post PostInterface, v PostVisitor) error {
+	switch p := post.(type) {
+	case *TextPost:
+		return v.VisitText(p)
+	case *PhotoPost:
+		return v.VisitPhoto(p)
+	case *QuotePost:
+		return v.VisitQuote(p)
+	case *LinkPost:
+		return v.VisitLink(p)
+	case *ChatPost:
+		return v.VisitChat(p)
+	case *AnswerPost:
+		return v.VisitAnswer(p)
+	case *AudioPost:
+		return v.VisitAudio(p)
+	case *VideoPost:
+		return v.VisitVideo(p)
+	case *RawPost:
+		return v.VisitRaw(p)
+	case nil:
+		return errors.New("No post provided")
+	}
+	return v.VisitOther(post)
+}
+
+// AsText returns the post as a *TextPost, if it is one.
+func AsText(post PostInterface) (*TextPost, bool) {
+	p, ok := post.(*TextPost)
+	return p, ok
+}
+
+// AsPhoto returns the post as a *PhotoPost, if it is one.
+func AsPhoto(post PostInterface) (*PhotoPost, bool) {
+	p, ok := post.(*PhotoPost)
+	return p, ok
+}
+
+// AsQuote returns the post as a *QuotePost, if it is one.
+func AsQuote(post PostInterface) (*QuotePost, bool) {
+	p, ok := post.(*QuotePost)
+	return p, ok
+}
+
+// AsLink returns the post as a *LinkPost, if it is one.
+func AsLink(post PostInterface) (*LinkPost, bool) {
+	p, ok := post.(*LinkPost)
+	return p, ok
+}
+
+// AsChat returns the post as a *ChatPost, if it is one.
+func AsChat(post PostInterface) (*ChatPost, bool) {
+	p, ok := post.(*ChatPost)
+	return p, ok
+}
+
+// AsAnswer returns the post as an *AnswerPost, if it is one.
+func AsAnswer(post PostInterface) (*AnswerPost, bool) {
+	p, ok := post.(*AnswerPost)
+	return p, ok
+}
+
+// AsAudio returns the post as an *AudioPost, if it is one.
+func AsAudio(post PostInterface) (*AudioPost, bool) {
+	p, ok := post.(*AudioPost)
+	return p, ok
+}
+
+// AsVideo returns the post as a *VideoPost, if it is one.
+func AsVideo(post PostInterface) (*VideoPost, bool) {
+	p, ok := post.(*VideoPost)
+	return p, ok
+}
+
+// AsRaw returns the post as a *RawPost, if it is one.
+func AsRaw(post PostInterface) (*RawPost, bool) {
+	p, ok := post.(*RawPost)
+	return p, ok
+}
+
+// The embedded Post's GetProperty only sees the common fields, as its receiver is the Post itself, so each
+// concrete type shadows it to look up its own fields by Go field name or JSON name as well.
+
+func (p *QuotePost) GetProperty(key string) (interface{}, error)  { return getProperty(p, key) }
+func (p *ChatPost) GetProperty(key string) (interface{}, error)   { return getProperty(p, key) }
+func (p *TextPost) GetProperty(key string) (interface{}, error)   { return getProperty(p, key) }
+func (p *LinkPost) GetProperty(key string) (interface{}, error)   { return getProperty(p, key) }
+func (p *AnswerPost) GetProperty(key string) (interface{}, error) { return getProperty(p, key) }
+func (p *AudioPost) GetProperty(key string) (interface{}, error)  { return getProperty(p, key) }
+func (p *VideoPost) GetProperty(key string) (interface{}, error)  { return getProperty(p, key) }
+func (p *PhotoPost) GetProperty(key string) (interface{}, error)  { return getProperty(p, key) }
+func (p *RawPost) GetProperty(key string) (interface{}, error)    { return getProperty(p, key) }
+
+// Looks up an exported field of a struct (or pointer to one) by Go field name, falling back to its JSON name.
+// Fields of the outer type shadow those of embedded types, as in Go itself.
+func getProperty(v interface{}, key string) (interface{}, error) {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+	if value.Kind() == reflect.Struct {
+		if field, ok := value.Type().FieldByName(key); ok && field.PkgPath == "" {
+			return value.FieldByIndex(field.Index).Interface(), nil
+		}
+		for level := []reflect.Value{value}; len(level) > 0; {
+			var embedded []reflect.Value
+			for _, current := range level {
+				t := current.Type()
+				for i := 0; i < t.NumField(); i++ {
+					field := t.Field(i)
+					if field.Anonymous && field.Type.Kind() == reflect.Struct {
+						embedded = append(embedded, current.Field(i))
+						continue
+					}
+					if name := strings.Split(field.Tag.Get("json"), ",")[0]; field.PkgPath == "" && name == key {
+						return current.Field(i).Interface(), nil
+					}
+				}
+			}
+			level = embedded
+		}
+	}
+	return nil, errors.New(fmt.Sprintf("Property %s does not exist", key))
+}
@@ -0,0 +1,194 @@
+package tumblr
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Headers Tumblr uses to report the remaining API quota; resets are given in seconds from the response
+const (
+	RateLimitPerDayRemainingHeader  = "X-Ratelimit-Perday-Remaining"
+	RateLimitPerDayResetHeader      = "X-Ratelimit-Perday-Reset"
+	RateLimitPerHourRemainingHeader = "X-Ratelimit-Perhour-Remaining"
+	RateLimitPerHourResetHeader     = "X-Ratelimit-Perhour-Reset"
+)
+
+// RateLimitState is the API quota as last reported by Tumblr.
+// Remaining counts are -1 while unknown.
+type RateLimitState struct {
+	PerDayRemaining  int
+	PerDayReset      time.Time
+	PerHourRemaining int
+	PerHourReset     time.Time
+	// When the state was last updated from a response; zero if never
+	Updated time.Time
+}
+
+// Returns a state with no known limits
+func unknownRateLimitState() RateLimitState {
+	return RateLimitState{PerDayRemaining: -1, PerHourRemaining: -1}
+}
+
+// ParseRateLimitHeaders reads the rate limit headers of a response received at the given time.
+// The boolean result is false if the headers carried no rate limit information.
+func ParseRateLimitHeaders(headers http.Header, received time.Time) (RateLimitState, bool) {
+	state := unknownRateLimitState()
+	found := false
+	parse := func(remainingKey, resetKey string, remaining *int, reset *time.Time) {
+		if v, err := strconv.Atoi(headers.Get(remainingKey)); err == nil {
+			*remaining = v
+			found = true
+		}
+		if v, err := strconv.ParseInt(headers.Get(resetKey), 10, 64); err == nil {
+			*reset = received.Add(time.Duration(v) * time.Second)
+			found = true
+		}
+	}
+	parse(RateLimitPerDayRemainingHeader, RateLimitPerDayResetHeader, &state.PerDayRemaining, &state.PerDayReset)
+	parse(RateLimitPerHourRemainingHeader, RateLimitPerHourResetHeader, &state.PerHourRemaining, &state.PerHourReset)
+	if found {
+		state.Updated = received
+	}
+	return state, found
+}
+
+// Returns when the quota is expected to be available again if at most threshold requests remain, or the zero time
+func (s RateLimitState) exhaustedUntil(threshold int, now time.Time) time.Time {
+	until := time.Time{}
+	if s.PerDayRemaining >= 0 && s.PerDayRemaining <= threshold && s.PerDayReset.After(now) {
+		until = s.PerDayReset
+	}
+	if s.PerHourRemaining >= 0 && s.PerHourRemaining <= threshold && s.PerHourReset.After(now) && s.PerHourReset.After(until) {
+		until = s.PerHourReset
+	}
+	return until
+}
+
+// RateLimitError is returned when Tumblr rejects a request for exceeding the rate limit,
+// or when a RateLimitClient refuses to wait for the limit to reset.
+type RateLimitError struct {
+	// When requests are expected to be accepted again; zero if unknown
+	Reset time.Time
+	// The rejected response, empty if the request was never sent
+	Response Response
+}
+
+// Error implements the error interface.
+func (e *RateLimitError) Error() string {
+	if e.Reset.IsZero() {
+		return "Rate limit exceeded"
+	}
+	return fmt.Sprintf("Rate limit exceeded until %s", e.Reset.Format(time.RFC3339))
+}
+
+// RateLimitClient wraps a ClientInterface, tracking the rate limit headers of its responses and
+// delaying requests while the quota is (nearly) exhausted.
+type RateLimitClient struct {
+	client ClientInterface
+	// Requests are delayed until reset once this many or fewer remain in either window
+	Threshold int
+	// Longest delay to accept before failing with a RateLimitError instead; zero means always wait
+	MaxWait time.Duration
+	mutex   sync.Mutex
+	state   RateLimitState
+	now     func() time.Time
+	sleep   func(time.Duration)
+}
+
+// NewRateLimitClient wraps the provided client.
+func NewRateLimitClient(client ClientInterface) *RateLimitClient {
+	return &RateLimitClient{
+		client: client,
+		state:  unknownRateLimitState(),
+		now:    time.Now,
+		sleep:  time.Sleep,
+	}
+}
+
+// State returns the most recently observed rate limit state.
+func (c *RateLimitClient) State() RateLimitState {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.state
+}
+
+// Waits for the quota to reset if needed, then issues the request and records the resulting state
+func (c *RateLimitClient) do(method, endpoint string, params url.Values) (Response, error) {
+	c.mutex.Lock()
+	until := c.state.exhaustedUntil(c.Threshold, c.now())
+	updated := c.state.Updated
+	c.mutex.Unlock()
+	if !until.IsZero() {
+		wait := until.Sub(c.now())
+		if c.MaxWait > 0 && wait > c.MaxWait {
+			return Response{}, &RateLimitError{Reset: until}
+		}
+		c.sleep(wait)
+		c.mutex.Lock()
+		// the quota has been replenished, so unless another response arrived meanwhile what we knew no longer applies
+		if c.state.Updated.Equal(updated) {
+			c.state = unknownRateLimitState()
+		}
+		c.mutex.Unlock()
+	}
+	response, err := callClient(c.client, method, endpoint, params)
+	received := c.now()
+	state, found := ParseRateLimitHeaders(response.Headers, received)
+	if found {
+		c.mutex.Lock()
+		c.state = state
+		c.mutex.Unlock()
+	}
+	if response.StatusCode() == http.StatusTooManyRequests {
+		reset := state.exhaustedUntil(0, received)
+		if seconds, e := strconv.Atoi(response.Headers.Get("Retry-After")); e == nil {
+			reset = received.Add(time.Duration(seconds) * time.Second)
+		}
+		return response, &RateLimitError{Reset: reset, Response: response}
+	}
+	return response, err
+}
+
+// Get implements ClientInterface.
+func (c *RateLimitClient) Get(endpoint string) (Response, error) {
+	return c.do(http.MethodGet, endpoint, nil)
+}
+
+// GetWithParams implements ClientInterface.
+func (c *RateLimitClient) GetWithParams(endpoint string, params url.Values) (Response, error) {
+	return c.do(http.MethodGet, endpoint, params)
+}
+
+// Post implements ClientInterface.
+func (c *RateLimitClient) Post(endpoint string) (Response, error) {
+	return c.do(http.MethodPost, endpoint, nil)
+}
+
+// PostWithParams implements ClientInterface.
+func (c *RateLimitClient) PostWithParams(endpoint string, params url.Values) (Response, error) {
+	return c.do(http.MethodPost, endpoint, params)
+}
+
+// Put implements ClientInterface.
+func (c *RateLimitClient) Put(endpoint string) (Response, error) {
+	return c.do(http.MethodPut, endpoint, nil)
+}
+
+// PutWithParams implements ClientInterface.
+func (c *RateLimitClient) PutWithParams(endpoint string, params url.Values) (Response, error) {
+	return c.do(http.MethodPut, endpoint, params)
+}
+
+// Delete implements ClientInterface.
+func (c *RateLimitClient) Delete(endpoint string) (Response, error) {
+	return c.do(http.MethodDelete, endpoint, nil)
+}
+
+// DeleteWithParams implements ClientInterface.
+func (c *RateLimitClient) DeleteWithParams(endpoint string, params url.Values) (Response, error) {
+	return c.do(http.MethodDelete, endpoint, params)
+}
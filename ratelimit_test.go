@@ -0,0 +1,106 @@
+package tumblr
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func rateLimitHeaders(dayRemaining, dayReset, hourRemaining, hourReset string) http.Header {
+	headers := http.Header{}
+	headers.Set(RateLimitPerDayRemainingHeader, dayRemaining)
+	headers.Set(RateLimitPerDayResetHeader, dayReset)
+	headers.Set(RateLimitPerHourRemainingHeader, hourRemaining)
+	headers.Set(RateLimitPerHourResetHeader, hourReset)
+	return headers
+}
+
+func newTestRateLimitClient(client ClientInterface, now *time.Time, slept *time.Duration) *RateLimitClient {
+	c := NewRateLimitClient(client)
+	c.now = func() time.Time { return *now }
+	c.sleep = func(d time.Duration) {
+		*slept += d
+		*now = now.Add(d)
+	}
+	return c
+}
+
+func TestParseRateLimitHeaders(t *testing.T) {
+	received := time.Unix(1000, 0)
+	state, found := ParseRateLimitHeaders(rateLimitHeaders("4999", "3600", "999", "60"), received)
+	if !found {
+		t.Fatal("Rate limit headers should be found")
+	}
+	if state.PerDayRemaining != 4999 || state.PerHourRemaining != 999 {
+		t.Fatalf("Unexpected remaining counts %d/%d", state.PerDayRemaining, state.PerHourRemaining)
+	}
+	if !state.PerDayReset.Equal(time.Unix(4600, 0)) || !state.PerHourReset.Equal(time.Unix(1060, 0)) {
+		t.Fatalf("Unexpected resets %v/%v", state.PerDayReset, state.PerHourReset)
+	}
+	if !state.Updated.Equal(received) {
+		t.Fatal("State should record when it was received")
+	}
+	if state, found = ParseRateLimitHeaders(http.Header{}, received); found || state.PerDayRemaining != -1 {
+		t.Fatal("Missing headers should leave the state unknown")
+	}
+}
+
+func TestRateLimitClientTracksState(t *testing.T) {
+	now := time.Unix(1000, 0)
+	var slept time.Duration
+	inner := newTestClient("{}", nil)
+	inner.response.Headers = rateLimitHeaders("100", "3600", "10", "60")
+	client := newTestRateLimitClient(inner, &now, &slept)
+	if client.State().PerHourRemaining != -1 {
+		t.Fatal("State should be unknown before any request")
+	}
+	if _, err := client.Get("/user/info"); err != nil {
+		t.Fatal(err)
+	}
+	if client.State().PerHourRemaining != 10 || slept != 0 {
+		t.Fatal("Client should track the state without delaying")
+	}
+	client.Threshold = 10
+	if _, err := client.Get("/user/info"); err != nil {
+		t.Fatal(err)
+	}
+	if slept != time.Minute {
+		t.Fatalf("Client should have waited for the hourly reset, waited %v", slept)
+	}
+}
+
+func TestRateLimitClientMaxWait(t *testing.T) {
+	now := time.Unix(1000, 0)
+	var slept time.Duration
+	inner := newTestClient("{}", nil)
+	inner.response.Headers = rateLimitHeaders("0", "3600", "0", "60")
+	client := newTestRateLimitClient(inner, &now, &slept)
+	client.MaxWait = time.Minute
+	client.Get("/user/info")
+	_, err := client.Get("/user/info")
+	rateErr := &RateLimitError{}
+	if !errors.As(err, &rateErr) {
+		t.Fatalf("Expected a rate limit error, got %v", err)
+	}
+	if !rateErr.Reset.Equal(time.Unix(4600, 0)) || slept != 0 {
+		t.Fatal("Client should refuse to wait for the daily reset")
+	}
+}
+
+func TestRateLimitClientTooManyRequests(t *testing.T) {
+	now := time.Unix(1000, 0)
+	var slept time.Duration
+	inner := newTestClient(`{"meta": {"status": 429, "msg": "Limit Exceeded"}}`, nil)
+	inner.response.Headers = http.Header{}
+	inner.response.Headers.Set("Retry-After", "30")
+	client := newTestRateLimitClient(inner, &now, &slept)
+	_, err := client.PostWithParams("/blog/b/post", nil)
+	rateErr := &RateLimitError{}
+	if !errors.As(err, &rateErr) {
+		t.Fatalf("Expected a rate limit error, got %v", err)
+	}
+	if !rateErr.Reset.Equal(time.Unix(1030, 0)) || rateErr.Response.StatusCode() != 429 {
+		t.Fatal("Rate limit error should carry the reset time and the response")
+	}
+}
@@ -0,0 +1,319 @@
+package tumblr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces secrets in recorded interactions.
+const Redacted = "REDACTED"
+
+// Param keys holding credentials, which are never written to fixtures
+var redactedParams = []string{
+	"api_key", "oauth_consumer_key", "oauth_token", "oauth_token_secret", "oauth_signature", "oauth_nonce",
+	"oauth_verifier", "x_auth_password", "password",
+}
+
+// Keys of form-encoded bodies which are never written to fixtures, on top of redactedParams
+var redactedBodyKeys = []string{"email"}
+
+// Header keys holding credentials, which are never written to fixtures
+var redactedHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+
+// Interaction is a recorded request along with the response it received.
+type Interaction struct {
+	Method  string      `json:"method"`
+	Path    string      `json:"path"`
+	Params  url.Values  `json:"params,omitempty"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body"`
+	// Message of the error returned alongside the response, if any
+	Error string `json:"error,omitempty"`
+}
+
+// Replaces the values of credential keys
+func redactValues(values map[string][]string, keys []string) {
+	for key := range values {
+		for _, secret := range keys {
+			if strings.EqualFold(key, secret) {
+				values[key] = []string{Redacted}
+			}
+		}
+	}
+}
+
+// Reports whether values hold any of keys
+func hasAnyKey(values url.Values, keys []string) bool {
+	for key := range values {
+		for _, secret := range keys {
+			if strings.EqualFold(key, secret) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns a body with credentials redacted. Form-encoded bodies, such as the tokens the OAuth endpoints
+// answer with, have their credential values replaced; any other body of an OAuth endpoint is dropped whole.
+func redactBody(path, body string) string {
+	keys := append(append([]string{}, redactedParams...), redactedBodyKeys...)
+	if values, err := url.ParseQuery(body); err == nil && hasAnyKey(values, keys) {
+		redactValues(values, keys)
+		return values.Encode()
+	}
+	if strings.Contains(path, "/oauth/") && body != "" {
+		return Redacted
+	}
+	return body
+}
+
+// Returns the path and params of a request with credentials redacted. Params embedded in the endpoint's
+// query string are merged into the params.
+func redactRequest(endpoint string, params url.Values) (string, url.Values) {
+	redacted := url.Values{}
+	if i := strings.Index(endpoint, "?"); i >= 0 {
+		if query, err := url.ParseQuery(endpoint[i+1:]); err == nil {
+			endpoint = endpoint[:i]
+			for key, values := range query {
+				redacted[key] = append(redacted[key], values...)
+			}
+		}
+	}
+	for key, values := range params {
+		redacted[key] = append(redacted[key], values...)
+	}
+	redactValues(redacted, redactedParams)
+	if len(redacted) < 1 {
+		return endpoint, nil
+	}
+	return endpoint, redacted
+}
+
+// RecordingClient wraps a ClientInterface, recording every request and response so they can be saved as a
+// fixture for a ReplayClient. Credentials in params (which make up the form-encoded body of POST and PUT
+// requests), headers and form-encoded response bodies are redacted.
+type RecordingClient struct {
+	client       ClientInterface
+	mutex        sync.Mutex
+	interactions []Interaction
+}
+
+// NewRecordingClient wraps the provided client.
+func NewRecordingClient(client ClientInterface) *RecordingClient {
+	return &RecordingClient{client: client}
+}
+
+// Interactions returns the interactions recorded so far.
+func (c *RecordingClient) Interactions() []Interaction {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]Interaction{}, c.interactions...)
+}
+
+// WriteTo writes the recorded interactions as JSON.
+func (c *RecordingClient) WriteTo(w io.Writer) (int64, error) {
+	data, err := json.MarshalIndent(c.Interactions(), "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(append(data, '\n'))
+	return int64(n), err
+}
+
+// Save writes the recorded interactions to a fixture file.
+func (c *RecordingClient) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err = c.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Issues the request and records it
+func (c *RecordingClient) do(method, endpoint string, params url.Values) (Response, error) {
+	response, err := callClient(c.client, method, endpoint, params)
+	interaction := Interaction{Method: method}
+	interaction.Path, interaction.Params = redactRequest(endpoint, params)
+	interaction.Body = redactBody(interaction.Path, string(response.GetBody()))
+	if len(response.Headers) > 0 {
+		interaction.Headers = response.Headers.Clone()
+		redactValues(interaction.Headers, redactedHeaders)
+	}
+	if err != nil {
+		interaction.Error = err.Error()
+	}
+	c.mutex.Lock()
+	c.interactions = append(c.interactions, interaction)
+	c.mutex.Unlock()
+	return response, err
+}
+
+// Get implements ClientInterface.
+func (c *RecordingClient) Get(endpoint string) (Response, error) {
+	return c.do(http.MethodGet, endpoint, nil)
+}
+
+// GetWithParams implements ClientInterface.
+func (c *RecordingClient) GetWithParams(endpoint string, params url.Values) (Response, error) {
+	return c.do(http.MethodGet, endpoint, params)
+}
+
+// Post implements ClientInterface.
+func (c *RecordingClient) Post(endpoint string) (Response, error) {
+	return c.do(http.MethodPost, endpoint, nil)
+}
+
+// PostWithParams implements ClientInterface.
+func (c *RecordingClient) PostWithParams(endpoint string, params url.Values) (Response, error) {
+	return c.do(http.MethodPost, endpoint, params)
+}
+
+// Put implements ClientInterface.
+func (c *RecordingClient) Put(endpoint string) (Response, error) {
+	return c.do(http.MethodPut, endpoint, nil)
+}
+
+// PutWithParams implements ClientInterface.
+func (c *RecordingClient) PutWithParams(endpoint string, params url.Values) (Response, error) {
+	return c.do(http.MethodPut, endpoint, params)
+}
+
+// Delete implements ClientInterface.
+func (c *RecordingClient) Delete(endpoint string) (Response, error) {
+	return c.do(http.MethodDelete, endpoint, nil)
+}
+
+// DeleteWithParams implements ClientInterface.
+func (c *RecordingClient) DeleteWithParams(endpoint string, params url.Values) (Response, error) {
+	return c.do(http.MethodDelete, endpoint, params)
+}
+
+// UnmatchedRequestError is returned by a ReplayClient for a request matching no remaining interaction.
+type UnmatchedRequestError struct {
+	Method string
+	Path   string
+	Params url.Values
+}
+
+// Error implements the error interface.
+func (e *UnmatchedRequestError) Error() string {
+	return fmt.Sprintf("No recorded interaction for %s %s %s", e.Method, e.Path, e.Params.Encode())
+}
+
+// ReplayClient serves recorded interactions back. Each request is answered by the first unused interaction
+// with the same method, path and params; requests matching none fail with an UnmatchedRequestError.
+type ReplayClient struct {
+	mutex        sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewReplayClient creates a ReplayClient serving the given interactions.
+func NewReplayClient(interactions []Interaction) *ReplayClient {
+	return &ReplayClient{
+		interactions: interactions,
+		used:         make([]bool, len(interactions)),
+	}
+}
+
+// LoadReplayClient creates a ReplayClient serving the interactions of a fixture file written by a RecordingClient.
+func LoadReplayClient(path string) (*ReplayClient, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	interactions := []Interaction{}
+	if err = json.Unmarshal(data, &interactions); err != nil {
+		return nil, err
+	}
+	return NewReplayClient(interactions), nil
+}
+
+// Unused returns the interactions which have not been replayed yet.
+func (c *ReplayClient) Unused() []Interaction {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	unused := []Interaction{}
+	for i, interaction := range c.interactions {
+		if !c.used[i] {
+			unused = append(unused, interaction)
+		}
+	}
+	return unused
+}
+
+// Reports whether two sets of params hold the same values
+func sameParams(a, b url.Values) bool {
+	return a.Encode() == b.Encode()
+}
+
+// Answers the request with the first matching unused interaction
+func (c *ReplayClient) do(method, endpoint string, params url.Values) (Response, error) {
+	path, redacted := redactRequest(endpoint, params)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i, interaction := range c.interactions {
+		if c.used[i] || interaction.Method != method || interaction.Path != path || !sameParams(interaction.Params, redacted) {
+			continue
+		}
+		c.used[i] = true
+		response := NewResponse([]byte(interaction.Body), interaction.Headers)
+		if interaction.Error != "" {
+			return *response, errors.New(interaction.Error)
+		}
+		return *response, nil
+	}
+	return Response{}, &UnmatchedRequestError{Method: method, Path: path, Params: redacted}
+}
+
+// Get implements ClientInterface.
+func (c *ReplayClient) Get(endpoint string) (Response, error) {
+	return c.do(http.MethodGet, endpoint, nil)
+}
+
+// GetWithParams implements ClientInterface.
+func (c *ReplayClient) GetWithParams(endpoint string, params url.Values) (Response, error) {
+	return c.do(http.MethodGet, endpoint, params)
+}
+
+// Post implements ClientInterface.
+func (c *ReplayClient) Post(endpoint string) (Response, error) {
+	return c.do(http.MethodPost, endpoint, nil)
+}
+
+// PostWithParams implements ClientInterface.
+func (c *ReplayClient) PostWithParams(endpoint string, params url.Values) (Response, error) {
+	return c.do(http.MethodPost, endpoint, params)
+}
+
+// Put implements ClientInterface.
+func (c *ReplayClient) Put(endpoint string) (Response, error) {
+	return c.do(http.MethodPut, endpoint, nil)
+}
+
+// PutWithParams implements ClientInterface.
+func (c *ReplayClient) PutWithParams(endpoint string, params url.Values) (Response, error) {
+	return c.do(http.MethodPut, endpoint, params)
+}
+
+// Delete implements ClientInterface.
+func (c *ReplayClient) Delete(endpoint string) (Response, error) {
+	return c.do(http.MethodDelete, endpoint, nil)
+}
+
+// DeleteWithParams implements ClientInterface.
+func (c *ReplayClient) DeleteWithParams(endpoint string, params url.Values) (Response, error) {
+	return c.do(http.MethodDelete, endpoint, params)
+}
@@ -0,0 +1,106 @@
+package tumblr
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordingClientRedactsSecrets(t *testing.T) {
+	inner := newTestClient(`{"meta": {"status": 200}, "response": {}}`, nil)
+	inner.response.Headers = http.Header{"Set-Cookie": []string{"session=secret"}, "X-Ratelimit-Perday-Remaining": []string{"10"}}
+	client := NewRecordingClient(inner)
+	client.GetWithParams("/blog/b.tumblr.com/posts?api_key=secret", url.Values{"limit": []string{"5"}})
+	interactions := client.Interactions()
+	if len(interactions) != 1 {
+		t.Fatalf("Expected one interaction, got %d", len(interactions))
+	}
+	recorded := interactions[0]
+	if recorded.Method != http.MethodGet || recorded.Path != "/blog/b.tumblr.com/posts" {
+		t.Fatalf("Unexpected request %s %s", recorded.Method, recorded.Path)
+	}
+	if recorded.Params.Get("api_key") != Redacted || recorded.Params.Get("limit") != "5" {
+		t.Fatalf("Params were not redacted correctly: %v", recorded.Params)
+	}
+	if recorded.Headers.Get("Set-Cookie") != Redacted || recorded.Headers.Get("X-Ratelimit-Perday-Remaining") != "10" {
+		t.Fatalf("Headers were not redacted correctly: %v", recorded.Headers)
+	}
+	if inner.response.Headers.Get("Set-Cookie") != "session=secret" {
+		t.Fatal("Redaction should not alter the live response")
+	}
+}
+
+func TestRecordingClientRedactsOAuthBodies(t *testing.T) {
+	inner := newTestClient("oauth_token=token&oauth_token_secret=secret&email=u%40example.com", nil)
+	client := NewRecordingClient(inner)
+	client.PostWithParams("/oauth/access_token", url.Values{
+		"x_auth_mode":     []string{"client_auth"},
+		"x_auth_password": []string{"hunter2"},
+	})
+	inner.response = *NewResponse([]byte(`{"token": "secret"}`), nil)
+	client.Post("/oauth/request_token")
+	interactions := client.Interactions()
+	if len(interactions) != 2 {
+		t.Fatalf("Expected two interactions, got %d", len(interactions))
+	}
+	recorded := interactions[0]
+	if recorded.Params.Get("x_auth_password") != Redacted || recorded.Params.Get("x_auth_mode") != "client_auth" {
+		t.Fatalf("Params were not redacted correctly: %v", recorded.Params)
+	}
+	body, err := url.ParseQuery(recorded.Body)
+	if err != nil || body.Get("oauth_token") != Redacted || body.Get("oauth_token_secret") != Redacted || body.Get("email") != Redacted {
+		t.Fatalf("Form body was not redacted correctly: %q", recorded.Body)
+	}
+	if interactions[1].Body != Redacted {
+		t.Fatalf("Other OAuth bodies should be dropped, got %q", interactions[1].Body)
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	inner := newTestClient(`{"meta": {"status": 200}, "response": {"user": {"name": "u"}}}`, nil)
+	recorder := NewRecordingClient(inner)
+	if _, err := GetUserInfo(recorder); err != nil {
+		t.Fatal(err)
+	}
+	inner.err = errors.New("Network error")
+	Follow(recorder, "b")
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	if err := recorder.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	replay, err := LoadReplayClient(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := GetUserInfo(replay)
+	if err != nil || user.Name != "u" {
+		t.Fatalf("Unexpected replayed result %v, %v", user, err)
+	}
+	if err = Follow(replay, "b"); err == nil || err.Error() != "Network error" {
+		t.Fatalf("Recorded error should be replayed, got %v", err)
+	}
+	if len(replay.Unused()) != 0 {
+		t.Fatal("All interactions should have been used")
+	}
+	_, err = GetUserInfo(replay)
+	unmatched := &UnmatchedRequestError{}
+	if !errors.As(err, &unmatched) || unmatched.Path != "/user/info" {
+		t.Fatalf("Expected an unmatched request error, got %v", err)
+	}
+}
+
+func TestReplayClientMatchesParams(t *testing.T) {
+	replay := NewReplayClient([]Interaction{
+		{Method: http.MethodGet, Path: "/tagged", Params: url.Values{"tag": []string{"a"}}, Body: "a"},
+		{Method: http.MethodGet, Path: "/tagged", Params: url.Values{"tag": []string{"b"}}, Body: "b"},
+	})
+	response, err := replay.GetWithParams("/tagged", url.Values{"tag": []string{"b"}})
+	if err != nil || string(response.GetBody()) != "b" {
+		t.Fatalf("Expected the interaction matching the params, got %q, %v", response.GetBody(), err)
+	}
+	if _, err = replay.GetWithParams("/tagged", url.Values{"tag": []string{"c"}}); err == nil {
+		t.Fatal("Requests with different params should not match")
+	}
+}
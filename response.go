@@ -39,3 +39,22 @@ func (r *Response) PopulateFromBody() error {
 	}
 	return nil
 }
+
+// Get the HTTP status code reported in the response's meta block, or 0 if there is none
+func (r *Response) StatusCode() int {
+	meta := r.Meta
+	if meta == nil {
+		// error responses carry an empty array as their result, so only the meta block is decoded
+		envelope := struct {
+			Meta map[string]interface{} `json:"meta"`
+		}{}
+		if err := json.Unmarshal(r.body, &envelope); err != nil {
+			return 0
+		}
+		meta = envelope.Meta
+	}
+	if status, ok := meta["status"].(float64); ok {
+		return int(status)
+	}
+	return 0
+}
@@ -48,3 +48,20 @@ func TestResponse_PopulateFromBody(t *testing.T) {
 		t.Fatal("Populate from body should return unmarshal error on invalid JSON")
 	}
 }
+
+func TestResponse_StatusCode(t *testing.T) {
+	r := NewResponse([]byte(`{"meta": {"status": 404, "msg": "Not Found"}}`), nil)
+	if status := r.StatusCode(); status != 404 {
+		t.Fatalf("Expected status 404, got %d", status)
+	}
+	if status := NewResponse([]byte("{"), nil).StatusCode(); status != 0 {
+		t.Fatalf("Expected no status for invalid body, got %d", status)
+	}
+}
+
+func TestResponse_StatusCodeOfErrorResponse(t *testing.T) {
+	r := NewResponse([]byte(`{"meta": {"status": 401, "msg": "Unauthorized"}, "response": []}`), nil)
+	if status := r.StatusCode(); status != 401 {
+		t.Fatalf("Expected status 401, got %d", status)
+	}
+}
@@ -0,0 +1,192 @@
+package tumblr
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// RetryAttempt describes a failed attempt which is about to be retried.
+type RetryAttempt struct {
+	Method   string
+	Endpoint string
+	// The number of the attempt which failed, starting at 1
+	Attempt int
+	// How long the client will wait before the next attempt
+	Delay time.Duration
+	// The status code of the failed attempt, 0 if there was no response
+	Status int
+	Err    error
+}
+
+// RetryPolicy configures which requests a RetryClient retries, and how often.
+type RetryPolicy struct {
+	// Total number of attempts per request, including the first
+	MaxAttempts int
+	// Delay before the first retry, doubling for each retry after that
+	BaseDelay time.Duration
+	// Upper bound of any delay; a Retry-After beyond it ends the retries
+	MaxDelay time.Duration
+	// Fraction (0 to 1) of each delay that is randomized
+	Jitter float64
+	// Also retry POST requests. Those are not idempotent (retrying CreatePost or ReblogPost may publish twice),
+	// so this should only be set when duplicates are acceptable.
+	RetryPosts bool
+	// Decides whether a result is a transient failure; nil uses IsTransientFailure
+	Retryable func(response Response, err error) bool
+	// Called before each retry, to observe attempts
+	OnRetry func(attempt RetryAttempt)
+}
+
+// DefaultRetryPolicy returns the policy used by NewRetryClient when none is given.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    30 * time.Second,
+		Jitter:      0.5,
+	}
+}
+
+// IsTransientFailure reports whether a request failed in a way that may succeed when retried:
+// rate limiting (429), server errors (5xx), or an error without any API response, such as a network failure.
+func IsTransientFailure(response Response, err error) bool {
+	status := response.StatusCode()
+	if status == 0 {
+		return err != nil
+	}
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// Returns how long the response asks clients to wait before retrying, or 0 if it does not say
+func retryAfter(response Response, err error, now time.Time) time.Duration {
+	if value := response.Headers.Get("Retry-After"); value != "" {
+		if seconds, e := strconv.Atoi(value); e == nil {
+			return time.Duration(seconds) * time.Second
+		}
+		if date, e := http.ParseTime(value); e == nil && date.After(now) {
+			return date.Sub(now)
+		}
+	}
+	rateErr := &RateLimitError{}
+	if errors.As(err, &rateErr) && rateErr.Reset.After(now) {
+		return rateErr.Reset.Sub(now)
+	}
+	return 0
+}
+
+// RetryClient wraps a ClientInterface, retrying transient failures with jittered exponential backoff.
+type RetryClient struct {
+	client ClientInterface
+	Policy RetryPolicy
+	now    func() time.Time
+	sleep  func(time.Duration)
+	random func() float64
+}
+
+// NewRetryClient wraps the provided client; a nil policy uses DefaultRetryPolicy.
+func NewRetryClient(client ClientInterface, policy *RetryPolicy) *RetryClient {
+	c := &RetryClient{
+		client: client,
+		Policy: DefaultRetryPolicy(),
+		now:    time.Now,
+		sleep:  time.Sleep,
+		random: rand.Float64,
+	}
+	if policy != nil {
+		c.Policy = *policy
+	}
+	return c
+}
+
+// Computes the backoff before the given retry (starting at 1), without Retry-After
+func (c *RetryClient) backoff(retry int) time.Duration {
+	delay := c.Policy.BaseDelay
+	for i := 1; i < retry && (c.Policy.MaxDelay <= 0 || delay < c.Policy.MaxDelay); i++ {
+		delay *= 2
+	}
+	if c.Policy.MaxDelay > 0 && delay > c.Policy.MaxDelay {
+		delay = c.Policy.MaxDelay
+	}
+	if c.Policy.Jitter > 0 {
+		delay -= time.Duration(c.random() * c.Policy.Jitter * float64(delay))
+	}
+	return delay
+}
+
+// Issues the request, retrying it as long as the policy allows
+func (c *RetryClient) do(method, endpoint string, params url.Values) (Response, error) {
+	retryable := c.Policy.Retryable
+	if retryable == nil {
+		retryable = IsTransientFailure
+	}
+	for attempt := 1; ; attempt++ {
+		response, err := callClient(c.client, method, endpoint, params)
+		if attempt >= c.Policy.MaxAttempts || (method == http.MethodPost && !c.Policy.RetryPosts) || !retryable(response, err) {
+			return response, err
+		}
+		delay := c.backoff(attempt)
+		if wait := retryAfter(response, err, c.now()); wait > 0 {
+			if c.Policy.MaxDelay > 0 && wait > c.Policy.MaxDelay {
+				return response, err
+			}
+			if wait > delay {
+				delay = wait
+			}
+		}
+		if c.Policy.OnRetry != nil {
+			c.Policy.OnRetry(RetryAttempt{
+				Method:   method,
+				Endpoint: endpoint,
+				Attempt:  attempt,
+				Delay:    delay,
+				Status:   response.StatusCode(),
+				Err:      err,
+			})
+		}
+		c.sleep(delay)
+	}
+}
+
+// Get implements ClientInterface.
+func (c *RetryClient) Get(endpoint string) (Response, error) {
+	return c.do(http.MethodGet, endpoint, nil)
+}
+
+// GetWithParams implements ClientInterface.
+func (c *RetryClient) GetWithParams(endpoint string, params url.Values) (Response, error) {
+	return c.do(http.MethodGet, endpoint, params)
+}
+
+// Post implements ClientInterface.
+func (c *RetryClient) Post(endpoint string) (Response, error) {
+	return c.do(http.MethodPost, endpoint, nil)
+}
+
+// PostWithParams implements ClientInterface.
+func (c *RetryClient) PostWithParams(endpoint string, params url.Values) (Response, error) {
+	return c.do(http.MethodPost, endpoint, params)
+}
+
+// Put implements ClientInterface.
+func (c *RetryClient) Put(endpoint string) (Response, error) {
+	return c.do(http.MethodPut, endpoint, nil)
+}
+
+// PutWithParams implements ClientInterface.
+func (c *RetryClient) PutWithParams(endpoint string, params url.Values) (Response, error) {
+	return c.do(http.MethodPut, endpoint, params)
+}
+
+// Delete implements ClientInterface.
+func (c *RetryClient) Delete(endpoint string) (Response, error) {
+	return c.do(http.MethodDelete, endpoint, nil)
+}
+
+// DeleteWithParams implements ClientInterface.
+func (c *RetryClient) DeleteWithParams(endpoint string, params url.Values) (Response, error) {
+	return c.do(http.MethodDelete, endpoint, params)
+}
@@ -0,0 +1,145 @@
+package tumblr
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// Client returning a fixed sequence of results, repeating the last one
+type sequenceClient struct {
+	testClient
+	responses []Response
+	errs      []error
+	calls     int
+}
+
+func newSequenceClient(bodies []string, errs []error) *sequenceClient {
+	c := &sequenceClient{errs: errs}
+	for _, body := range bodies {
+		c.responses = append(c.responses, Response{body: []byte(body)})
+	}
+	return c
+}
+
+func (c *sequenceClient) next() (Response, error) {
+	i := c.calls
+	c.calls++
+	if i >= len(c.responses) {
+		i = len(c.responses) - 1
+	}
+	return c.responses[i], c.errs[i]
+}
+
+func (c *sequenceClient) GetWithParams(string, url.Values) (Response, error) {
+	return c.next()
+}
+
+func (c *sequenceClient) PostWithParams(string, url.Values) (Response, error) {
+	return c.next()
+}
+
+func newTestRetryClient(client ClientInterface, policy *RetryPolicy, slept *[]time.Duration) *RetryClient {
+	c := NewRetryClient(client, policy)
+	c.now = func() time.Time { return time.Unix(1000, 0) }
+	c.sleep = func(d time.Duration) { *slept = append(*slept, d) }
+	c.random = func() float64 { return 0 }
+	return c
+}
+
+func TestIsTransientFailure(t *testing.T) {
+	netErr := errors.New("connection reset")
+	cases := []struct {
+		body      string
+		err       error
+		transient bool
+	}{
+		{`{"meta": {"status": 200}}`, nil, false},
+		{`{"meta": {"status": 503}}`, netErr, true},
+		{`{"meta": {"status": 429}}`, nil, true},
+		{`{"meta": {"status": 404}}`, netErr, false},
+		{"", netErr, true},
+		{"", nil, false},
+	}
+	for _, c := range cases {
+		if IsTransientFailure(Response{body: []byte(c.body)}, c.err) != c.transient {
+			t.Errorf("Expected transient=%v for %q, %v", c.transient, c.body, c.err)
+		}
+	}
+}
+
+func TestRetryClientRetriesGets(t *testing.T) {
+	failure := errors.New("Server error")
+	inner := newSequenceClient(
+		[]string{`{"meta": {"status": 502}}`, `{"meta": {"status": 500}}`, `{"meta": {"status": 200}}`},
+		[]error{failure, failure, nil},
+	)
+	var slept []time.Duration
+	var attempts []RetryAttempt
+	policy := DefaultRetryPolicy()
+	policy.OnRetry = func(a RetryAttempt) { attempts = append(attempts, a) }
+	client := newTestRetryClient(inner, &policy, &slept)
+	response, err := client.GetWithParams("/blog/b/posts", url.Values{})
+	if err != nil || response.StatusCode() != 200 {
+		t.Fatalf("Expected eventual success, got %v", err)
+	}
+	if inner.calls != 3 || len(slept) != 2 || slept[0] != 500*time.Millisecond || slept[1] != time.Second {
+		t.Fatalf("Expected exponential backoff over 3 calls, got %d calls sleeping %v", inner.calls, slept)
+	}
+	if len(attempts) != 2 || attempts[0].Attempt != 1 || attempts[0].Status != 502 || attempts[1].Err != failure {
+		t.Fatalf("Attempts were not reported correctly: %+v", attempts)
+	}
+}
+
+func TestRetryClientGivesUp(t *testing.T) {
+	failure := errors.New("Server error")
+	inner := newSequenceClient([]string{`{"meta": {"status": 500}}`}, []error{failure})
+	var slept []time.Duration
+	client := newTestRetryClient(inner, nil, &slept)
+	if _, err := client.GetWithParams("/user/dashboard", url.Values{}); err != failure {
+		t.Fatalf("Expected the last error, got %v", err)
+	}
+	if inner.calls != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", inner.calls)
+	}
+}
+
+func TestRetryClientPostsRequireOptIn(t *testing.T) {
+	failure := errors.New("Server error")
+	inner := newSequenceClient([]string{`{"meta": {"status": 503}}`}, []error{failure})
+	var slept []time.Duration
+	client := newTestRetryClient(inner, nil, &slept)
+	client.PostWithParams("/blog/b/post/reblog", url.Values{})
+	if inner.calls != 1 {
+		t.Fatalf("POST should not be retried by default, got %d attempts", inner.calls)
+	}
+	client.Policy.RetryPosts = true
+	client.PostWithParams("/blog/b/post/reblog", url.Values{})
+	if inner.calls != 4 {
+		t.Fatalf("POST should be retried when opted in, got %d attempts", inner.calls-1)
+	}
+}
+
+func TestRetryClientHonorsRetryAfter(t *testing.T) {
+	inner := newSequenceClient(
+		[]string{`{"meta": {"status": 429}}`, `{"meta": {"status": 200}}`},
+		[]error{nil, nil},
+	)
+	inner.responses[0].Headers = http.Header{"Retry-After": []string{"7"}}
+	var slept []time.Duration
+	client := newTestRetryClient(inner, nil, &slept)
+	if _, err := client.GetWithParams("/tagged", url.Values{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(slept) != 1 || slept[0] != 7*time.Second {
+		t.Fatalf("Expected to wait as long as Retry-After, waited %v", slept)
+	}
+	inner.calls = 0
+	inner.responses[0].Headers.Set("Retry-After", "3600")
+	slept = nil
+	if response, _ := client.GetWithParams("/tagged", url.Values{}); response.StatusCode() != 429 || len(slept) != 0 {
+		t.Fatal("A Retry-After beyond the maximum delay should end the retries")
+	}
+}
@@ -10,9 +10,11 @@ type SearchResults struct {
 	client ClientInterface
 	Posts  []PostInterface `json:"response"`
 	params url.Values
+	errs   PostDecodeErrors
 }
 
 // gets page of posts
+// Posts which fail to decode are kept as RawPosts and reported by DecodeErrors.
 func TaggedSearch(client ClientInterface, tag string, params url.Values) (*SearchResults, error) {
 	params.Set("tag", tag)
 	response, err := client.GetWithParams("/tagged", params)
@@ -20,23 +22,24 @@ func TaggedSearch(client ClientInterface, tag string, params url.Values) (*Searc
 		return nil, err
 	}
 	result := struct {
-		Response []MiniPost `json:"response"`
+		Response decodedPostList `json:"response"`
 	}{}
 	if err = json.Unmarshal(response.body, &result); err != nil {
 		return nil, err
 	}
-	minis := result.Response
 	full := SearchResults{
-		Posts:  makePostsFromMinis(minis, client),
 		client: client,
 		params: params,
 	}
-	if err = json.Unmarshal(response.body, &full); err != nil {
-		return nil, err
-	}
+	full.Posts, full.errs = result.Response.result(client)
 	return &full, nil
 }
 
+// DecodeErrors returns the results which failed to decode, and are kept in Posts as RawPosts.
+func (s *SearchResults) DecodeErrors() PostDecodeErrors {
+	return s.errs
+}
+
 // returns next page of results
 func (s *SearchResults) Next() (*SearchResults, error) {
 	// get last timestamp
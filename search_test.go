@@ -41,6 +41,17 @@ func TestTaggedSearchSuccess(t *testing.T) {
 	}
 }
 
+func TestTaggedSearchKeepsPostsFailingToDecode(t *testing.T) {
+	client := newTestClient(`{"response": [{"id": 1, "type": "quote", "text": "q"}, {"id": 2, "type": "quote", "text": 5}]}`, nil)
+	results, err := TaggedSearch(client, "tag", url.Values{})
+	if err != nil || len(results.Posts) != 2 {
+		t.Fatal("A post failing to decode should not fail the page", err)
+	}
+	if errs := results.DecodeErrors(); len(errs) != 1 || errs[0].Id != 2 {
+		t.Fatal("The post failing to decode should be reported", errs)
+	}
+}
+
 func TestSearchResults_Next(t *testing.T) {
 	client := newTestClient("{}", nil)
 	tag := "some-tag"
@@ -0,0 +1,119 @@
+package tumblr
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// TrailPost identifies the post a ReblogTrailItem came from.
+type TrailPost struct {
+	Id        uint64 `json:"id"`
+	Timestamp uint64 `json:"timestamp,omitempty"`
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. The API sends the id either as a number or as a
+// numeric string, and omits it for broken trail items.
+func (p *TrailPost) UnmarshalJSON(b []byte) error {
+	raw := struct {
+		Id        json.Number `json:"id"`
+		Timestamp json.Number `json:"timestamp"`
+	}{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	var err error
+	*p = TrailPost{}
+	if raw.Id != "" {
+		if p.Id, err = strconv.ParseUint(string(raw.Id), 10, 64); err != nil {
+			return err
+		}
+	}
+	if raw.Timestamp != "" {
+		if p.Timestamp, err = strconv.ParseUint(string(raw.Timestamp), 10, 64); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface to ingest both legacy (HTML) and NPF (blocks) content.
+func (t *ReblogTrailItem) UnmarshalJSON(b []byte) error {
+	type plain ReblogTrailItem
+	raw := struct {
+		*plain
+		Content json.RawMessage `json:"content"`
+	}{plain: (*plain)(t)}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if len(raw.Content) > 0 && raw.Content[0] == '[' {
+		return json.Unmarshal(raw.Content, &t.ContentBlocks)
+	}
+	if len(raw.Content) > 0 && raw.Content[0] == '"' {
+		return json.Unmarshal(raw.Content, &t.Content)
+	}
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface, writing NPF content blocks in place of HTML when present.
+func (t ReblogTrailItem) MarshalJSON() ([]byte, error) {
+	type plain ReblogTrailItem
+	if len(t.ContentBlocks) < 1 {
+		return json.Marshal(plain(t))
+	}
+	return json.Marshal(struct {
+		plain
+		Content []ContentBlock `json:"content"`
+	}{plain: plain(t), Content: t.ContentBlocks})
+}
+
+// IsBroken reports whether the blog of this trail item no longer exists.
+func (t *ReblogTrailItem) IsBroken() bool {
+	return t.BrokenBlogName != ""
+}
+
+// BlogName returns the name of the blog which added this trail item, even if that blog is broken.
+func (t *ReblogTrailItem) BlogName() string {
+	if t.IsBroken() {
+		return t.BrokenBlogName
+	}
+	return t.Blog.Name
+}
+
+// HTML returns the trail item's content as HTML, rendering NPF content if necessary.
+func (t *ReblogTrailItem) HTML() string {
+	if len(t.ContentBlocks) > 0 {
+		return RenderNPFHTML(t.ContentBlocks, t.Layout)
+	}
+	return t.Content
+}
+
+// RootTrailItem returns the trail item of the original post, or nil if the post is not a reblog.
+func (p *Post) RootTrailItem() *ReblogTrailItem {
+	for i := range p.Trail {
+		if p.Trail[i].IsRootItem {
+			return &p.Trail[i]
+		}
+	}
+	// NPF trails do not flag the root item, but list it first
+	if len(p.Trail) > 0 && !p.Trail[0].IsCurrentItem {
+		return &p.Trail[0]
+	}
+	return nil
+}
+
+// Commenters returns the names of the blogs which added content along the reblog chain, starting with the
+// root post's blog and ending with this post's blog if it added content of its own.
+func (p *Post) Commenters() []string {
+	names := []string{}
+	current := false
+	for i := range p.Trail {
+		names = append(names, p.Trail[i].BlogName())
+		current = current || p.Trail[i].IsCurrentItem
+	}
+	// NPF posts keep their own content out of the trail
+	if !current && len(p.Content) > 0 {
+		names = append(names, p.BlogName)
+	}
+	return names
+}
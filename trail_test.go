@@ -0,0 +1,94 @@
+package tumblr
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTrailPostIdFormats(t *testing.T) {
+	items := []ReblogTrailItem{}
+	body := `[
+		{"post": {"id": 1986}},
+		{"post": {"id": "1234567890123", "timestamp": 1500000000}},
+		{"post": {}}
+	]`
+	if err := json.Unmarshal([]byte(body), &items); err != nil {
+		t.Fatal("Unexpected error unmarshalling trail", err)
+	}
+	if items[0].Post.Id != 1986 || items[1].Post.Id != 1234567890123 || items[1].Post.Timestamp != 1500000000 || items[2].Post.Id != 0 {
+		t.Fatal("Trail post ids should be normalized to uint64", items)
+	}
+	if err := json.Unmarshal([]byte(`[{"post": {"id": "abc"}}]`), &items); err == nil {
+		t.Fatal("Non-numeric post ids should generate an error")
+	}
+}
+
+func TestTrailItemLegacyAndNPFContent(t *testing.T) {
+	post := Post{}
+	body := `{
+		"blog_name": "current",
+		"content": [{"type": "text", "text": "my comment"}],
+		"trail": [
+			{"post": {"id": "1"}, "blog": {"name": "root", "uuid": "t:abc"}, "content": [{"type": "text", "text": "<original>"}], "layout": []},
+			{"post": {}, "broken_blog_name": "gone", "content": [{"type": "text", "text": "lost"}]},
+			{"post": {"id": 3}, "blog": {"name": "legacy"}, "content": "<p>html</p>", "content_raw": "<p>html</p>"}
+		]
+	}`
+	if err := json.Unmarshal([]byte(body), &post); err != nil {
+		t.Fatal("Unexpected error unmarshalling post", err)
+	}
+	root := post.Trail[0]
+	if root.Blog.Uuid != "t:abc" || len(root.ContentBlocks) != 1 || root.Content != "" {
+		t.Fatal("NPF trail content should be decoded into blocks", root)
+	}
+	if root.HTML() != "<p>&lt;original&gt;</p>" {
+		t.Fatalf("NPF trail content should render as HTML, got %s", root.HTML())
+	}
+	if !post.Trail[1].IsBroken() || post.Trail[1].BlogName() != "gone" {
+		t.Fatal("Broken trail items should be detected")
+	}
+	if legacy := post.Trail[2]; legacy.Content != "<p>html</p>" || legacy.HTML() != "<p>html</p>" || legacy.IsBroken() {
+		t.Fatal("Legacy trail content should be kept as HTML")
+	}
+	if post.RootTrailItem() != &post.Trail[0] {
+		t.Fatal("First NPF trail item should be the root")
+	}
+	if names := post.Commenters(); !reflect.DeepEqual(names, []string{"root", "gone", "legacy", "current"}) {
+		t.Fatal("Unexpected commenters", names)
+	}
+}
+
+func TestTrailItemMarshalRoundTrip(t *testing.T) {
+	item := ReblogTrailItem{ContentBlocks: []ContentBlock{{Type: "text", Text: "hi"}}, Post: TrailPost{Id: 5}}
+	out, err := json.Marshal(item)
+	if err != nil {
+		t.Fatal("Unexpected marshal error", err)
+	}
+	if !strings.Contains(string(out), `"content":[{"type":"text","text":"hi"}]`) {
+		t.Fatalf("NPF blocks should be marshalled as content, got %s", out)
+	}
+	decoded := ReblogTrailItem{}
+	if err = json.Unmarshal(out, &decoded); err != nil || decoded.Post.Id != 5 || len(decoded.ContentBlocks) != 1 {
+		t.Fatal("Trail item should survive a round trip", err)
+	}
+	legacy, _ := json.Marshal(ReblogTrailItem{Content: "x"})
+	if !strings.Contains(string(legacy), `"content":"x"`) {
+		t.Fatalf("Legacy content should be marshalled as a string, got %s", legacy)
+	}
+}
+
+func TestRootTrailItemLegacy(t *testing.T) {
+	post := Post{Trail: []ReblogTrailItem{{Blog: Blog{BlogRef: BlogRef{Name: "a"}}}, {IsRootItem: true}, {IsCurrentItem: true}}}
+	if post.RootTrailItem() != &post.Trail[1] {
+		t.Fatal("Flagged root item should be returned")
+	}
+	post = Post{Trail: []ReblogTrailItem{{IsCurrentItem: true}}}
+	if post.RootTrailItem() != nil {
+		t.Fatal("Non-reblogs should have no root item")
+	}
+	if post.Commenters(); len(post.Commenters()) != 1 {
+		t.Fatal("Current legacy item should count as a commenter")
+	}
+}
@@ -47,6 +47,7 @@ type Blog struct {
 	Subscribed           bool   `json:"subscribed"`
 	TotalPosts           int64  `json:"total_posts"`
 	Updated              int64  `json:"updated"`
+	Uuid                 string `json:"uuid,omitempty"`
 }
 
 // Convenience method converting a Blog into a JSON representation
@@ -137,6 +138,11 @@ func (b *BlogRef) CreatePost(params url.Values) (*PostRef, error) {
 	return CreatePost(b.client, b.Name, params)
 }
 
+// Creates an NPF post on the blog represented by BlogRef
+func (b *BlogRef) CreateNPFPost(content []ContentBlock, layout []LayoutBlock, params url.Values) (*PostRef, error) {
+	return CreateNPFPost(b.client, b.Name, content, layout, params)
+}
+
 // Reblogs a post to the blog represented by BlogRef
 func (b *BlogRef) ReblogPost(p *PostRef, params url.Values) (*PostRef, error) {
 	return p.ReblogOnBlog(b.Name, params)
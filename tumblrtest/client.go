@@ -0,0 +1,304 @@
+// Package tumblrtest provides a programmable fake tumblr.ClientInterface for testing code built on this library.
+//
+// Routes are registered per method and path, optionally narrowed by params, and answer with a sequence of
+// canned responses or errors:
+//
+//	client := tumblrtest.NewClient()
+//	client.On(http.MethodGet, "/blog/b.tumblr.com/followers").
+//		WithParam("offset", "0").
+//		RespondResult(map[string]interface{}{"total_users": 2, "users": []interface{}{...}})
+package tumblrtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+
+	"github.com/tumblr/tumblr.go"
+)
+
+// Call is a request received by a Client.
+type Call struct {
+	Method string
+	Path   string
+	Params url.Values
+}
+
+// NoRouteError is returned by a Client for a request matching none of its routes.
+type NoRouteError struct {
+	Call
+}
+
+// Error implements the error interface.
+func (e *NoRouteError) Error() string {
+	return fmt.Sprintf("No route for %s %s %s", e.Method, e.Path, e.Params.Encode())
+}
+
+// A canned answer of a Route
+type result struct {
+	body    []byte
+	headers http.Header
+	err     error
+}
+
+// Route answers the requests matching its method, path and params with a sequence of results.
+// Each call consumes the next result; the last one is repeated once the sequence is exhausted.
+// Results whose meta status is 400 or more are returned along with an error, as by a real client.
+type Route struct {
+	// The mutex of the route's Client, so routes can be programmed while it answers calls
+	mutex   *sync.Mutex
+	method  string
+	path    string
+	params  url.Values
+	results []result
+	calls   int
+}
+
+// WithParam narrows the route to requests having the param set to value. Other params are ignored.
+func (r *Route) WithParam(key, value string) *Route {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.params == nil {
+		r.params = url.Values{}
+	}
+	r.params.Add(key, value)
+	return r
+}
+
+// WithParams narrows the route to requests having all of the given params.
+func (r *Route) WithParams(params url.Values) *Route {
+	for key, values := range params {
+		for _, value := range values {
+			r.WithParam(key, value)
+		}
+	}
+	return r
+}
+
+// Respond appends a raw response body to the route's sequence.
+func (r *Route) Respond(body string) *Route {
+	return r.RespondWithHeaders(body, http.Header{})
+}
+
+// RespondWithHeaders appends a raw response body and its headers to the route's sequence.
+func (r *Route) RespondWithHeaders(body string, headers http.Header) *Route {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.results = append(r.results, result{body: []byte(body), headers: headers})
+	return r
+}
+
+// RespondResult appends a successful API response to the route's sequence, wrapping result in the
+// usual meta/response envelope. It panics if result cannot be encoded.
+func (r *Route) RespondResult(result interface{}) *Route {
+	return r.RespondStatus(http.StatusOK, result)
+}
+
+// RespondStatus appends an API response with the given status to the route's sequence, wrapping result
+// in the usual meta/response envelope. It panics if result cannot be encoded.
+func (r *Route) RespondStatus(status int, result interface{}) *Route {
+	body, err := json.Marshal(map[string]interface{}{
+		"meta": map[string]interface{}{
+			"status": status,
+			"msg":    http.StatusText(status),
+		},
+		"response": result,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return r.Respond(string(body))
+}
+
+// Fail appends an error to the route's sequence, as returned by a failing client.
+func (r *Route) Fail(err error) *Route {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.results = append(r.results, result{err: err})
+	return r
+}
+
+// Reports whether a request matches the route
+func (r *Route) matches(call Call) bool {
+	if r.method != call.Method || r.path != call.Path {
+		return false
+	}
+	for key, values := range r.params {
+		found := call.Params[key]
+		for _, value := range values {
+			if !slices.Contains(found, value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Client is a fake tumblr.ClientInterface answering requests from its routes and recording every call.
+// It is safe for concurrent use, including programming its routes while it answers calls.
+type Client struct {
+	mutex  sync.Mutex
+	routes []*Route
+	calls  []Call
+}
+
+// NewClient creates a Client without routes.
+func NewClient() *Client {
+	return &Client{}
+}
+
+// On registers a route for requests to path using method. When several routes match a request,
+// the first one registered answers it, so narrower routes should be registered first.
+func (c *Client) On(method, path string) *Route {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	route := &Route{mutex: &c.mutex, method: method, path: path}
+	c.routes = append(c.routes, route)
+	return route
+}
+
+// Calls returns every call received so far, in order.
+func (c *Client) Calls() []Call {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]Call{}, c.calls...)
+}
+
+// CallCount returns the number of calls received for path using method.
+func (c *Client) CallCount(method, path string) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	count := 0
+	for _, call := range c.calls {
+		if call.Method == method && call.Path == path {
+			count++
+		}
+	}
+	return count
+}
+
+// RouteCalls returns the number of calls the route has answered.
+func (c *Client) RouteCalls(r *Route) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return r.calls
+}
+
+// Unused returns the routes which have not answered any call.
+func (c *Client) Unused() []*Route {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	unused := []*Route{}
+	for _, route := range c.routes {
+		if route.calls < 1 {
+			unused = append(unused, route)
+		}
+	}
+	return unused
+}
+
+// TestingT is the part of testing.TB used by the assertion helpers.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// AssertCalled fails the test unless path was requested using method exactly times times.
+func (c *Client) AssertCalled(t TestingT, method, path string, times int) {
+	t.Helper()
+	if count := c.CallCount(method, path); count != times {
+		t.Errorf("Expected %d calls to %s %s, got %d", times, method, path, count)
+	}
+}
+
+// AssertAllRoutesCalled fails the test if any route has not answered a call.
+func (c *Client) AssertAllRoutesCalled(t TestingT) {
+	t.Helper()
+	for _, route := range c.Unused() {
+		t.Errorf("Route %s %s %s was never called", route.method, route.path, route.params.Encode())
+	}
+}
+
+// Returns a copy of values which later changes to values do not affect
+func copyValues(values url.Values) url.Values {
+	copied := url.Values{}
+	for key, list := range values {
+		copied[key] = append([]string{}, list...)
+	}
+	return copied
+}
+
+// Records the call and answers it from the first matching route
+func (c *Client) do(method, endpoint string, params url.Values) (tumblr.Response, error) {
+	// callers commonly reuse their params between requests
+	call := Call{Method: method, Path: endpoint, Params: copyValues(params)}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.calls = append(c.calls, call)
+	for _, route := range c.routes {
+		if !route.matches(call) {
+			continue
+		}
+		i := route.calls
+		route.calls++
+		if len(route.results) < 1 {
+			return *tumblr.NewResponse(nil, http.Header{}), nil
+		}
+		if i >= len(route.results) {
+			i = len(route.results) - 1
+		}
+		res := route.results[i]
+		if res.err != nil {
+			return tumblr.Response{}, res.err
+		}
+		response := *tumblr.NewResponse(res.body, res.headers)
+		if status := response.StatusCode(); status >= 400 {
+			return response, fmt.Errorf("%s %s: %d %s", method, endpoint, status, http.StatusText(status))
+		}
+		return response, nil
+	}
+	return tumblr.Response{}, &NoRouteError{Call: call}
+}
+
+// Get implements tumblr.ClientInterface.
+func (c *Client) Get(endpoint string) (tumblr.Response, error) {
+	return c.do(http.MethodGet, endpoint, nil)
+}
+
+// GetWithParams implements tumblr.ClientInterface.
+func (c *Client) GetWithParams(endpoint string, params url.Values) (tumblr.Response, error) {
+	return c.do(http.MethodGet, endpoint, params)
+}
+
+// Post implements tumblr.ClientInterface.
+func (c *Client) Post(endpoint string) (tumblr.Response, error) {
+	return c.do(http.MethodPost, endpoint, nil)
+}
+
+// PostWithParams implements tumblr.ClientInterface.
+func (c *Client) PostWithParams(endpoint string, params url.Values) (tumblr.Response, error) {
+	return c.do(http.MethodPost, endpoint, params)
+}
+
+// Put implements tumblr.ClientInterface.
+func (c *Client) Put(endpoint string) (tumblr.Response, error) {
+	return c.do(http.MethodPut, endpoint, nil)
+}
+
+// PutWithParams implements tumblr.ClientInterface.
+func (c *Client) PutWithParams(endpoint string, params url.Values) (tumblr.Response, error) {
+	return c.do(http.MethodPut, endpoint, params)
+}
+
+// Delete implements tumblr.ClientInterface.
+func (c *Client) Delete(endpoint string) (tumblr.Response, error) {
+	return c.do(http.MethodDelete, endpoint, nil)
+}
+
+// DeleteWithParams implements tumblr.ClientInterface.
+func (c *Client) DeleteWithParams(endpoint string, params url.Values) (tumblr.Response, error) {
+	return c.do(http.MethodDelete, endpoint, params)
+}
@@ -0,0 +1,109 @@
+package tumblrtest_test
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/tumblr/tumblr.go"
+	"github.com/tumblr/tumblr.go/tumblrtest"
+)
+
+func follower(name string) map[string]interface{} {
+	return map[string]interface{}{"name": name}
+}
+
+func TestClientPagination(t *testing.T) {
+	client := tumblrtest.NewClient()
+	client.On(http.MethodGet, "/blog/b.tumblr.com/followers").
+		WithParam("offset", "0").
+		RespondResult(map[string]interface{}{"total_users": 3, "users": []interface{}{follower("x"), follower("y")}})
+	client.On(http.MethodGet, "/blog/b.tumblr.com/followers").
+		WithParam("offset", "2").
+		RespondResult(map[string]interface{}{"total_users": 3, "users": []interface{}{follower("z")}})
+	page, err := tumblr.GetFollowers(client, "b", 0, 2)
+	if err != nil || len(page.Followers) != 2 {
+		t.Fatalf("Unexpected first page %v, %v", page, err)
+	}
+	if page, err = page.Next(); err != nil || len(page.Followers) != 1 || page.Followers[0].Name != "z" {
+		t.Fatalf("Unexpected second page %v, %v", page, err)
+	}
+	client.AssertCalled(t, http.MethodGet, "/blog/b.tumblr.com/followers", 2)
+	client.AssertAllRoutesCalled(t)
+}
+
+func TestClientSequencesAndErrors(t *testing.T) {
+	failure := errors.New("Network error")
+	client := tumblrtest.NewClient()
+	route := client.On(http.MethodGet, "/user/info").
+		Fail(failure).
+		RespondResult(map[string]interface{}{"user": map[string]interface{}{"name": "u"}})
+	if _, err := tumblr.GetUserInfo(client); err != failure {
+		t.Fatalf("Expected the injected error, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if user, err := tumblr.GetUserInfo(client); err != nil || user.Name != "u" {
+			t.Fatalf("Unexpected user %v, %v", user, err)
+		}
+	}
+	if calls := client.RouteCalls(route); calls != 3 {
+		t.Fatalf("Expected 3 calls, got %d", calls)
+	}
+}
+
+func TestClientNoRoute(t *testing.T) {
+	client := tumblrtest.NewClient()
+	client.On(http.MethodPost, "/user/follow").WithParam("url", "a.tumblr.com").RespondResult(nil)
+	err := tumblr.Follow(client, "b")
+	noRoute := &tumblrtest.NoRouteError{}
+	if !errors.As(err, &noRoute) || noRoute.Params.Get("url") != "b.tumblr.com" {
+		t.Fatalf("Expected a no route error, got %v", err)
+	}
+	if len(client.Calls()) != 1 || len(client.Unused()) != 1 {
+		t.Fatal("Unmatched calls should be recorded but not consume routes")
+	}
+}
+
+func TestClientCopiesParams(t *testing.T) {
+	client := tumblrtest.NewClient()
+	params := url.Values{"offset": []string{"0"}}
+	client.GetWithParams("/blog/b.tumblr.com/posts", params)
+	params.Set("offset", "20")
+	client.GetWithParams("/blog/b.tumblr.com/posts", params)
+	calls := client.Calls()
+	if len(calls) != 2 || calls[0].Params.Get("offset") != "0" || calls[1].Params.Get("offset") != "20" {
+		t.Fatalf("Calls should keep the params they were made with, got %v", calls)
+	}
+}
+
+func TestClientErrorStatus(t *testing.T) {
+	client := tumblrtest.NewClient()
+	client.On(http.MethodGet, "/user/info").RespondStatus(http.StatusNotFound, []interface{}{})
+	response, err := client.Get("/user/info")
+	if err == nil || err.Error() != "GET /user/info: 404 Not Found" {
+		t.Fatalf("Expected a status error, got %v", err)
+	}
+	if response.StatusCode() != http.StatusNotFound {
+		t.Fatal("The response should be returned along with the error")
+	}
+}
+
+func TestClientConcurrentRoutes(t *testing.T) {
+	client := tumblrtest.NewClient()
+	route := client.On(http.MethodGet, "/user/info")
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			client.Get("/user/info")
+		}
+		close(done)
+	}()
+	for i := 0; i < 100; i++ {
+		route.RespondResult(map[string]interface{}{"user": map[string]interface{}{"name": "u"}})
+	}
+	<-done
+	if calls := client.RouteCalls(route); calls != 100 {
+		t.Fatalf("Expected 100 calls, got %d", calls)
+	}
+}
@@ -0,0 +1,99 @@
+package fakeserver
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/tumblr/tumblr.go"
+)
+
+// HTTPClient is a minimal tumblr.ClientInterface issuing unauthenticated HTTP requests,
+// enough to talk to a Server (or any other API at BaseURL) from tests.
+type HTTPClient struct {
+	// API root, including the version, e.g. "https://api.tumblr.com/v2"
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewHTTPClient creates an HTTPClient for the API at baseURL using http.DefaultClient.
+func NewHTTPClient(baseURL string) *HTTPClient {
+	return &HTTPClient{BaseURL: strings.TrimSuffix(baseURL, "/"), HTTPClient: http.DefaultClient}
+}
+
+// Issues the request, sending params in the query string for GET and DELETE and as a form otherwise.
+// Responses with an error status are returned along with an error.
+func (c *HTTPClient) request(method, endpoint string, params url.Values) (tumblr.Response, error) {
+	target := c.BaseURL + endpoint
+	var body io.Reader
+	if method == http.MethodGet || method == http.MethodDelete {
+		if encoded := params.Encode(); encoded != "" {
+			target += "?" + encoded
+		}
+	} else {
+		body = strings.NewReader(params.Encode())
+	}
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return tumblr.Response{}, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return tumblr.Response{}, err
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return tumblr.Response{}, err
+	}
+	response := *tumblr.NewResponse(data, res.Header)
+	if res.StatusCode >= 400 {
+		return response, fmt.Errorf("%s %s: %s", method, endpoint, res.Status)
+	}
+	return response, nil
+}
+
+// Get implements tumblr.ClientInterface.
+func (c *HTTPClient) Get(endpoint string) (tumblr.Response, error) {
+	return c.request(http.MethodGet, endpoint, url.Values{})
+}
+
+// GetWithParams implements tumblr.ClientInterface.
+func (c *HTTPClient) GetWithParams(endpoint string, params url.Values) (tumblr.Response, error) {
+	return c.request(http.MethodGet, endpoint, params)
+}
+
+// Post implements tumblr.ClientInterface.
+func (c *HTTPClient) Post(endpoint string) (tumblr.Response, error) {
+	return c.request(http.MethodPost, endpoint, url.Values{})
+}
+
+// PostWithParams implements tumblr.ClientInterface.
+func (c *HTTPClient) PostWithParams(endpoint string, params url.Values) (tumblr.Response, error) {
+	return c.request(http.MethodPost, endpoint, params)
+}
+
+// Put implements tumblr.ClientInterface.
+func (c *HTTPClient) Put(endpoint string) (tumblr.Response, error) {
+	return c.request(http.MethodPut, endpoint, url.Values{})
+}
+
+// PutWithParams implements tumblr.ClientInterface.
+func (c *HTTPClient) PutWithParams(endpoint string, params url.Values) (tumblr.Response, error) {
+	return c.request(http.MethodPut, endpoint, params)
+}
+
+// Delete implements tumblr.ClientInterface.
+func (c *HTTPClient) Delete(endpoint string) (tumblr.Response, error) {
+	return c.request(http.MethodDelete, endpoint, url.Values{})
+}
+
+// DeleteWithParams implements tumblr.ClientInterface.
+func (c *HTTPClient) DeleteWithParams(endpoint string, params url.Values) (tumblr.Response, error) {
+	return c.request(http.MethodDelete, endpoint, params)
+}
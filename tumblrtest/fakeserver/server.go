@@ -0,0 +1,868 @@
+// Package fakeserver provides an in-memory fake of the Tumblr API for integration tests.
+//
+// A Server keeps real state: blogs, legacy and NPF posts (published, queued and drafted), likes and follows,
+// so that CreatePost followed by GetPosts returns the new post and Follow changes GetFollowing.
+// All requests act on behalf of a single user, whose primary blog is named after them; no authentication
+// is performed. Any HTTP based tumblr.ClientInterface can talk to it, such as the provided HTTPClient:
+//
+//	server := fakeserver.New("me")
+//	defer server.Close()
+//	client := server.Client()
+//	tumblr.CreatePost(client, "me", url.Values{"type": []string{"text"}, "body": []string{"hello"}})
+package fakeserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Number of items per page when a request does not set a limit
+const defaultLimit = 20
+
+// A blog known to the server
+type blog struct {
+	name        string
+	title       string
+	description string
+	uuid        string
+	followers   []string
+}
+
+// A post known to the server. Fields holds the type specific properties, as sent on creation.
+type post struct {
+	id        uint64
+	blog      string
+	state     string
+	timestamp int64
+	// When the post was featured in its tags, zero if it was not
+	featured  int64
+	reblogKey string
+	tags      []string
+	fields    map[string]interface{}
+}
+
+// Server is an in-memory fake of the Tumblr API served over HTTP. It is safe for concurrent use.
+// The API is served both at the root and under /v2.
+type Server struct {
+	*httptest.Server
+	mutex     sync.Mutex
+	user      string
+	blogs     map[string]*blog
+	posts     map[uint64]*post
+	nextId    uint64
+	clock     int64
+	following []string
+	likes     []uint64
+}
+
+// New starts a Server acting on behalf of user, who owns a blog of the same name.
+// Close must be called once the server is no longer needed.
+func New(user string) *Server {
+	s := NewUnstarted(user)
+	s.Start()
+	return s
+}
+
+// NewUnstarted creates a Server like New without starting it, so it can be used as a plain http.Handler.
+func NewUnstarted(user string) *Server {
+	s := &Server{
+		user:   user,
+		blogs:  map[string]*blog{},
+		posts:  map[uint64]*post{},
+		nextId: 1000,
+		clock:  time.Now().Unix(),
+	}
+	s.Server = httptest.NewUnstartedServer(s)
+	s.AddBlog(user, user)
+	return s
+}
+
+// Client returns an HTTPClient talking to the server.
+func (s *Server) Client() *HTTPClient {
+	return NewHTTPClient(s.URL + "/v2")
+}
+
+// Strips the domain of a blog identifier
+func blogName(identifier string) string {
+	return strings.TrimSuffix(identifier, ".tumblr.com")
+}
+
+// AddBlog adds a blog owned by someone other than the user, for the user to follow, like or reblog.
+// Adding an existing blog updates its title.
+func (s *Server) AddBlog(name, title string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	name = blogName(name)
+	if b, ok := s.blogs[name]; ok {
+		b.title = title
+		return
+	}
+	s.blogs[name] = &blog{name: name, title: title, uuid: fmt.Sprintf("t:%s", name)}
+}
+
+// AddFollower adds follower to the followers of a blog, which is then listed among the blogs follower follows.
+func (s *Server) AddFollower(blogName, follower string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	b, err := s.blog(blogName)
+	if err != nil {
+		return err
+	}
+	b.followers = appendUnique(b.followers, follower)
+	return nil
+}
+
+// AddPost creates a legacy post on any blog, using the same params as the post creation endpoint.
+func (s *Server) AddPost(blogName string, params url.Values) (uint64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	b, err := s.blog(blogName)
+	if err != nil {
+		return 0, err
+	}
+	p, err := s.createPost(b, params, false)
+	if err != nil {
+		return 0, err
+	}
+	return p.id, nil
+}
+
+// FeaturePost features a post in its tags as of now, moving it to the top of their /tagged results as the
+// API orders them by when a post was featured, if it was.
+func (s *Server) FeaturePost(id uint64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	p, ok := s.posts[id]
+	if !ok {
+		return newError(http.StatusNotFound, "Post %d not found", id)
+	}
+	s.clock++
+	p.featured = s.clock
+	return nil
+}
+
+// Error answered with an HTTP status
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+// Creates an error answered with status
+func newError(status int, format string, args ...interface{}) *apiError {
+	return &apiError{status: status, message: fmt.Sprintf(format, args...)}
+}
+
+// Looks a blog up by name or hostname
+func (s *Server) blog(identifier string) (*blog, error) {
+	if b, ok := s.blogs[blogName(identifier)]; ok {
+		return b, nil
+	}
+	return nil, newError(http.StatusNotFound, "Blog %s not found", identifier)
+}
+
+// Looks a post up by the id in params, checking it belongs to the blog if one is given
+func (s *Server) post(params url.Values, b *blog) (*post, error) {
+	id, err := strconv.ParseUint(params.Get("id"), 10, 64)
+	if err != nil {
+		return nil, newError(http.StatusBadRequest, "Invalid post id %q", params.Get("id"))
+	}
+	p, ok := s.posts[id]
+	if !ok || (b != nil && p.blog != b.name) {
+		return nil, newError(http.StatusNotFound, "Post %d not found", id)
+	}
+	return p, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeResponse(w, http.StatusBadRequest, nil, err.Error())
+		return
+	}
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v2"), "/")
+	s.mutex.Lock()
+	result, err := s.route(r.Method, strings.Split(path, "/"), r.Form)
+	s.mutex.Unlock()
+	if err != nil {
+		status := http.StatusInternalServerError
+		if e, ok := err.(*apiError); ok {
+			status = e.status
+		}
+		writeResponse(w, status, nil, err.Error())
+		return
+	}
+	writeResponse(w, http.StatusOK, result, "")
+}
+
+// Writes the API's meta/response envelope
+func writeResponse(w http.ResponseWriter, status int, result interface{}, message string) {
+	envelope := map[string]interface{}{
+		"meta": map[string]interface{}{
+			"status": status,
+			"msg":    http.StatusText(status),
+		},
+		"response": result,
+	}
+	if result == nil {
+		envelope["response"] = []interface{}{}
+	}
+	if message != "" {
+		envelope["errors"] = []interface{}{map[string]interface{}{"title": http.StatusText(status), "detail": message}}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(envelope)
+}
+
+// Dispatches a request to its endpoint
+func (s *Server) route(method string, segments []string, params url.Values) (interface{}, error) {
+	switch {
+	case len(segments) == 2 && segments[0] == "user":
+		return s.routeUser(method+" "+segments[1], params)
+	case len(segments) >= 3 && segments[0] == "blog":
+		b, err := s.blog(segments[1])
+		if err != nil {
+			return nil, err
+		}
+		return s.routeBlog(method, b, segments[2:], params)
+	case len(segments) == 1 && segments[0] == "tagged" && method == http.MethodGet:
+		return s.tagged(params)
+	}
+	return nil, newError(http.StatusNotFound, "No endpoint at /%s", strings.Join(segments, "/"))
+}
+
+// Dispatches a request to a /user/ endpoint
+func (s *Server) routeUser(route string, params url.Values) (interface{}, error) {
+	switch route {
+	case "GET info":
+		return s.userInfo(), nil
+	case "GET dashboard":
+		return s.dashboard(params)
+	case "GET likes":
+		return s.likedPosts(params)
+	case "GET following":
+		return s.followingBlogs(s.following, params)
+	case "POST follow", "POST unfollow":
+		b, err := s.blog(params.Get("url"))
+		if err != nil {
+			return nil, err
+		}
+		if route == "POST follow" {
+			s.following = appendUnique(s.following, b.name)
+			b.followers = appendUnique(b.followers, s.user)
+		} else {
+			s.following = remove(s.following, b.name)
+			b.followers = remove(b.followers, s.user)
+		}
+		return map[string]interface{}{"blog": s.blogInfo(b)}, nil
+	case "POST like", "POST unlike":
+		p, err := s.post(params, nil)
+		if err != nil {
+			return nil, err
+		}
+		if params.Get("reblog_key") != p.reblogKey {
+			return nil, newError(http.StatusBadRequest, "Invalid reblog key")
+		}
+		if route == "POST like" {
+			s.likes = append([]uint64{p.id}, removeId(s.likes, p.id)...)
+		} else {
+			s.likes = removeId(s.likes, p.id)
+		}
+		return nil, nil
+	}
+	return nil, newError(http.StatusNotFound, "No endpoint for %s", route)
+}
+
+// Dispatches a request to a /blog/{blog}/ endpoint
+func (s *Server) routeBlog(method string, b *blog, segments []string, params url.Values) (interface{}, error) {
+	route := method + " " + strings.Join(segments, "/")
+	switch route {
+	case "GET info":
+		return map[string]interface{}{"blog": s.blogInfo(b)}, nil
+	case "GET avatar":
+		return map[string]interface{}{"location": "https://assets.tumblr.test/avatar/" + b.name + "_64.png"}, nil
+	case "GET followers":
+		return s.followers(b, params)
+	case "GET following":
+		return s.followingBlogs(s.blogFollowing(b), params)
+	case "GET posts", "GET posts/queue", "GET posts/draft", "GET posts/submission":
+		state := "published"
+		if len(segments) > 1 {
+			state = segments[1]
+		}
+		return s.blogPosts(b, state, params.Get("type"), params)
+	case "POST post":
+		p, err := s.createPost(b, params, false)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"id": p.id}, nil
+	case "POST posts":
+		p, err := s.createPost(b, params, true)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"id": strconv.FormatUint(p.id, 10)}, nil
+	case "POST post/edit":
+		p, err := s.post(params, b)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"id": p.id}, s.editPost(p, params, false)
+	case "POST post/delete":
+		p, err := s.post(params, b)
+		if err != nil {
+			return nil, err
+		}
+		delete(s.posts, p.id)
+		s.likes = removeId(s.likes, p.id)
+		return map[string]interface{}{"id": p.id}, nil
+	case "POST post/reblog":
+		p, err := s.reblog(b, params)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"id": p.id}, nil
+	}
+	if len(segments) == 2 && segments[0] == "posts" {
+		if method == http.MethodPut {
+			p, err := s.post(url.Values{"id": []string{segments[1]}}, b)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{"id": segments[1]}, s.editPost(p, params, true)
+		}
+		if method == http.MethodGet {
+			return s.blogPosts(b, "published", segments[1], params)
+		}
+	}
+	return nil, newError(http.StatusNotFound, "No endpoint for %s on blog %s", route, b.name)
+}
+
+// Params which are not stored among a post's fields
+var reservedParams = map[string]bool{
+	"id": true, "type": true, "state": true, "tags": true, "reblog_key": true, "content": true, "layout": true,
+	"api_key": true, "comment": true, "date": true,
+}
+
+// Layouts accepted for the date param, the first being the one posts are rendered with
+var dateLayouts = []string{"2006-01-02 15:04:05 GMT", time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"}
+
+// Parses the date param of a post
+func parseDate(date string) (int64, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, date); err == nil {
+			return t.Unix(), nil
+		}
+	}
+	return 0, newError(http.StatusBadRequest, "Invalid date %q", date)
+}
+
+// Splits a comma separated tags param
+func parseTags(tags string) []string {
+	parsed := []string{}
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			parsed = append(parsed, tag)
+		}
+	}
+	return parsed
+}
+
+// Returns the state a post is stored in for the state param
+func parseState(state string) (string, error) {
+	switch state {
+	case "", "published":
+		return "published", nil
+	case "queue", "queued":
+		return "queue", nil
+	case "draft", "private":
+		return state, nil
+	}
+	return "", newError(http.StatusBadRequest, "Invalid state %q", state)
+}
+
+// Stores the params of a legacy or NPF request in a post
+func (s *Server) applyParams(p *post, params url.Values, npf bool) error {
+	if params.Get("state") != "" || p.state == "" {
+		state, err := parseState(params.Get("state"))
+		if err != nil {
+			return err
+		}
+		p.state = state
+	}
+	if _, ok := params["tags"]; ok {
+		p.tags = parseTags(params.Get("tags"))
+	}
+	if date := params.Get("date"); date != "" {
+		timestamp, err := parseDate(date)
+		if err != nil {
+			return err
+		}
+		p.timestamp = timestamp
+	}
+	if npf {
+		var content, layout interface{}
+		if err := json.Unmarshal([]byte(params.Get("content")), &content); err != nil {
+			return newError(http.StatusBadRequest, "Invalid content: %v", err)
+		}
+		p.fields["content"] = content
+		if params.Get("layout") != "" {
+			if err := json.Unmarshal([]byte(params.Get("layout")), &layout); err != nil {
+				return newError(http.StatusBadRequest, "Invalid layout: %v", err)
+			}
+			p.fields["layout"] = layout
+		}
+		return nil
+	}
+	for key := range params {
+		if !reservedParams[key] {
+			p.fields[key] = params.Get(key)
+		}
+	}
+	return nil
+}
+
+// Creates a post on the blog from the params of a legacy or NPF request
+func (s *Server) createPost(b *blog, params url.Values, npf bool) (*post, error) {
+	postType := params.Get("type")
+	if postType == "" || npf {
+		postType = "text"
+	}
+	s.nextId++
+	s.clock++
+	p := &post{
+		id:        s.nextId,
+		blog:      b.name,
+		timestamp: s.clock,
+		reblogKey: fmt.Sprintf("rk%d", s.nextId),
+		tags:      []string{},
+		fields:    map[string]interface{}{"type": postType},
+	}
+	if err := s.applyParams(p, params, npf); err != nil {
+		s.nextId--
+		s.clock--
+		return nil, err
+	}
+	s.posts[p.id] = p
+	return p, nil
+}
+
+// Updates a post from the params of a legacy or NPF request
+func (s *Server) editPost(p *post, params url.Values, npf bool) error {
+	edited := *p
+	edited.fields = map[string]interface{}{}
+	for key, value := range p.fields {
+		edited.fields[key] = value
+	}
+	if err := s.applyParams(&edited, params, npf); err != nil {
+		return err
+	}
+	*p = edited
+	return nil
+}
+
+// Reblogs the post given in params to the blog
+func (s *Server) reblog(b *blog, params url.Values) (*post, error) {
+	original, err := s.post(params, nil)
+	if err != nil {
+		return nil, err
+	}
+	if params.Get("reblog_key") != original.reblogKey {
+		return nil, newError(http.StatusBadRequest, "Invalid reblog key")
+	}
+	p, err := s.createPost(b, url.Values{"state": params["state"], "tags": params["tags"]}, false)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range original.fields {
+		p.fields[key] = value
+	}
+	root := original
+	if rootId, ok := original.fields["reblogged_root_id"].(uint64); ok && s.posts[rootId] != nil {
+		root = s.posts[rootId]
+	}
+	p.fields["reblogged_from_id"] = original.id
+	p.fields["reblogged_from_name"] = original.blog
+	p.fields["reblogged_root_id"] = root.id
+	p.fields["reblogged_root_name"] = root.blog
+	p.fields["reblog"] = map[string]interface{}{"comment": params.Get("comment"), "tree_html": ""}
+	return p, nil
+}
+
+// Renders a post as the API does
+func (s *Server) render(p *post) map[string]interface{} {
+	rendered := map[string]interface{}{}
+	for key, value := range p.fields {
+		rendered[key] = value
+	}
+	// the user's like and reblogs are the only possible notes
+	notes := len(s.filterPosts(func(other *post) bool {
+		return other.fields["reblogged_from_id"] == p.id
+	}))
+	liked := false
+	for _, id := range s.likes {
+		liked = liked || id == p.id
+	}
+	if liked {
+		notes++
+	}
+	postUrl := fmt.Sprintf("https://%s.tumblr.com/post/%d", p.blog, p.id)
+	rendered["id"] = p.id
+	rendered["id_string"] = strconv.FormatUint(p.id, 10)
+	rendered["blog_name"] = p.blog
+	rendered["reblog_key"] = p.reblogKey
+	rendered["timestamp"] = p.timestamp
+	if p.featured > 0 {
+		rendered["featured_timestamp"] = p.featured
+	}
+	rendered["date"] = time.Unix(p.timestamp, 0).UTC().Format(dateLayouts[0])
+	rendered["state"] = p.state
+	rendered["tags"] = p.tags
+	rendered["liked"] = liked
+	rendered["note_count"] = notes
+	rendered["post_url"] = postUrl
+	rendered["short_url"] = postUrl
+	rendered["can_like"] = true
+	rendered["can_reblog"] = true
+	return rendered
+}
+
+// Returns the posts matching the filter, newest first
+func (s *Server) filterPosts(keep func(p *post) bool) []*post {
+	posts := []*post{}
+	for _, p := range s.posts {
+		if keep(p) {
+			posts = append(posts, p)
+		}
+	}
+	// newest first, as posts given a date are placed by it
+	sort.Slice(posts, func(i, j int) bool {
+		if posts[i].timestamp != posts[j].timestamp {
+			return posts[i].timestamp > posts[j].timestamp
+		}
+		return posts[i].id > posts[j].id
+	})
+	return posts
+}
+
+// Returns the offset and limit params, applying defaults
+func pageParams(params url.Values) (int, int, error) {
+	offset, limit := 0, defaultLimit
+	var err error
+	if v := params.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			return 0, 0, newError(http.StatusBadRequest, "Invalid offset %q", v)
+		}
+	}
+	if v := params.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			return 0, 0, newError(http.StatusBadRequest, "Invalid limit %q", v)
+		}
+	}
+	if limit > defaultLimit {
+		limit = defaultLimit
+	}
+	return offset, limit, nil
+}
+
+// Returns the bounds of a page within n items
+func pageBounds(n, offset, limit int) (int, int) {
+	if offset > n {
+		offset = n
+	}
+	end := offset + limit
+	if end > n {
+		end = n
+	}
+	return offset, end
+}
+
+// Renders a page of posts
+func (s *Server) renderPage(posts []*post, params url.Values) ([]interface{}, error) {
+	offset, limit, err := pageParams(params)
+	if err != nil {
+		return nil, err
+	}
+	start, end := pageBounds(len(posts), offset, limit)
+	rendered := []interface{}{}
+	for _, p := range posts[start:end] {
+		rendered = append(rendered, s.render(p))
+	}
+	return rendered, nil
+}
+
+// Reports whether the post has the tag
+func hasTag(p *post, tag string) bool {
+	for _, t := range p.tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Answers /blog/{blog}/posts and its variants
+func (s *Server) blogPosts(b *blog, state, postType string, params url.Values) (interface{}, error) {
+	var id uint64
+	if v := params.Get("id"); v != "" {
+		var err error
+		if id, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return nil, newError(http.StatusBadRequest, "Invalid post id %q", v)
+		}
+	}
+	// drafts are paged by before_id only, like the API does; published posts may be paged by before
+	var beforeId uint64
+	if state == "draft" {
+		if params.Get("offset") != "" {
+			return nil, newError(http.StatusBadRequest, "Drafts are paged with before_id, not offset")
+		}
+		if v := params.Get("before_id"); v != "" {
+			var err error
+			if beforeId, err = strconv.ParseUint(v, 10, 64); err != nil {
+				return nil, newError(http.StatusBadRequest, "Invalid before_id %q", v)
+			}
+		}
+	}
+	before, err := parseBefore(params)
+	if err != nil {
+		return nil, err
+	}
+	tag := params.Get("tag")
+	posts := s.filterPosts(func(p *post) bool {
+		// the owner of a blog also sees its private posts among the published ones
+		listed := p.state == state || (state == "published" && p.state == "private" && b.name == s.user)
+		return p.blog == b.name && listed &&
+			(postType == "" || p.fields["type"] == postType) &&
+			(tag == "" || hasTag(p, tag)) &&
+			(id == 0 || p.id == id) &&
+			(beforeId == 0 || p.id < beforeId) &&
+			(before == 0 || p.timestamp < before)
+	})
+	if state == "draft" {
+		sort.Slice(posts, func(i, j int) bool {
+			return posts[i].id > posts[j].id
+		})
+	}
+	rendered, err := s.renderPage(posts, params)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"blog":        s.blogInfo(b),
+		"posts":       rendered,
+		"total_posts": len(posts),
+	}, nil
+}
+
+// Answers /user/dashboard
+func (s *Server) dashboard(params url.Values) (interface{}, error) {
+	var sinceId uint64
+	if v := params.Get("since_id"); v != "" {
+		var err error
+		if sinceId, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return nil, newError(http.StatusBadRequest, "Invalid since_id %q", v)
+		}
+	}
+	shown := append([]string{s.user}, s.following...)
+	posts := s.filterPosts(func(p *post) bool {
+		return p.state == "published" && p.id > sinceId && slices.Contains(shown, p.blog)
+	})
+	rendered, err := s.renderPage(posts, params)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"posts": rendered}, nil
+}
+
+// Answers /user/likes
+func (s *Server) likedPosts(params url.Values) (interface{}, error) {
+	liked := []*post{}
+	for _, id := range s.likes {
+		if p, ok := s.posts[id]; ok {
+			liked = append(liked, p)
+		}
+	}
+	rendered, err := s.renderPage(liked, params)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"liked_posts": rendered, "liked_count": len(liked)}, nil
+}
+
+// Parses the before param, a timestamp which results are published before; zero if it is not set
+func parseBefore(params url.Values) (int64, error) {
+	v := params.Get("before")
+	if v == "" {
+		return 0, nil
+	}
+	before, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, newError(http.StatusBadRequest, "Invalid before %q", v)
+	}
+	return before, nil
+}
+
+// Answers /tagged
+func (s *Server) tagged(params url.Values) (interface{}, error) {
+	tag := params.Get("tag")
+	if tag == "" {
+		return nil, newError(http.StatusBadRequest, "No tag provided")
+	}
+	before, err := parseBefore(params)
+	if err != nil {
+		return nil, err
+	}
+	// results are ordered and paged by when a post was featured in the tag if it was, and published otherwise
+	posts := s.filterPosts(func(p *post) bool {
+		return p.state == "published" && hasTag(p, tag) && (before == 0 || effectiveTimestamp(p) < before)
+	})
+	sort.SliceStable(posts, func(i, j int) bool {
+		return effectiveTimestamp(posts[i]) > effectiveTimestamp(posts[j])
+	})
+	return s.renderPage(posts, url.Values{"limit": params["limit"]})
+}
+
+// Returns the timestamp tagged results are ordered by
+func effectiveTimestamp(p *post) int64 {
+	if p.featured > 0 {
+		return p.featured
+	}
+	return p.timestamp
+}
+
+// Renders a blog's info as the API does
+func (s *Server) blogInfo(b *blog) map[string]interface{} {
+	published := s.filterPosts(func(p *post) bool {
+		return p.blog == b.name && p.state == "published"
+	})
+	updated := int64(0)
+	if len(published) > 0 {
+		updated = published[0].timestamp
+	}
+	return map[string]interface{}{
+		"name":        b.name,
+		"title":       b.title,
+		"description": b.description,
+		"url":         fmt.Sprintf("https://%s.tumblr.com/", b.name),
+		"uuid":        b.uuid,
+		"posts":       len(published),
+		"total_posts": len(published),
+		"updated":     updated,
+		"followed":    slices.Contains(s.following, b.name),
+		"can_submit":  false,
+		"share_likes": false,
+	}
+}
+
+// Answers /user/info
+func (s *Server) userInfo() interface{} {
+	primary := s.blogs[s.user]
+	return map[string]interface{}{
+		"user": map[string]interface{}{
+			"name":                s.user,
+			"likes":               len(s.likes),
+			"following":           len(s.following),
+			"default_post_format": "html",
+			"blogs": []interface{}{map[string]interface{}{
+				"name":      primary.name,
+				"title":     primary.title,
+				"url":       fmt.Sprintf("https://%s.tumblr.com/", primary.name),
+				"primary":   true,
+				"followers": len(primary.followers),
+				"type":      "public",
+			}},
+		},
+	}
+}
+
+// Returns the blogs a blog follows: the user's followed blogs, or the blogs listing it as a follower, by name
+func (s *Server) blogFollowing(b *blog) []string {
+	if b.name == s.user {
+		return s.following
+	}
+	following := []string{}
+	for name, other := range s.blogs {
+		if slices.Contains(other.followers, b.name) {
+			following = append(following, name)
+		}
+	}
+	sort.Strings(following)
+	return following
+}
+
+// Answers /user/following and /blog/{blog}/following with a page of the given blogs
+func (s *Server) followingBlogs(following []string, params url.Values) (interface{}, error) {
+	offset, limit, err := pageParams(params)
+	if err != nil {
+		return nil, err
+	}
+	start, end := pageBounds(len(following), offset, limit)
+	blogs := []interface{}{}
+	for _, name := range following[start:end] {
+		blogs = append(blogs, s.blogInfo(s.blogs[name]))
+	}
+	return map[string]interface{}{"total_blogs": len(following), "blogs": blogs}, nil
+}
+
+// Answers /blog/{blog}/followers
+func (s *Server) followers(b *blog, params url.Values) (interface{}, error) {
+	offset, limit, err := pageParams(params)
+	if err != nil {
+		return nil, err
+	}
+	start, end := pageBounds(len(b.followers), offset, limit)
+	users := []interface{}{}
+	for _, name := range b.followers[start:end] {
+		users = append(users, map[string]interface{}{
+			"name":      name,
+			"url":       fmt.Sprintf("https://%s.tumblr.com/", name),
+			"following": slices.Contains(s.following, name),
+			"updated":   0,
+		})
+	}
+	return map[string]interface{}{"total_users": len(b.followers), "users": users}, nil
+}
+
+// Appends value unless it is already present
+func appendUnique(values []string, value string) []string {
+	if slices.Contains(values, value) {
+		return values
+	}
+	return append(values, value)
+}
+
+// Returns values without value
+func remove(values []string, value string) []string {
+	kept := []string{}
+	for _, v := range values {
+		if v != value {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+// Returns ids without id
+func removeId(ids []uint64, id uint64) []uint64 {
+	kept := []uint64{}
+	for _, v := range ids {
+		if v != id {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
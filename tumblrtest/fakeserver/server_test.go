@@ -0,0 +1,241 @@
+package fakeserver_test
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/tumblr/tumblr.go"
+	"github.com/tumblr/tumblr.go/tumblrtest/fakeserver"
+)
+
+func TestCreatePostThenGetPosts(t *testing.T) {
+	server := fakeserver.New("me")
+	defer server.Close()
+	client := server.Client()
+	ref, err := tumblr.CreatePost(client, "me", url.Values{
+		"type":  []string{"text"},
+		"title": []string{"Hello"},
+		"body":  []string{"<p>World</p>"},
+		"tags":  []string{"a, b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	posts, err := tumblr.GetPosts(client, "me", url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := posts.All()
+	if err != nil || len(all) != 1 || posts.TotalPosts != 1 {
+		t.Fatalf("Expected the created post, got %v, %v", all, err)
+	}
+	text, ok := tumblr.AsText(all[0])
+	if !ok || text.Id != ref.Id || text.Title != "Hello" || text.Body != "<p>World</p>" || len(text.Tags) != 2 {
+		t.Fatalf("Unexpected post %+v", all[0])
+	}
+	if err = tumblr.EditPost(client, "me", ref.Id, url.Values{"title": []string{"Bye"}}); err != nil {
+		t.Fatal(err)
+	}
+	posts, _ = tumblr.GetPosts(client, "me", url.Values{})
+	all, _ = posts.All()
+	if text, _ = tumblr.AsText(all[0]); text.Title != "Bye" {
+		t.Fatalf("Edit was not applied: %+v", text)
+	}
+	if err = tumblr.DeletePost(client, "me", ref.Id); err != nil {
+		t.Fatal(err)
+	}
+	if posts, _ = tumblr.GetPosts(client, "me", url.Values{}); len(posts.Posts) != 0 {
+		t.Fatal("Deleted post should be gone")
+	}
+}
+
+func TestPostDate(t *testing.T) {
+	server := fakeserver.New("me")
+	defer server.Close()
+	client := server.Client()
+	if _, err := server.AddPost("me", url.Values{"body": []string{"new"}}); err != nil {
+		t.Fatal(err)
+	}
+	ref, err := tumblr.CreatePost(client, "me", url.Values{
+		"body": []string{"old"},
+		"date": []string{"2015-03-01 12:00:00 GMT"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tumblr.CreatePost(client, "me", url.Values{"date": []string{"yesterday"}}); err == nil {
+		t.Fatal("Invalid dates should be rejected")
+	}
+	posts, _ := tumblr.GetPosts(client, "me", url.Values{})
+	all, _ := posts.All()
+	if len(all) != 2 {
+		t.Fatalf("Expected two posts, got %d", len(all))
+	}
+	// backdated posts are listed by their date
+	old := all[1].GetSelf()
+	if old.Id != ref.Id || old.Timestamp != 1425211200 || old.Date != "2015-03-01 12:00:00 GMT" {
+		t.Fatalf("Unexpected backdated post %+v", old)
+	}
+}
+
+func TestPostsBefore(t *testing.T) {
+	server := fakeserver.New("me")
+	defer server.Close()
+	for _, date := range []string{"2020-01-01", "2021-01-01", "2022-01-01"} {
+		server.AddPost("me", url.Values{"body": []string{date}, "date": []string{date}})
+	}
+	// 2021-01-01 00:00:00 GMT
+	posts, err := tumblr.GetPosts(server.Client(), "me", url.Values{"before": []string{"1609459200"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all, _ := posts.All(); len(all) != 1 || all[0].GetSelf().Body != "2020-01-01" {
+		t.Fatalf("Expected the posts published before the timestamp, got %+v", all)
+	}
+}
+
+func TestPrivatePosts(t *testing.T) {
+	server := fakeserver.New("me")
+	defer server.Close()
+	server.AddBlog("other", "Other")
+	private := uint64(0)
+	for _, blog := range []string{"me", "other"} {
+		server.AddPost(blog, url.Values{"body": []string{"public"}})
+		private, _ = server.AddPost(blog, url.Values{"body": []string{"private"}, "state": []string{"private"}})
+	}
+	client := server.Client()
+	if posts, _ := tumblr.GetPosts(client, "me", url.Values{}); len(posts.Posts) != 2 || posts.Posts[0].Id != private-2 {
+		t.Fatalf("The owner should see their private posts, got %+v", posts)
+	}
+	if posts, _ := tumblr.GetPosts(client, "other", url.Values{}); len(posts.Posts) != 1 {
+		t.Fatalf("Private posts of other blogs should not be listed, got %+v", posts)
+	}
+}
+
+func TestTaggedFeatured(t *testing.T) {
+	server := fakeserver.New("me")
+	defer server.Close()
+	ids := []uint64{}
+	for i := 0; i < 3; i++ {
+		id, _ := server.AddPost("me", url.Values{"body": []string{strconv.Itoa(i)}, "tags": []string{"cats"}})
+		ids = append(ids, id)
+	}
+	if err := server.FeaturePost(ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	client := server.Client()
+	results, err := tumblr.TaggedSearch(client, "cats", url.Values{"limit": []string{"2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Posts) != 2 || results.Posts[0].GetSelf().Id != ids[0] || results.Posts[0].GetSelf().FeaturedTimestamp == 0 {
+		t.Fatalf("The featured post should come first, got %+v", results.Posts)
+	}
+	if results, err = results.Next(); err != nil || len(results.Posts) != 1 || results.Posts[0].GetSelf().Id != ids[1] {
+		t.Fatalf("Paging should carry on before the effective timestamp, got %+v, %v", results, err)
+	}
+}
+
+func TestNPFPosts(t *testing.T) {
+	server := fakeserver.New("me")
+	defer server.Close()
+	client := server.Client()
+	content := []tumblr.ContentBlock{{Type: "text", Text: "npf"}}
+	ref, err := tumblr.CreateNPFPost(client, "me", content, nil, url.Values{"state": []string{"draft"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if posts, _ := tumblr.GetPosts(client, "me", url.Values{}); len(posts.Posts) != 0 {
+		t.Fatal("Drafts should not be published")
+	}
+	drafts, err := tumblr.GetDrafts(client, "me", url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, _ := drafts.All()
+	if len(all) != 1 || len(all[0].GetSelf().Content) != 1 || all[0].GetSelf().Content[0].Text != "npf" {
+		t.Fatalf("Unexpected drafts %+v", all)
+	}
+	if _, err = tumblr.GetDrafts(client, "me", url.Values{"offset": []string{"0"}}); err == nil {
+		t.Fatal("Drafts should not be paged by offset")
+	}
+	before := url.Values{"before_id": []string{strconv.FormatUint(ref.Id, 10)}}
+	if drafts, err = tumblr.GetDrafts(client, "me", before); err != nil || len(drafts.Posts) != 0 {
+		t.Fatalf("Expected no drafts before the only one, got %v, %v", drafts, err)
+	}
+	content[0].Text = "edited"
+	if err = tumblr.EditNPFPost(client, "me", ref.Id, content, nil, url.Values{"state": []string{"published"}}); err != nil {
+		t.Fatal(err)
+	}
+	posts, _ := tumblr.GetPosts(client, "me", url.Values{})
+	if all, _ = posts.All(); len(all) != 1 || all[0].GetSelf().Content[0].Text != "edited" {
+		t.Fatalf("Edited post should be published, got %+v", all)
+	}
+}
+
+func TestFollowLikeReblog(t *testing.T) {
+	server := fakeserver.New("me")
+	defer server.Close()
+	server.AddBlog("other", "Other")
+	id, err := server.AddPost("other", url.Values{"type": []string{"quote"}, "quote": []string{"q"}, "tags": []string{"x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := server.Client()
+	if err = tumblr.Follow(client, "other"); err != nil {
+		t.Fatal(err)
+	}
+	following, err := tumblr.GetFollowing(client, 0, 20)
+	if err != nil || following.Total != 1 || following.Blogs[0].Name != "other" {
+		t.Fatalf("Following should list the followed blog, got %+v, %v", following, err)
+	}
+	followers, _ := tumblr.GetFollowers(client, "other", 0, 20)
+	if followers.Total != 1 || followers.Followers[0].Name != "me" {
+		t.Fatalf("Followers should list the user, got %+v", followers)
+	}
+	server.AddBlog("third", "Third")
+	server.AddFollower("other", "third")
+	if following, err = tumblr.GetBlogFollowing(client, "third", 0, 20); err != nil || following.Total != 1 || following.Blogs[0].Name != "other" {
+		t.Fatalf("A blog's following should list the blogs it was added to as a follower, got %+v, %v", following, err)
+	}
+	dashboard, err := tumblr.GetDashboard(client, url.Values{})
+	if err != nil || len(dashboard.Posts) != 1 {
+		t.Fatalf("Dashboard should show the followed blog's post, got %v", err)
+	}
+	post := dashboard.Posts[0].GetSelf()
+	if err = tumblr.LikePost(client, id, post.ReblogKey); err != nil {
+		t.Fatal(err)
+	}
+	likes, _ := tumblr.GetLikes(client, url.Values{})
+	if likes.TotalLikes != 1 || likes.Posts[0].Id != id {
+		t.Fatalf("Likes should list the liked post, got %+v", likes)
+	}
+	if err = tumblr.LikePost(client, id, "wrong"); err == nil {
+		t.Fatal("Liking with the wrong reblog key should fail")
+	}
+	reblog, err := tumblr.ReblogPost(client, "me", id, post.ReblogKey, url.Values{"comment": []string{"nice"}, "tags": []string{"x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := tumblr.TaggedSearch(client, "x", url.Values{})
+	if err != nil || len(results.Posts) != 2 || results.Posts[0].GetSelf().Id != reblog.Id {
+		t.Fatalf("Tagged search should find the post and its reblog, got %v", err)
+	}
+	if err = tumblr.Unfollow(client, "other"); err != nil {
+		t.Fatal(err)
+	}
+	if following, _ = tumblr.GetFollowing(client, 0, 20); following.Total != 0 {
+		t.Fatal("Unfollowing should empty the following list")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	server := fakeserver.New("me")
+	defer server.Close()
+	response, err := server.Client().Get("/blog/nobody.tumblr.com/info")
+	if err == nil || response.StatusCode() != http.StatusNotFound {
+		t.Fatalf("Unknown blogs should be not found, got %d, %v", response.StatusCode(), err)
+	}
+}
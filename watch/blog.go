@@ -0,0 +1,308 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"sort"
+
+	"github.com/tumblr/tumblr.go"
+)
+
+// ChangeType is the kind of a PostChange.
+type ChangeType string
+
+const (
+	Created ChangeType = "created"
+	Edited  ChangeType = "edited"
+	Deleted ChangeType = "deleted"
+)
+
+// Fields reported as changed by an Edited PostChange
+const (
+	BodyField  = "body"
+	TagsField  = "tags"
+	StateField = "state"
+)
+
+// PostChange is a difference between two snapshots of a blog's posts.
+type PostChange struct {
+	Type ChangeType
+	Blog string
+	Id   uint64
+	// The post in the previous snapshot; nil for Created
+	Before tumblr.PostInterface
+	// The post in the new snapshot; nil for Deleted
+	After tumblr.PostInterface
+	// What changed in an Edited post: BodyField, TagsField and/or StateField
+	Fields []string
+}
+
+// A post of a snapshot
+type snapshotPost struct {
+	Post json.RawMessage `json:"post"`
+	// Digest of the content fields
+	Body  string   `json:"body"`
+	Tags  []string `json:"tags"`
+	State string   `json:"state"`
+}
+
+// The posts of a blog at some point, kept in the Store
+type blogSnapshot struct {
+	Posts map[uint64]snapshotPost `json:"posts"`
+}
+
+// Post fields digested to tell whether a post was edited. Anything else, such as engagement counts, the
+// embedded blog and the reblog trail, changes without the post itself being edited.
+var contentFields = []string{
+	"type", "date", "slug", "format", "title", "body", "caption", "text", "source", "url", "description", "excerpt",
+	"link_author", "question", "answer", "dialog", "photos", "video_url", "audio_url", "audio_source_url",
+	"track_name", "artist", "album_art", "source_url", "source_title", "content", "layout",
+}
+
+// Snapshots a post
+func newSnapshotPost(post tumblr.PostInterface) (snapshotPost, error) {
+	self := post.GetSelf()
+	snapshot := snapshotPost{Tags: self.Tags, State: self.State}
+	var err error
+	if raw, ok := post.(*tumblr.RawPost); ok {
+		snapshot.Post = raw.Raw
+	} else if snapshot.Post, err = json.Marshal(post); err != nil {
+		return snapshot, err
+	}
+	all := map[string]interface{}{}
+	if err = json.Unmarshal(snapshot.Post, &all); err != nil {
+		return snapshot, err
+	}
+	fields := map[string]interface{}{}
+	for _, field := range contentFields {
+		if value, ok := all[field]; ok {
+			fields[field] = value
+		}
+	}
+	// of a reblog, only the reblogger's comment is theirs to edit
+	if reblog, ok := all["reblog"].(map[string]interface{}); ok {
+		fields["reblog_comment"] = reblog["comment"]
+	}
+	// maps are encoded with sorted keys, so equal bodies have equal digests
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return snapshot, err
+	}
+	digest := sha256.Sum256(b)
+	snapshot.Body = hex.EncodeToString(digest[:])
+	return snapshot, nil
+}
+
+// Decodes the post of a snapshot
+func (s snapshotPost) decode() (tumblr.PostInterface, error) {
+	posts, err := tumblr.ReadPosts(bytes.NewReader(append(append([]byte{'['}, s.Post...), ']')))
+	if len(posts) != 1 {
+		return nil, err
+	}
+	return posts[0], nil
+}
+
+// Reports whether two tag lists differ
+func tagsDiffer(a, b []string) bool {
+	if len(a) != len(b) {
+		return true
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// BlogWatcher polls the posts of a set of blogs, comparing each snapshot with the previous one to report
+// created, edited and deleted posts. Snapshots are kept in a Store, so changes made while the watcher was
+// stopped are reported when it restarts.
+//
+// Snapshots hold the posts the API lists: published posts, along with private ones when the blog belongs to
+// the authenticated user. A post moved to the drafts or the queue is reported Deleted, and Created once it is
+// published again; StateField only reports a post made private or public.
+type BlogWatcher struct {
+	Schedule
+	Blogs []string
+	// Where snapshots are kept; defaults to a MemoryStore
+	Store Store
+	// Prefix of the snapshots' keys in Store, followed by the blog name
+	KeyPrefix string
+	// Only snapshot the newest posts; zero snapshots every post. Posts falling behind the newest MaxPosts are
+	// kept as they were rather than reported deleted.
+	MaxPosts int
+	// Report the posts of the first snapshot of a blog as created, instead of only taking the snapshot
+	EmitInitial bool
+	client      tumblr.ClientInterface
+}
+
+// NewBlogWatcher creates a BlogWatcher for blogs.
+func NewBlogWatcher(client tumblr.ClientInterface, blogs ...string) *BlogWatcher {
+	return &BlogWatcher{client: client, Blogs: blogs, KeyPrefix: "blog/"}
+}
+
+// Run polls the blogs until ctx is done, calling handle with each change. A blog's new snapshot is saved once
+// all its changes are handled; if handle returns an error the watch stops with that error, and the blog's
+// changes are reported again on the next run.
+func (w *BlogWatcher) Run(ctx context.Context, handle func(PostChange) error) error {
+	if len(w.Blogs) < 1 {
+		return errors.New("No blogs to watch")
+	}
+	if w.Store == nil {
+		w.Store = NewMemoryStore()
+	}
+	return w.run(ctx, func(ctx context.Context) error {
+		for _, blog := range w.Blogs {
+			if err := w.poll(ctx, blog, handle); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Watch polls the blogs like Run, delivering changes over the returned channel, which is closed once ctx is
+// done. Errors saving snapshots end the watch and are reported to OnError.
+func (w *BlogWatcher) Watch(ctx context.Context) <-chan PostChange {
+	changes := make(chan PostChange)
+	go func() {
+		defer close(changes)
+		err := w.Run(ctx, func(change PostChange) error {
+			select {
+			case changes <- change:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil && ctx.Err() == nil && w.OnError != nil {
+			w.OnError(err)
+		}
+	}()
+	return changes
+}
+
+// Takes a snapshot of a blog's posts
+func (w *BlogWatcher) snapshot(blog string) (*blogSnapshot, uint64, error) {
+	snapshot := &blogSnapshot{Posts: map[uint64]snapshotPost{}}
+	oldest := uint64(0)
+	pager := tumblr.NewPostPager(w.client, blog, url.Values{})
+	for w.MaxPosts < 1 || len(snapshot.Posts) < w.MaxPosts {
+		post, err := pager.Next()
+		if err == io.EOF {
+			break
+		}
+		// posts failing to decode are still tracked through their raw form
+		if errors.As(err, new(*tumblr.PostDecodeError)) && post != nil {
+			err = nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		id := post.GetSelf().Id
+		if snapshot.Posts[id], err = newSnapshotPost(post); err != nil {
+			return nil, 0, err
+		}
+		if oldest == 0 || id < oldest {
+			oldest = id
+		}
+	}
+	return snapshot, oldest, nil
+}
+
+// Compares two snapshots of a blog, returning its changes by post id
+func (w *BlogWatcher) diff(blog string, previous, current *blogSnapshot) ([]PostChange, error) {
+	changes := []PostChange{}
+	for id, after := range current.Posts {
+		change := PostChange{Blog: blog, Id: id}
+		before, existed := previous.Posts[id]
+		if !existed {
+			change.Type = Created
+		} else {
+			if before.Body != after.Body {
+				change.Fields = append(change.Fields, BodyField)
+			}
+			if tagsDiffer(before.Tags, after.Tags) {
+				change.Fields = append(change.Fields, TagsField)
+			}
+			if before.State != after.State {
+				change.Fields = append(change.Fields, StateField)
+			}
+			if len(change.Fields) < 1 {
+				continue
+			}
+			change.Type = Edited
+		}
+		changes = append(changes, change)
+	}
+	for id := range previous.Posts {
+		if _, ok := current.Posts[id]; !ok {
+			changes = append(changes, PostChange{Type: Deleted, Blog: blog, Id: id})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Id < changes[j].Id
+	})
+	var err error
+	for i := range changes {
+		change := &changes[i]
+		if before, ok := previous.Posts[change.Id]; ok {
+			if change.Before, err = before.decode(); err != nil {
+				return nil, err
+			}
+		}
+		if after, ok := current.Posts[change.Id]; ok {
+			if change.After, err = after.decode(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return changes, nil
+}
+
+// Snapshots a blog and reports its changes since the previous snapshot
+func (w *BlogWatcher) poll(ctx context.Context, blog string, handle func(PostChange) error) error {
+	key := w.KeyPrefix + blog
+	previous := &blogSnapshot{}
+	found, err := w.Store.Load(key, previous)
+	if err != nil {
+		return &stopError{err}
+	}
+	current, oldest, err := w.snapshot(blog)
+	if err != nil {
+		return err
+	}
+	if w.MaxPosts > 0 && previous.Posts != nil {
+		// posts behind the window were not seen, not deleted
+		for id, post := range previous.Posts {
+			if id < oldest {
+				current.Posts[id] = post
+			}
+		}
+	}
+	if found || w.EmitInitial {
+		if previous.Posts == nil {
+			previous.Posts = map[uint64]snapshotPost{}
+		}
+		changes, err := w.diff(blog, previous, current)
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			if err = ctx.Err(); err != nil {
+				return err
+			}
+			if err = handle(change); err != nil {
+				return &stopError{err}
+			}
+		}
+	}
+	return stopOnError(w.Store.Save(key, current))
+}
@@ -0,0 +1,204 @@
+package watch_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/tumblr/tumblr.go"
+	"github.com/tumblr/tumblr.go/tumblrtest"
+	"github.com/tumblr/tumblr.go/tumblrtest/fakeserver"
+	"github.com/tumblr/tumblr.go/watch"
+)
+
+// Receives n changes from a watch, failing after a timeout
+func receiveChanges(t *testing.T, changes <-chan watch.PostChange, n int) []watch.PostChange {
+	received := []watch.PostChange{}
+	for len(received) < n {
+		select {
+		case change, ok := <-changes:
+			if !ok {
+				t.Fatalf("Watch ended after %v", received)
+			}
+			received = append(received, change)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out after receiving %v", received)
+		}
+	}
+	return received
+}
+
+// A store which calls saved after each save
+type notifyingStore struct {
+	watch.Store
+	saved func()
+}
+
+func (s notifyingStore) Save(key string, v interface{}) error {
+	err := s.Store.Save(key, v)
+	s.saved()
+	return err
+}
+
+// Polls a single blog once, returning the changes
+func pollOnce(t *testing.T, watcher *watch.BlogWatcher) []watch.PostChange {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := watcher.Store
+	if store == nil {
+		store = watch.NewMemoryStore()
+	}
+	// the snapshot is saved once the blog's changes are handled
+	watcher.Store = notifyingStore{store, cancel}
+	defer func() {
+		watcher.Store = store
+	}()
+	changes := []watch.PostChange{}
+	err := watcher.Run(ctx, func(change watch.PostChange) error {
+		changes = append(changes, change)
+		return nil
+	})
+	if err != context.Canceled {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	return changes
+}
+
+func TestBlogWatcher(t *testing.T) {
+	server := fakeserver.New("david")
+	defer server.Close()
+	client := server.Client()
+	kept := addPosts(t, server, "david", 1)[0]
+	edited := addPosts(t, server, "david", 1)[0]
+	retagged := addPosts(t, server, "david", 1)[0]
+	deleted := addPosts(t, server, "david", 1)[0]
+	store := watch.NewMemoryStore()
+
+	watcher := watch.NewBlogWatcher(client, "david")
+	watcher.Store = store
+	if changes := pollOnce(t, watcher); len(changes) != 0 {
+		t.Fatalf("Expected the first snapshot to be taken silently, got %v", changes)
+	}
+
+	tumblr.EditPost(client, "david", edited, url.Values{"body": {"changed"}})
+	tumblr.EditPost(client, "david", retagged, url.Values{"tags": {"new"}})
+	tumblr.DeletePost(client, "david", deleted)
+	created := addPosts(t, server, "david", 1)[0]
+
+	// a new watcher picks the snapshot up from the store
+	watcher = watch.NewBlogWatcher(client, "david")
+	watcher.Store = store
+	changes := pollOnce(t, watcher)
+	if len(changes) != 4 {
+		t.Fatalf("Expected 4 changes, got %+v", changes)
+	}
+	for _, change := range changes {
+		if change.Id == kept {
+			t.Errorf("Unexpected change of an untouched post %+v", change)
+		}
+	}
+	edit := changes[0]
+	if edit.Type != watch.Edited || edit.Id != edited || len(edit.Fields) != 1 || edit.Fields[0] != watch.BodyField {
+		t.Errorf("Expected a body edit, got %+v", edit)
+	}
+	if edit.Before.GetSelf().Body != "post" || edit.After.GetSelf().Body != "changed" {
+		t.Errorf("Expected before and after copies, got %v and %v", edit.Before, edit.After)
+	}
+	retag := changes[1]
+	if retag.Type != watch.Edited || retag.Id != retagged || len(retag.Fields) != 1 || retag.Fields[0] != watch.TagsField {
+		t.Errorf("Expected a tags edit, got %+v", retag)
+	}
+	if del := changes[2]; del.Type != watch.Deleted || del.Id != deleted || del.After != nil || del.Before.GetSelf().Id != deleted {
+		t.Errorf("Expected a deletion, got %+v", del)
+	}
+	if create := changes[3]; create.Type != watch.Created || create.Id != created || create.Before != nil || create.Blog != "david" {
+		t.Errorf("Expected a creation, got %+v", create)
+	}
+	if changes = pollOnce(t, watcher); len(changes) != 0 {
+		t.Errorf("Expected no changes without edits, got %+v", changes)
+	}
+}
+
+func TestBlogWatcherStateChange(t *testing.T) {
+	client := tumblrtest.NewClient()
+	client.On(http.MethodGet, "/blog/david.tumblr.com/posts").
+		Respond(`{"response": {"posts": [{"id": 1, "type": "text", "state": "published", "note_count": 1}], "total_posts": 1}}`).
+		Respond(`{"response": {"posts": [{"id": 1, "type": "text", "state": "private", "note_count": 9}], "total_posts": 1}}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watcher := watch.NewBlogWatcher(client, "david")
+	watcher.Interval = time.Millisecond
+	change := receiveChanges(t, watcher.Watch(ctx), 1)[0]
+	// new notes alone are not an edit
+	if change.Type != watch.Edited || len(change.Fields) != 1 || change.Fields[0] != watch.StateField {
+		t.Errorf("Expected a state change, got %+v", change)
+	}
+	if change.Before.GetSelf().State != "published" || change.After.GetSelf().State != "private" {
+		t.Errorf("Unexpected copies %v and %v", change.Before, change.After)
+	}
+}
+
+func TestBlogWatcherIgnoresEngagement(t *testing.T) {
+	client := tumblrtest.NewClient()
+	client.On(http.MethodGet, "/blog/david.tumblr.com/posts").
+		Respond(`{"response": {"posts": [{"id": 1, "type": "audio", "caption": "c", "plays": 10, "note_count": 1, "liked": false}], "total_posts": 1}}`).
+		Respond(`{"response": {"posts": [{"id": 1, "type": "audio", "caption": "c", "plays": 250, "note_count": 40, "liked": true}], "total_posts": 1}}`)
+
+	watcher := watch.NewBlogWatcher(client, "david")
+	pollOnce(t, watcher)
+	if changes := pollOnce(t, watcher); len(changes) != 0 {
+		t.Errorf("Expected no changes when only engagement changes, got %+v", changes)
+	}
+}
+
+func TestBlogWatcherIgnoresBlogAndTrail(t *testing.T) {
+	client := tumblrtest.NewClient()
+	client.On(http.MethodGet, "/blog/david.tumblr.com/posts").
+		Respond(`{"response": {"posts": [{"id": 1, "type": "future", "body": "b", "blog": {"name": "david", "updated": 100},
+			"trail": [{"blog": {"name": "anne", "title": "Anne"}, "content": "c"}]}], "total_posts": 1}}`).
+		Respond(`{"response": {"posts": [{"id": 1, "type": "future", "body": "b", "blog": {"name": "david", "updated": 200},
+			"trail": [{"blog": {"name": "anne", "title": "Anne's"}, "content": "c"}]}], "total_posts": 1}}`).
+		Respond(`{"response": {"posts": [{"id": 1, "type": "future", "body": "edited", "blog": {"name": "david", "updated": 300},
+			"trail": [{"blog": {"name": "anne", "title": "Anne's"}, "content": "c"}]}], "total_posts": 1}}`)
+
+	watcher := watch.NewBlogWatcher(client, "david")
+	pollOnce(t, watcher)
+	if changes := pollOnce(t, watcher); len(changes) != 0 {
+		t.Errorf("Expected no changes when only the blog and trail change, got %+v", changes)
+	}
+	if changes := pollOnce(t, watcher); len(changes) != 1 || changes[0].Fields[0] != watch.BodyField {
+		t.Errorf("Expected the body of the post of an unknown type to be edited, got %+v", changes)
+	}
+}
+
+func TestBlogWatcherMaxPosts(t *testing.T) {
+	server := fakeserver.New("david")
+	defer server.Close()
+	addPosts(t, server, "david", 3)
+
+	watcher := watch.NewBlogWatcher(server.Client(), "david")
+	watcher.MaxPosts = 2
+	pollOnce(t, watcher)
+	created := addPosts(t, server, "david", 1)[0]
+	// the oldest snapshotted post falls out of the window without being deleted
+	changes := pollOnce(t, watcher)
+	if len(changes) != 1 || changes[0].Type != watch.Created || changes[0].Id != created {
+		t.Errorf("Expected only the new post, got %+v", changes)
+	}
+}
+
+func TestBlogWatcherEmitInitial(t *testing.T) {
+	server := fakeserver.New("david")
+	defer server.Close()
+	ids := addPosts(t, server, "david", 2)
+
+	watcher := watch.NewBlogWatcher(server.Client(), "david")
+	watcher.EmitInitial = true
+	changes := pollOnce(t, watcher)
+	if len(changes) != 2 || changes[0].Id != ids[0] || changes[1].Type != watch.Created {
+		t.Errorf("Expected the existing posts as created, got %+v", changes)
+	}
+}
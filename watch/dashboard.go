@@ -0,0 +1,166 @@
+package watch
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/tumblr/tumblr.go"
+)
+
+// Default number of posts the dashboard returns per request
+const dashboardPageSize = 20
+
+// State of a DashboardWatcher kept in its Store
+type dashboardState struct {
+	// Id of the newest delivered post
+	SinceId uint64 `json:"since_id"`
+}
+
+// DashboardWatcher polls the user's dashboard and delivers new posts, oldest first.
+type DashboardWatcher struct {
+	Schedule
+	// Filters passed to GetDashboard, e.g. type; since_id and offset are managed by the watcher
+	Params url.Values
+	// Where the high-water mark is saved; defaults to a MemoryStore
+	Store Store
+	// Key of the watcher's state in Store
+	Key string
+	// Deliver the dashboard's current posts on the first poll, instead of only posts published afterwards
+	EmitInitial bool
+	client      tumblr.ClientInterface
+	seen        *seenSet
+}
+
+// NewDashboardWatcher creates a DashboardWatcher for the user of client.
+func NewDashboardWatcher(client tumblr.ClientInterface) *DashboardWatcher {
+	return &DashboardWatcher{client: client, Key: "dashboard", seen: newSeenSet(1000)}
+}
+
+// Run polls the dashboard until ctx is done, calling handle with each new post. The high-water mark is saved
+// once a post is handled, so a restarted watcher neither misses nor repeats posts. If handle returns an
+// error the watch stops with that error, and the post is delivered again on the next run.
+func (w *DashboardWatcher) Run(ctx context.Context, handle func(tumblr.PostInterface) error) error {
+	if w.Store == nil {
+		w.Store = NewMemoryStore()
+	}
+	state := dashboardState{}
+	found, err := w.Store.Load(w.Key, &state)
+	if err != nil {
+		return err
+	}
+	initial := !found && !w.EmitInitial
+	return w.run(ctx, func(ctx context.Context) error {
+		err := w.poll(ctx, &state, initial, handle)
+		if err == nil {
+			initial = false
+		}
+		return err
+	})
+}
+
+// Watch polls the dashboard like Run, delivering new posts over the returned channel, which is closed once
+// ctx is done. Errors saving the high-water mark end the watch and are reported to OnError.
+func (w *DashboardWatcher) Watch(ctx context.Context) <-chan tumblr.PostInterface {
+	return watchPosts(ctx, w.OnError, w.Run)
+}
+
+// Fetches the posts newer than the high-water mark, oldest first. The dashboard answers with the newest posts
+// first, so the pages are read backwards until they reach the mark; without a mark, only the newest page is read.
+func (w *DashboardWatcher) fetch(sinceId uint64) ([]tumblr.PostInterface, error) {
+	pageSize := dashboardPageSize
+	if limit, err := strconv.Atoi(w.Params.Get("limit")); err == nil && limit > 0 {
+		pageSize = limit
+	}
+	posts := []tumblr.PostInterface{}
+	fetched := map[uint64]bool{}
+	for offset := 0; ; {
+		params := url.Values{}
+		for key, values := range w.Params {
+			params[key] = values
+		}
+		// since_id cannot be combined with offset: the following pages stop once they reach the mark instead
+		if offset > 0 {
+			params.Set("offset", strconv.Itoa(offset))
+		} else if sinceId > 0 {
+			params.Set("since_id", strconv.FormatUint(sinceId, 10))
+		}
+		dashboard, err := tumblr.GetDashboard(w.client, params)
+		if err != nil {
+			return nil, err
+		}
+		reached := false
+		for _, post := range dashboard.Posts {
+			id := post.GetSelf().Id
+			// posts published while paging shift the pages, repeating some posts
+			if !fetched[id] {
+				fetched[id] = true
+				posts = append(posts, post)
+			}
+			reached = reached || id <= sinceId
+		}
+		// a short page is the last one
+		if sinceId == 0 || reached || len(dashboard.Posts) < pageSize {
+			break
+		}
+		offset += len(dashboard.Posts)
+	}
+	sort.SliceStable(posts, func(i, j int) bool {
+		return posts[i].GetSelf().Id < posts[j].GetSelf().Id
+	})
+	return posts, nil
+}
+
+// Delivers the posts newer than the high-water mark
+func (w *DashboardWatcher) poll(ctx context.Context, state *dashboardState, initial bool, handle func(tumblr.PostInterface) error) error {
+	posts, err := w.fetch(state.SinceId)
+	if err != nil {
+		return err
+	}
+	if initial {
+		// the first poll only sets the mark
+		if len(posts) > 0 {
+			state.SinceId = posts[len(posts)-1].GetSelf().Id
+		}
+		return stopOnError(w.Store.Save(w.Key, state))
+	}
+	for _, post := range posts {
+		id := post.GetSelf().Id
+		if id <= state.SinceId || w.seen.contains(id) {
+			continue
+		}
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if err = handle(post); err != nil {
+			return &stopError{err}
+		}
+		w.seen.add(id)
+		state.SinceId = id
+		if err = w.Store.Save(w.Key, state); err != nil {
+			return &stopError{err}
+		}
+	}
+	return nil
+}
+
+// Runs a watcher delivering its posts over a channel, closed when the watch ends
+func watchPosts(ctx context.Context, onError func(error), run func(context.Context, func(tumblr.PostInterface) error) error) <-chan tumblr.PostInterface {
+	posts := make(chan tumblr.PostInterface)
+	go func() {
+		defer close(posts)
+		err := run(ctx, func(post tumblr.PostInterface) error {
+			select {
+			case posts <- post:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil && ctx.Err() == nil && onError != nil {
+			onError(err)
+		}
+	}()
+	return posts
+}
@@ -0,0 +1,207 @@
+package watch_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/tumblr/tumblr.go"
+	"github.com/tumblr/tumblr.go/tumblrtest/fakeserver"
+	"github.com/tumblr/tumblr.go/watch"
+)
+
+// Adds n text posts to a blog of the server
+func addPosts(t *testing.T, server *fakeserver.Server, blog string, n int) []uint64 {
+	ids := []uint64{}
+	for i := 0; i < n; i++ {
+		id, err := server.AddPost(blog, url.Values{"type": {"text"}, "body": {"post"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Receives n posts from a watch, failing after a timeout
+func receive(t *testing.T, posts <-chan tumblr.PostInterface, n int) []uint64 {
+	ids := []uint64{}
+	for len(ids) < n {
+		select {
+		case post, ok := <-posts:
+			if !ok {
+				t.Fatalf("Watch ended after %v", ids)
+			}
+			ids = append(ids, post.GetSelf().Id)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out after receiving %v", ids)
+		}
+	}
+	return ids
+}
+
+func equalIds(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDashboardWatcher(t *testing.T) {
+	server := fakeserver.New("david")
+	defer server.Close()
+	existing := addPosts(t, server, "david", 3)
+	store := watch.NewMemoryStore()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	watcher := watch.NewDashboardWatcher(server.Client())
+	watcher.Interval = time.Millisecond
+	watcher.Store = store
+	watcher.EmitInitial = true
+	posts := watcher.Watch(ctx)
+	if ids := receive(t, posts, 3); !equalIds(ids, existing) {
+		t.Errorf("Expected the existing posts oldest first %v, got %v", existing, ids)
+	}
+	added := addPosts(t, server, "david", 2)
+	if ids := receive(t, posts, 2); !equalIds(ids, added) {
+		t.Errorf("Expected the new posts %v, got %v", added, ids)
+	}
+	cancel()
+	for range posts {
+		t.Error("Expected no more posts")
+	}
+
+	// a restarted watcher resumes from the saved mark
+	missed := addPosts(t, server, "david", 2)
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	restarted := watch.NewDashboardWatcher(server.Client())
+	restarted.Interval = time.Millisecond
+	restarted.Store = store
+	if ids := receive(t, restarted.Watch(ctx), 2); !equalIds(ids, missed) {
+		t.Errorf("Expected the posts published while stopped %v, got %v", missed, ids)
+	}
+}
+
+func TestDashboardWatcherCatchesUpPastAPage(t *testing.T) {
+	server := fakeserver.New("david")
+	defer server.Close()
+	existing := addPosts(t, server, "david", 2)
+	// a previous run delivered the existing posts
+	store := watch.NewMemoryStore()
+	store.Save("dashboard", map[string]uint64{"since_id": existing[1]})
+
+	// more posts than a dashboard page are published while the watcher is stopped
+	missed := addPosts(t, server, "david", 30)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	restarted := watch.NewDashboardWatcher(server.Client())
+	restarted.Interval = time.Millisecond
+	restarted.Store = store
+	if ids := receive(t, restarted.Watch(ctx), 30); !equalIds(ids, missed) {
+		t.Errorf("Expected every post published while stopped %v, got %v", missed, ids)
+	}
+}
+
+func TestDashboardWatcherSkipsExistingPosts(t *testing.T) {
+	server := fakeserver.New("david")
+	defer server.Close()
+	addPosts(t, server, "david", 3)
+	store := watch.NewMemoryStore()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watcher := watch.NewDashboardWatcher(server.Client())
+	watcher.Interval = time.Millisecond
+	watcher.Store = store
+	posts := watcher.Watch(ctx)
+	// wait for the first poll to set the mark
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if found, _ := store.Load("dashboard", &struct{}{}); found {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("The first poll never saved a mark")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	added := addPosts(t, server, "david", 1)
+	if ids := receive(t, posts, 1); !equalIds(ids, added) {
+		t.Errorf("Expected only the new post %v, got %v", added, ids)
+	}
+}
+
+func TestDashboardWatcherHandlerError(t *testing.T) {
+	server := fakeserver.New("david")
+	defer server.Close()
+	ids := addPosts(t, server, "david", 2)
+	store := watch.NewMemoryStore()
+	failure := errors.New("Handler failed")
+
+	watcher := watch.NewDashboardWatcher(server.Client())
+	watcher.Store = store
+	watcher.EmitInitial = true
+	handled := []uint64{}
+	err := watcher.Run(context.Background(), func(post tumblr.PostInterface) error {
+		if len(handled) == 1 {
+			return failure
+		}
+		handled = append(handled, post.GetSelf().Id)
+		return nil
+	})
+	if err != failure || !equalIds(handled, ids[:1]) {
+		t.Fatalf("Expected the watch to stop at the failing post, got %v after %v", err, handled)
+	}
+	state := struct {
+		SinceId uint64 `json:"since_id"`
+	}{}
+	if _, err = store.Load("dashboard", &state); err != nil || state.SinceId != ids[0] {
+		t.Errorf("Expected the mark to stay at the last handled post, got %d", state.SinceId)
+	}
+}
+
+func TestDashboardWatcherReportsErrors(t *testing.T) {
+	client := &failingClient{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watcher := watch.NewDashboardWatcher(client)
+	watcher.Interval = time.Millisecond
+	watcher.MaxBackoff = 4 * time.Millisecond
+	errs := make(chan error, 10)
+	watcher.OnError = func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}
+	posts := watcher.Watch(ctx)
+	for i := 0; i < 3; i++ {
+		select {
+		case err := <-errs:
+			if err.Error() != "Unavailable" {
+				t.Errorf("Unexpected error %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected failed polls to be reported and retried")
+		}
+	}
+	cancel()
+	for range posts {
+	}
+}
+
+// Fails every request
+type failingClient struct {
+	tumblr.ClientInterface
+}
+
+func (c *failingClient) GetWithParams(string, url.Values) (tumblr.Response, error) {
+	return tumblr.Response{}, errors.New("Unavailable")
+}
@@ -0,0 +1,256 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/tumblr/tumblr.go"
+)
+
+// Kinds of a FollowerChange
+const (
+	Gained ChangeType = "gained"
+	Lost   ChangeType = "lost"
+)
+
+// Default number of followers a FollowerTracker requests per page, the most the API returns
+const defaultFollowerPageSize = 20
+
+// Number of times a FollowerTracker enumerates the followers before accepting a count that moved under it
+const followerScanAttempts = 3
+
+// FollowerChange is a follower gained or lost by a blog between two snapshots.
+type FollowerChange struct {
+	Type ChangeType
+	Blog string
+	// The follower as last listed
+	Follower tumblr.Follower
+	// When the follower followed the blog, as listed by the API; if the API does not list it, when the follower
+	// was first listed
+	Since time.Time
+	// When the change was noticed
+	Time time.Time
+}
+
+// A follower of a snapshot
+type trackedFollower struct {
+	Follower tumblr.Follower `json:"follower"`
+	Since    int64           `json:"since"`
+}
+
+// The followers of a blog at some point, kept in the Store
+type followerSnapshot struct {
+	Time  int64  `json:"time"`
+	Total uint32 `json:"total"`
+	// Names on the first page, newest first
+	Newest    []string                   `json:"newest"`
+	Followers map[string]trackedFollower `json:"followers"`
+}
+
+// FollowerTracker keeps a snapshot of the followers of blogs and reports the followers gained and lost since
+// the previous snapshot, either once with Track or at an interval with Run.
+//
+// Every follower is enumerated on each run. Set QuickScan to skip the enumeration when a blog's follower count
+// and first page are unchanged.
+type FollowerTracker struct {
+	Schedule
+	Blogs []string
+	// Where snapshots are kept; defaults to a MemoryStore
+	Store Store
+	// Prefix of the snapshots' keys in Store, followed by the blog name
+	KeyPrefix string
+	// Followers requested per page; defaults to 20
+	PageSize uint
+	// Assume the followers are unchanged when their count and first page are, instead of enumerating them. A
+	// follow and an unfollow further down the list, which leave both as they were, then go unnoticed until the
+	// first page changes.
+	QuickScan bool
+	client    tumblr.ClientInterface
+}
+
+// NewFollowerTracker creates a FollowerTracker for blogs.
+func NewFollowerTracker(client tumblr.ClientInterface, blogs ...string) *FollowerTracker {
+	return &FollowerTracker{client: client, Blogs: blogs, KeyPrefix: "followers/"}
+}
+
+// Track compares the current followers of blog with its saved snapshot, saves the new snapshot and returns
+// the followers gained, oldest first, then those lost. The first snapshot of a blog reports no changes.
+func (t *FollowerTracker) Track(blog string) ([]FollowerChange, error) {
+	changes, snapshot, err := t.compare(blog)
+	if err != nil || snapshot == nil {
+		return changes, err
+	}
+	return changes, t.Store.Save(t.KeyPrefix+blog, snapshot)
+}
+
+// Run tracks the blogs until ctx is done, calling handle with each change. A blog's new snapshot is saved
+// once all its changes are handled; if handle returns an error the run stops with that error, and the
+// blog's changes are reported again on the next run.
+func (t *FollowerTracker) Run(ctx context.Context, handle func(FollowerChange) error) error {
+	if len(t.Blogs) < 1 {
+		return errors.New("No blogs to track")
+	}
+	return t.run(ctx, func(ctx context.Context) error {
+		for _, blog := range t.Blogs {
+			changes, snapshot, err := t.compare(blog)
+			if err != nil {
+				return err
+			}
+			for _, change := range changes {
+				if err = ctx.Err(); err != nil {
+					return err
+				}
+				if err = handle(change); err != nil {
+					return &stopError{err}
+				}
+			}
+			if snapshot != nil {
+				if err = stopOnError(t.Store.Save(t.KeyPrefix+blog, snapshot)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Returns the current time
+func (t *FollowerTracker) time() time.Time {
+	if t.now != nil {
+		return t.now()
+	}
+	return time.Now()
+}
+
+// Reports whether the first page of followers matches a snapshot
+func unchangedFollowers(first *tumblr.FollowerList, previous *followerSnapshot) bool {
+	if first.Total != previous.Total || len(first.Followers) != len(previous.Newest) {
+		return false
+	}
+	for i, follower := range first.Followers {
+		if follower.Name != previous.Newest[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Lists every follower of a blog, newest first, starting from its first page. Followers gained or lost while
+// paging shift the pages, so the list is read again if the count moved.
+func (t *FollowerTracker) enumerate(blog string, first *tumblr.FollowerList) ([]tumblr.Follower, error) {
+	limit := t.PageSize
+	if limit < 1 {
+		limit = defaultFollowerPageSize
+	}
+	var followers []tumblr.Follower
+	for attempt := 1; ; attempt++ {
+		followers = make([]tumblr.Follower, 0, first.Total)
+		included := map[string]bool{}
+		moved := false
+		page := first
+		for {
+			moved = moved || page.Total != first.Total
+			for _, follower := range page.Followers {
+				if !included[follower.Name] {
+					included[follower.Name] = true
+					followers = append(followers, follower)
+				}
+			}
+			next, err := page.Next()
+			if err == tumblr.NoNextPageError {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			page = next
+		}
+		if !moved || attempt >= followerScanAttempts {
+			return followers, nil
+		}
+		var err error
+		if first, err = tumblr.GetFollowers(t.client, blog, 0, limit); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Lists the followers of a blog and compares them with its snapshot, returning the changes and the new
+// snapshot, which is nil when nothing changed
+func (t *FollowerTracker) compare(blog string) ([]FollowerChange, *followerSnapshot, error) {
+	if t.Store == nil {
+		t.Store = NewMemoryStore()
+	}
+	previous := &followerSnapshot{}
+	found, err := t.Store.Load(t.KeyPrefix+blog, previous)
+	if err != nil {
+		return nil, nil, &stopError{err}
+	}
+	limit := t.PageSize
+	if limit < 1 {
+		limit = defaultFollowerPageSize
+	}
+	first, err := tumblr.GetFollowers(t.client, blog, 0, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	if found && t.QuickScan && unchangedFollowers(first, previous) {
+		return []FollowerChange{}, nil, nil
+	}
+	followers, err := t.enumerate(blog, first)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := t.time()
+	current := &followerSnapshot{
+		Time:      now.Unix(),
+		Total:     first.Total,
+		Newest:    []string{},
+		Followers: make(map[string]trackedFollower, len(followers)),
+	}
+	for _, follower := range first.Followers {
+		current.Newest = append(current.Newest, follower.Name)
+	}
+	changes := []FollowerChange{}
+	// oldest first, as they were gained
+	for i := len(followers) - 1; i >= 0; i-- {
+		follower := followers[i]
+		tracked, existed := previous.Followers[follower.Name]
+		if !existed {
+			tracked.Since = follower.Updated
+			if tracked.Since == 0 {
+				tracked.Since = now.Unix()
+			}
+			if found {
+				changes = append(changes, FollowerChange{
+					Type:     Gained,
+					Blog:     blog,
+					Follower: follower,
+					Since:    time.Unix(tracked.Since, 0),
+					Time:     now,
+				})
+			}
+		}
+		tracked.Follower = follower
+		current.Followers[follower.Name] = tracked
+	}
+	lost := []FollowerChange{}
+	for name, tracked := range previous.Followers {
+		if _, ok := current.Followers[name]; !ok {
+			lost = append(lost, FollowerChange{
+				Type:     Lost,
+				Blog:     blog,
+				Follower: tracked.Follower,
+				Since:    time.Unix(tracked.Since, 0),
+				Time:     now,
+			})
+		}
+	}
+	sort.Slice(lost, func(i, j int) bool {
+		return lost[i].Follower.Name < lost[j].Follower.Name
+	})
+	return append(changes, lost...), current, nil
+}
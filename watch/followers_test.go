@@ -0,0 +1,127 @@
+package watch_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/tumblr/tumblr.go"
+	"github.com/tumblr/tumblr.go/tumblrtest"
+	"github.com/tumblr/tumblr.go/tumblrtest/fakeserver"
+	"github.com/tumblr/tumblr.go/watch"
+)
+
+func TestFollowerTracker(t *testing.T) {
+	server := fakeserver.New("david")
+	defer server.Close()
+	client := server.Client()
+	server.AddBlog("other", "Other")
+	// more than a page of followers
+	for i := 0; i < 45; i++ {
+		if err := server.AddFollower("other", fmt.Sprintf("follower%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tracker := watch.NewFollowerTracker(client)
+	if changes, err := tracker.Track("other"); err != nil || len(changes) != 0 {
+		t.Fatalf("Expected the first snapshot to be taken silently, got %v, %v", changes, err)
+	}
+	before := time.Now()
+	if err := tumblr.Follow(client, "other"); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := tracker.Track("other")
+	if err != nil || len(changes) != 1 {
+		t.Fatalf("Expected one change, got %v, %v", changes, err)
+	}
+	gained := changes[0]
+	if gained.Type != watch.Gained || gained.Blog != "other" || gained.Follower.Name != "david" || gained.Time.Before(before.Truncate(time.Second)) {
+		t.Errorf("Expected the user as gained, got %+v", gained)
+	}
+
+	if err = tumblr.Unfollow(client, "other"); err != nil {
+		t.Fatal(err)
+	}
+	changes, err = tracker.Track("other")
+	if err != nil || len(changes) != 1 {
+		t.Fatalf("Expected one change, got %v, %v", changes, err)
+	}
+	if lost := changes[0]; lost.Type != watch.Lost || lost.Follower.Name != "david" || lost.Since.Unix() != gained.Since.Unix() {
+		t.Errorf("Expected the user as lost since they were gained, got %+v", lost)
+	}
+}
+
+// Responds to a page of followers with the given names
+func followersPage(total int, names ...string) string {
+	users := ""
+	for i, name := range names {
+		if i > 0 {
+			users += ","
+		}
+		users += fmt.Sprintf(`{"name": "%s", "updated": 100}`, name)
+	}
+	return fmt.Sprintf(`{"response": {"total_users": %d, "users": [%s]}}`, total, users)
+}
+
+func TestFollowerTrackerUnchangedFirstPage(t *testing.T) {
+	client := tumblrtest.NewClient()
+	client.On(http.MethodGet, "/blog/david.tumblr.com/followers").WithParam("offset", "0").
+		Respond(followersPage(3, "c", "b"))
+	client.On(http.MethodGet, "/blog/david.tumblr.com/followers").WithParam("offset", "2").
+		Respond(followersPage(3, "a"))
+
+	tracker := watch.NewFollowerTracker(client)
+	tracker.PageSize = 2
+	tracker.QuickScan = true
+	for i := 0; i < 2; i++ {
+		if changes, err := tracker.Track("david"); err != nil || len(changes) != 0 {
+			t.Fatalf("Expected no changes, got %v, %v", changes, err)
+		}
+	}
+	// the second run stopped at the unchanged first page
+	if calls := client.Calls(); len(calls) != 3 {
+		t.Errorf("Expected 3 requests, got %v", calls)
+	}
+
+	tracker.QuickScan = false
+	if _, err := tracker.Track("david"); err != nil {
+		t.Fatal(err)
+	}
+	if calls := client.Calls(); len(calls) != 5 {
+		t.Errorf("Expected every page to be read, got %v", calls)
+	}
+}
+
+func TestFollowerTrackerRun(t *testing.T) {
+	client := tumblrtest.NewClient()
+	client.On(http.MethodGet, "/blog/david.tumblr.com/followers").
+		Respond(followersPage(2, "b", "a")).
+		Respond(followersPage(2, "c", "b"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tracker := watch.NewFollowerTracker(client, "david")
+	tracker.Interval = time.Millisecond
+	changes := []watch.FollowerChange{}
+	err := tracker.Run(ctx, func(change watch.FollowerChange) error {
+		changes = append(changes, change)
+		if len(changes) == 2 {
+			cancel()
+		}
+		return nil
+	})
+	if err != context.Canceled {
+		t.Errorf("Expected the run to be cancelled, got %v", err)
+	}
+	if len(changes) != 2 || changes[0].Type != watch.Gained || changes[0].Follower.Name != "c" ||
+		changes[1].Type != watch.Lost || changes[1].Follower.Name != "a" {
+		t.Errorf("Expected c gained and a lost, got %+v", changes)
+	}
+	// followed when the API says
+	if changes[0].Since.Unix() != 100 || changes[1].Since.Unix() != 100 {
+		t.Errorf("Expected the follow times listed by the API, got %+v", changes)
+	}
+}
@@ -0,0 +1,120 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/tumblr/tumblr.go"
+)
+
+// Default time between polls
+const DefaultInterval = time.Minute
+
+// Default longest wait after consecutive failures
+const DefaultMaxBackoff = 15 * time.Minute
+
+// Error returned by a poll which must end the watch, rather than be retried
+type stopError struct {
+	err error
+}
+
+func (e *stopError) Error() string {
+	return e.err.Error()
+}
+
+// Wraps errors which must end a watch
+func stopOnError(err error) error {
+	if err != nil {
+		return &stopError{err}
+	}
+	return nil
+}
+
+// Schedule configures how often a watcher polls. It is embedded in every watcher.
+type Schedule struct {
+	// Time between polls; defaults to DefaultInterval
+	Interval time.Duration
+	// Longest wait after consecutive failures; defaults to DefaultMaxBackoff
+	MaxBackoff time.Duration
+	// Called with the errors of failed polls, which are retried
+	OnError func(error)
+	now     func() time.Time
+	sleep   func(ctx context.Context, d time.Duration) error
+}
+
+// Waits for d, or until the context is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Returns how long to wait after a poll: the interval after a success, until the quota resets after a
+// rate limit error, and an exponentially growing delay after consecutive failures
+func (s *Schedule) delay(err error, failures int) time.Duration {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	if err == nil {
+		return interval
+	}
+	limit := &tumblr.RateLimitError{}
+	if errors.As(err, &limit) && !limit.Reset.IsZero() {
+		if wait := limit.Reset.Sub(s.now()); wait > interval {
+			return wait
+		}
+		return interval
+	}
+	maxBackoff := s.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+	wait := interval
+	for i := 1; i < failures && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait
+}
+
+// Calls poll until the context is done or poll returns a stopError. Other errors are reported to OnError
+// and retried after a backoff.
+func (s *Schedule) run(ctx context.Context, poll func(ctx context.Context) error) error {
+	if s.now == nil {
+		s.now = time.Now
+	}
+	if s.sleep == nil {
+		s.sleep = sleepContext
+	}
+	failures := 0
+	for {
+		err := poll(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		stop := &stopError{}
+		if errors.As(err, &stop) {
+			return stop.err
+		}
+		if err != nil {
+			failures++
+			if s.OnError != nil {
+				s.OnError(err)
+			}
+		} else {
+			failures = 0
+		}
+		if err = s.sleep(ctx, s.delay(err, failures)); err != nil {
+			return err
+		}
+	}
+}
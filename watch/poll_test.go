@@ -0,0 +1,66 @@
+package watch
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tumblr/tumblr.go"
+)
+
+func TestScheduleDelay(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := &Schedule{Interval: time.Minute, MaxBackoff: 5 * time.Minute, now: func() time.Time { return now }}
+	failure := errors.New("Failed")
+	cases := []struct {
+		err      error
+		failures int
+		expected time.Duration
+	}{
+		{nil, 0, time.Minute},
+		{failure, 1, time.Minute},
+		{failure, 2, 2 * time.Minute},
+		{failure, 3, 4 * time.Minute},
+		{failure, 4, 5 * time.Minute},
+		{failure, 40, 5 * time.Minute},
+		{&tumblr.RateLimitError{Reset: now.Add(time.Hour)}, 1, time.Hour},
+		{&tumblr.RateLimitError{Reset: now.Add(time.Second)}, 1, time.Minute},
+	}
+	for _, c := range cases {
+		if delay := s.delay(c.err, c.failures); delay != c.expected {
+			t.Errorf("Expected a delay of %s after %d failures with %v, got %s", c.expected, c.failures, c.err, delay)
+		}
+	}
+}
+
+func TestSeenSet(t *testing.T) {
+	s := newSeenSet(3)
+	for _, id := range []uint64{1, 2, 3, 2, 4} {
+		s.add(id)
+	}
+	if s.contains(1) {
+		t.Error("Expected the oldest id to be evicted")
+	}
+	for _, id := range []uint64{2, 3, 4} {
+		if !s.contains(id) {
+			t.Errorf("Expected %d to be kept", id)
+		}
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := map[string]int{}
+	if found, err := store.Load("tag/cats", &value); found || err != nil {
+		t.Fatalf("Expected nothing saved, got %v, %v", found, err)
+	}
+	if err = store.Save("tag/cats", map[string]int{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if found, err := store.Load("tag/cats", &value); !found || err != nil || value["a"] != 1 {
+		t.Errorf("Unexpected value %v, %v, %v", value, found, err)
+	}
+}
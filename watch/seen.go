@@ -0,0 +1,41 @@
+package watch
+
+// A set of the most recently added post ids, forgetting the oldest beyond its capacity
+type seenSet struct {
+	ids   map[uint64]bool
+	order []uint64
+	next  int
+}
+
+// Creates an empty seenSet holding up to capacity ids
+func newSeenSet(capacity int) *seenSet {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &seenSet{ids: map[uint64]bool{}, order: make([]uint64, 0, capacity)}
+}
+
+// Reports whether id is in the set
+func (s *seenSet) contains(id uint64) bool {
+	return s.ids[id]
+}
+
+// Adds id, evicting the oldest id if the set is full
+func (s *seenSet) add(id uint64) {
+	if s.ids[id] {
+		return
+	}
+	if len(s.order) < cap(s.order) {
+		s.order = append(s.order, id)
+	} else {
+		delete(s.ids, s.order[s.next])
+		s.order[s.next] = id
+		s.next = (s.next + 1) % len(s.order)
+	}
+	s.ids[id] = true
+}
+
+// Returns the ids, oldest first
+func (s *seenSet) list() []uint64 {
+	return append(append([]uint64{}, s.order[s.next:]...), s.order[:s.next]...)
+}
@@ -0,0 +1,110 @@
+// Package watch follows Tumblr activity over time: new dashboard and tagged posts, changes to blogs' posts,
+// and gained or lost followers.
+//
+// Watchers poll the API at an interval, backing off on errors and waiting out exhausted rate limits; wrap the
+// client with tumblr.NewRateLimitClient so rate limits are reported as *tumblr.RateLimitError. Their state is
+// kept in a Store, so a restarted process picks up where the previous one stopped.
+package watch
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Store persists the state of watchers, as JSON values under string keys.
+type Store interface {
+	// Load decodes the value saved under key into v, reporting false if nothing was saved.
+	Load(key string, v interface{}) (bool, error)
+	Save(key string, v interface{}) error
+}
+
+// MemoryStore is a Store keeping values in memory, for tests and short-lived processes.
+type MemoryStore struct {
+	mutex  sync.Mutex
+	values map[string][]byte
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{values: map[string][]byte{}}
+}
+
+// Load implements Store.
+func (s *MemoryStore) Load(key string, v interface{}) (bool, error) {
+	s.mutex.Lock()
+	b, ok := s.values[key]
+	s.mutex.Unlock()
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(b, v)
+}
+
+// Save implements Store.
+func (s *MemoryStore) Save(key string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.values[key] = b
+	return nil
+}
+
+// FileStore is a Store keeping each value in a JSON file of a directory.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates a FileStore in dir, creating the directory if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Returns the file of a key
+func (s *FileStore) path(key string) string {
+	return filepath.Join(s.dir, url.PathEscape(key)+".json")
+}
+
+// Load implements Store.
+func (s *FileStore) Load(key string, v interface{}) (bool, error) {
+	b, err := os.ReadFile(s.path(key))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(b, v)
+}
+
+// Save implements Store. Values are written aside and renamed into place, so a crash never leaves a partial file.
+func (s *FileStore) Save(key string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(s.dir, ".save-*")
+	if err != nil {
+		return err
+	}
+	if _, err = temp.Write(b); err == nil {
+		err = temp.Close()
+	} else {
+		temp.Close()
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), s.path(key))
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+	}
+	return err
+}
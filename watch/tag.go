@@ -0,0 +1,254 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/tumblr/tumblr.go"
+)
+
+// Default number of post ids a TagWatcher remembers
+const DefaultSeenCapacity = 5000
+
+// Default number of pages a TagWatcher reads per tag and poll
+const defaultMaxPages = 5
+
+// State of a TagWatcher kept in its Store
+type tagState struct {
+	// Newest effective timestamp seen per tag
+	Marks map[string]uint64 `json:"marks"`
+	// Recently delivered post ids, oldest first
+	Seen []uint64 `json:"seen"`
+	// Ranges of effective timestamps per tag which were skipped and are yet to be backfilled
+	Gaps map[string][]tagGap `json:"gaps,omitempty"`
+}
+
+// Range of effective timestamps whose posts were not delivered yet
+type tagGap struct {
+	From uint64 `json:"from"`
+	To   uint64 `json:"to"`
+}
+
+// TagWatcher polls TaggedSearch for a set of tags and delivers the posts it has not delivered before,
+// oldest first. A post carrying several watched tags is delivered once.
+//
+// Tagged results are ordered by when a post was featured in the tag if it was, and by when it was published
+// otherwise, as SearchResults.Next assumes; the watcher orders and pages by that effective timestamp too.
+type TagWatcher struct {
+	Schedule
+	Tags []string
+	// Where the marks and seen ids are saved; nil keeps them in memory only
+	Store Store
+	// Key of the watcher's state in Store
+	Key string
+	// Number of post ids remembered to avoid repeats; defaults to DefaultSeenCapacity
+	SeenCapacity int
+	// Pages read per tag and poll when catching up; defaults to 5. Posts beyond them are reported to OnError
+	// as a *GapError, and backfilled on the following polls, MaxPages pages at a time.
+	MaxPages int
+	// Deliver the tags' current posts on the first poll, instead of only posts tagged afterwards
+	EmitInitial bool
+	client      tumblr.ClientInterface
+}
+
+// NewTagWatcher creates a TagWatcher for tags.
+func NewTagWatcher(client tumblr.ClientInterface, tags ...string) *TagWatcher {
+	return &TagWatcher{client: client, Tags: tags, Key: "tags"}
+}
+
+// GapError reports posts of a tag a TagWatcher could not read in a poll because there were more new posts than
+// MaxPages pages. The posts tagged between From and To, as effective timestamps, are delivered on the
+// following polls, after newer ones.
+type GapError struct {
+	Tag  string
+	From uint64
+	To   uint64
+}
+
+// Error implements the error interface.
+func (e *GapError) Error() string {
+	return fmt.Sprintf("Deferred posts tagged %s between %d and %d", e.Tag, e.From, e.To)
+}
+
+// Returns the timestamp tagged results are ordered by
+func effectiveTimestamp(post tumblr.PostInterface) uint64 {
+	self := post.GetSelf()
+	if self.FeaturedTimestamp > 0 {
+		return self.FeaturedTimestamp
+	}
+	return self.Timestamp
+}
+
+// Run polls the tags until ctx is done, calling handle with each new post. If handle returns an error the
+// watch stops with that error, and the post is delivered again on the next run.
+func (w *TagWatcher) Run(ctx context.Context, handle func(tumblr.PostInterface) error) error {
+	if len(w.Tags) < 1 {
+		return errors.New("No tags to watch")
+	}
+	capacity := w.SeenCapacity
+	if capacity < 1 {
+		capacity = DefaultSeenCapacity
+	}
+	state := tagState{}
+	found := false
+	if w.Store != nil {
+		var err error
+		if found, err = w.Store.Load(w.Key, &state); err != nil {
+			return err
+		}
+	}
+	if state.Marks == nil {
+		state.Marks = map[string]uint64{}
+	}
+	if state.Gaps == nil {
+		state.Gaps = map[string][]tagGap{}
+	}
+	seen := newSeenSet(capacity)
+	for _, id := range state.Seen {
+		seen.add(id)
+	}
+	initial := !found && !w.EmitInitial
+	return w.run(ctx, func(ctx context.Context) error {
+		err := w.poll(ctx, &state, seen, initial, handle)
+		if err == nil {
+			initial = false
+		}
+		return err
+	})
+}
+
+// Watch polls the tags like Run, delivering new posts over the returned channel, which is closed once ctx is
+// done. Errors saving the watcher's state end the watch and are reported to OnError.
+func (w *TagWatcher) Watch(ctx context.Context) <-chan tumblr.PostInterface {
+	return watchPosts(ctx, w.OnError, w.Run)
+}
+
+// Saves the state, if there is a store
+func (w *TagWatcher) save(state *tagState, seen *seenSet) error {
+	if w.Store == nil {
+		return nil
+	}
+	state.Seen = seen.list()
+	return stopOnError(w.Store.Save(w.Key, state))
+}
+
+// Fetches the posts tagged since mark, reading back from before if it is set, and from the newest post
+// otherwise. complete is false if MaxPages ran out before the mark was reached.
+func (w *TagWatcher) fetch(tag string, mark, before uint64) (posts []tumblr.PostInterface, complete bool, err error) {
+	maxPages := w.MaxPages
+	if maxPages < 1 {
+		maxPages = defaultMaxPages
+	}
+	if mark == 0 {
+		maxPages = 1
+	}
+	params := url.Values{}
+	if before > 0 {
+		params.Set("before", strconv.FormatUint(before, 10))
+	}
+	posts = []tumblr.PostInterface{}
+	results, err := tumblr.TaggedSearch(w.client, tag, params)
+	for page := 1; ; page++ {
+		if err != nil {
+			return nil, false, err
+		}
+		posts = append(posts, results.Posts...)
+		if len(results.Posts) < 1 || effectiveTimestamp(results.Posts[len(results.Posts)-1]) <= mark {
+			return posts, true, nil
+		}
+		if page >= maxPages {
+			return posts, mark == 0, nil
+		}
+		results, err = results.Next()
+	}
+}
+
+// Returns the oldest effective timestamp of posts
+func oldestTimestamp(posts []tumblr.PostInterface) uint64 {
+	oldest := uint64(0)
+	for _, post := range posts {
+		if ts := effectiveTimestamp(post); oldest == 0 || ts < oldest {
+			oldest = ts
+		}
+	}
+	return oldest
+}
+
+// Delivers the posts tagged since the last poll, then backfills the gaps left by earlier polls
+func (w *TagWatcher) poll(ctx context.Context, state *tagState, seen *seenSet, initial bool, handle func(tumblr.PostInterface) error) error {
+	fresh := []tumblr.PostInterface{}
+	marks := map[string]uint64{}
+	gaps := map[string][]tagGap{}
+	included := map[uint64]bool{}
+	// collects the posts tagged from the given effective timestamp on, up to to if it is set
+	collect := func(posts []tumblr.PostInterface, from, to uint64) {
+		for _, post := range posts {
+			id := post.GetSelf().Id
+			ts := effectiveTimestamp(post)
+			// posts older than the mark were delivered already, even if they have been forgotten since
+			if ts >= from && (to == 0 || ts <= to) && !seen.contains(id) && !included[id] {
+				included[id] = true
+				fresh = append(fresh, post)
+			}
+		}
+	}
+	for _, tag := range w.Tags {
+		mark := state.Marks[tag]
+		posts, complete, err := w.fetch(tag, mark, 0)
+		if err != nil {
+			return err
+		}
+		marks[tag] = mark
+		for _, post := range posts {
+			if ts := effectiveTimestamp(post); ts > marks[tag] {
+				marks[tag] = ts
+			}
+		}
+		collect(posts, mark, 0)
+		if !complete && len(posts) > 0 {
+			gap := tagGap{From: mark, To: oldestTimestamp(posts)}
+			gaps[tag] = append(gaps[tag], gap)
+			if w.OnError != nil {
+				w.OnError(&GapError{Tag: tag, From: gap.From, To: gap.To})
+			}
+		}
+		for _, gap := range state.Gaps[tag] {
+			// posts sharing the second the gap ends on may straddle it, and are read again
+			posts, complete, err := w.fetch(tag, gap.From, gap.To+1)
+			if err != nil {
+				return err
+			}
+			collect(posts, gap.From, gap.To)
+			if !complete && len(posts) > 0 {
+				gap.To = oldestTimestamp(posts)
+				gaps[tag] = append(gaps[tag], gap)
+			}
+		}
+	}
+	sort.SliceStable(fresh, func(i, j int) bool {
+		return effectiveTimestamp(fresh[i]) < effectiveTimestamp(fresh[j])
+	})
+	for _, post := range fresh {
+		if !initial {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := handle(post); err != nil {
+				return &stopError{err}
+			}
+		}
+		seen.add(post.GetSelf().Id)
+		if !initial {
+			if err := w.save(state, seen); err != nil {
+				return err
+			}
+		}
+	}
+	state.Marks = marks
+	state.Gaps = gaps
+	return w.save(state, seen)
+}
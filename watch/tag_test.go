@@ -0,0 +1,185 @@
+package watch_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/tumblr/tumblr.go/tumblrtest"
+	"github.com/tumblr/tumblr.go/tumblrtest/fakeserver"
+	"github.com/tumblr/tumblr.go/watch"
+)
+
+// Adds a post with the given tags to a blog of the server
+func addTagged(t *testing.T, server *fakeserver.Server, tags string) uint64 {
+	id, err := server.AddPost("david", url.Values{"type": {"text"}, "body": {"post"}, "tags": {tags}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestTagWatcher(t *testing.T) {
+	server := fakeserver.New("david")
+	defer server.Close()
+	both := addTagged(t, server, "cats,dogs")
+	cats := addTagged(t, server, "cats")
+	store := watch.NewMemoryStore()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	watcher := watch.NewTagWatcher(server.Client(), "cats", "dogs")
+	watcher.Interval = time.Millisecond
+	watcher.Store = store
+	watcher.EmitInitial = true
+	posts := watcher.Watch(ctx)
+	if ids := receive(t, posts, 2); !equalIds(ids, []uint64{both, cats}) {
+		t.Errorf("Expected each tagged post once, oldest first, got %v", ids)
+	}
+	// more than a page of new posts
+	added := []uint64{}
+	for i := 0; i < 25; i++ {
+		added = append(added, addTagged(t, server, "dogs"))
+	}
+	if ids := receive(t, posts, 25); !equalIds(ids, added) {
+		t.Errorf("Expected the new posts %v, got %v", added, ids)
+	}
+	cancel()
+	for range posts {
+		t.Error("Expected no more posts")
+	}
+
+	// a restarted watcher does not repeat posts
+	latest := addTagged(t, server, "cats")
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	restarted := watch.NewTagWatcher(server.Client(), "cats", "dogs")
+	restarted.Interval = time.Millisecond
+	restarted.Store = store
+	if ids := receive(t, restarted.Watch(ctx), 1); ids[0] != latest {
+		t.Errorf("Expected only the post tagged while stopped %d, got %v", latest, ids)
+	}
+}
+
+func TestTagWatcherReportsGaps(t *testing.T) {
+	server := fakeserver.New("david")
+	defer server.Close()
+	addTagged(t, server, "cats")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watcher := watch.NewTagWatcher(server.Client(), "cats")
+	watcher.Interval = time.Millisecond
+	watcher.MaxPages = 1
+	gaps := make(chan *watch.GapError, 10)
+	watcher.OnError = func(err error) {
+		if gap, ok := err.(*watch.GapError); ok {
+			gaps <- gap
+		}
+	}
+	store := watch.NewMemoryStore()
+	watcher.Store = store
+	posts := watcher.Watch(ctx)
+	// wait for the first poll to set the mark
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if found, _ := store.Load("tags", &struct{}{}); found {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("The first poll never saved a mark")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// more new posts than MaxPages pages hold
+	added := []uint64{}
+	for i := 0; i < 25; i++ {
+		added = append(added, addTagged(t, server, "cats"))
+	}
+	if ids := receive(t, posts, 20); !equalIds(ids, added[5:]) {
+		t.Errorf("Expected the newest page of posts %v, got %v", added[5:], ids)
+	}
+	select {
+	case gap := <-gaps:
+		if gap.Tag != "cats" || gap.From == 0 || gap.To <= gap.From {
+			t.Errorf("Unexpected gap %+v", gap)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the skipped posts to be reported")
+	}
+	// the next poll backfills the gap, without repeating the posts delivered already
+	if ids := receive(t, posts, 5); !equalIds(ids, added[:5]) {
+		t.Errorf("Expected the skipped posts %v, got %v", added[:5], ids)
+	}
+	select {
+	case post := <-posts:
+		t.Errorf("Unexpected post %d", post.GetSelf().Id)
+	case gap := <-gaps:
+		t.Errorf("The gap should be reported once, got %+v", gap)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestTagWatcherFeaturedPosts(t *testing.T) {
+	client := tumblrtest.NewClient()
+	client.On(http.MethodGet, "/tagged").
+		Respond(`{"response": [
+			{"id": 2, "type": "text", "timestamp": 400},
+			{"id": 1, "type": "text", "timestamp": 100, "featured_timestamp": 300}
+		]}`).
+		// an old post featured since the first poll sorts first, by when it was featured
+		Respond(`{"response": [
+			{"id": 3, "type": "text", "timestamp": 50, "featured_timestamp": 600},
+			{"id": 4, "type": "text", "timestamp": 500},
+			{"id": 2, "type": "text", "timestamp": 400},
+			{"id": 1, "type": "text", "timestamp": 100, "featured_timestamp": 300}
+		]}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watcher := watch.NewTagWatcher(client, "cats")
+	watcher.Interval = time.Millisecond
+	if ids := receive(t, watcher.Watch(ctx), 2); !equalIds(ids, []uint64{4, 3}) {
+		t.Errorf("Expected the new posts by effective timestamp, got %v", ids)
+	}
+	// the second page was never needed: its newest post is older than the mark
+	for _, call := range client.Calls() {
+		if call.Params.Get("before") != "" {
+			t.Errorf("Unexpected paging request %v", call.Params)
+		}
+	}
+}
+
+func TestTagWatcherForgetsBeyondCapacity(t *testing.T) {
+	server := fakeserver.New("david")
+	defer server.Close()
+	addTagged(t, server, "cats")
+	store := watch.NewMemoryStore()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watcher := watch.NewTagWatcher(server.Client(), "cats")
+	watcher.Interval = time.Millisecond
+	watcher.Store = store
+	watcher.SeenCapacity = 2
+	watcher.EmitInitial = true
+	posts := watcher.Watch(ctx)
+	receive(t, posts, 1)
+	addTagged(t, server, "cats")
+	addTagged(t, server, "cats")
+	receive(t, posts, 2)
+	// the forgotten first post is not delivered again
+	addTagged(t, server, "cats")
+	if ids := receive(t, posts, 1); len(ids) != 1 {
+		t.Errorf("Unexpected posts %v", ids)
+	}
+	cancel()
+	for range posts {
+	}
+	state := struct {
+		Seen []uint64 `json:"seen"`
+	}{}
+	if _, err := store.Load("tags", &state); err != nil || len(state.Seen) != 2 {
+		t.Errorf("Expected the two newest ids to be kept, got %v, %v", state.Seen, err)
+	}
+}